@@ -7,11 +7,18 @@ package main
 // @BasePath /api
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
 
 	_ "bottomley.ian/musicserver/docs"
 	_ "modernc.org/sqlite"
@@ -26,13 +33,16 @@ import (
 	"bottomley.ian/musicserver/internal/handlers"
 	"bottomley.ian/musicserver/internal/services/fs"
 	"bottomley.ian/musicserver/internal/services/scanner"
+	"bottomley.ian/musicserver/internal/services/transcode"
 	"bottomley.ian/musicserver/internal/store"
 )
 
 func main() {
 	dbPath := getenv("DB_PATH", "./data.sqlite")
 
-	sqlite, err := sql.Open("sqlite", dbPath)
+	// Connection-scoped pragmas go in the DSN so every pooled connection gets them;
+	// background scans, watchers and the folder monitor write concurrently.
+	sqlite, err := sql.Open("sqlite", dbPath+"?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -43,12 +53,6 @@ func main() {
 	if _, err := sqlite.Exec(`PRAGMA journal_mode = WAL;`); err != nil {
 		log.Fatal(err)
 	}
-	if _, err := sqlite.Exec(`PRAGMA foreign_keys = ON;`); err != nil {
-		log.Fatal(err)
-	}
-	if _, err := sqlite.Exec(`PRAGMA busy_timeout = 5000;`); err != nil {
-		log.Fatal(err)
-	}
 
 	if err := store.ApplyMigrations(sqlite); err != nil {
 		log.Fatal(err)
@@ -61,8 +65,31 @@ func main() {
 		Queries: db.New(sqlite),
 		FS:      fs.OSFS{},
 	}
-	s := scanner.New(a.Queries, a.FS)
+	s := scanner.New(a.DB, a.Queries, a.FS)
+	s.Workers = getenvInt("SCAN_WORKERS", 0)
+	s.BatchSize = getenvInt("SCAN_BATCH_SIZE", 0)
+	s.Loudness = getenvBool("SCAN_LOUDNESS", false)
+	if err := s.RecoverInterruptedScans(context.Background(), getenv("SCAN_RECOVERY", scanner.RecoveryNone)); err != nil {
+		log.Fatal(err)
+	}
+	if err := s.WatchFolders(context.Background()); err != nil {
+		log.Printf("failed to start folder watchers: %v", err)
+	}
+	// FOLDER_MONITOR_SECONDS=0 disables availability checks and scheduled rescans.
+	if secs := getenvInt("FOLDER_MONITOR_SECONDS", 60); secs > 0 {
+		go s.MonitorFolders(context.Background(), time.Duration(secs)*time.Second)
+	}
 	h := handlers.New(a, s)
+	h.InferPlays = getenvBool("INFER_PLAYS", false)
+	h.Transcoder = transcode.New(getenvInt("TRANSCODE_MAX", 0))
+	h.HLSCache, err = transcode.NewCache(getenv("HLS_CACHE_DIR", filepath.Join("tmp", "hls")), int64(getenvInt("HLS_CACHE_MB", 1024))<<20)
+	if err != nil {
+		log.Fatal(err)
+	}
+	h.TranscodeCache, err = transcode.NewCache(getenv("TRANSCODE_CACHE_DIR", filepath.Join("tmp", "transcodes")), int64(getenvInt("TRANSCODE_CACHE_MB", 4096))<<20)
+	if err != nil {
+		log.Fatal(err)
+	}
 	r := chi.NewRouter()
 
 	// global middleware
@@ -84,18 +111,42 @@ func main() {
 			r.Delete("/{id}", h.DeleteFolder)
 			r.Post("/{id}/scan", h.ScanFolder)
 			r.Get("/{id}/scan", h.ScanStatus)
+			r.Get("/{id}/scans", h.ListFolderScans)
+			r.Put("/{id}/watch", h.SetFolderWatch)
+			r.Put("/{id}/schedule", h.SetFolderSchedule)
 			r.Post("/", h.CreateFolder)
 		})
+		r.Get("/search", h.Search)
+		r.Route("/scans", func(r chi.Router) {
+			r.Get("/{runId}/errors", h.ListScanErrors)
+		})
 		r.Route("/tracks", func(r chi.Router) {
 			r.Get("/", h.ListTracks)
 			r.Get("/{id}", h.GetTrack)
 			r.Put("/{id}", h.UpdateTrack)
 			r.Patch("/{id}/rating", h.UpdateTrackRating)
 			r.Get("/{id}/play", h.StreamTrack)
+			r.Get("/{id}/stream", h.TranscodeTrack)
+			r.Get("/{id}/hls/master.m3u8", h.HLSMasterPlaylist)
+			r.Get("/{id}/hls/{variant}/index.m3u8", h.HLSVariantPlaylist)
+			r.Get("/{id}/hls/{variant}/{segment}", h.HLSSegment)
+			r.Post("/{id}/played", h.RecordTrackPlay)
 			r.Get("/{id}/download", h.DownloadTrack)
 			r.Get("/{id}/image", h.GetTrackImage)
 			r.Post("/{id}/image", h.UpdateTrackImage)
 		})
+		r.Route("/library", func(r chi.Router) {
+			r.Get("/formats", h.GetLibraryFormats)
+		})
+		r.Route("/cache", func(r chi.Router) {
+			r.Get("/transcodes", h.GetTranscodeCacheStats)
+		})
+		r.Route("/plays", func(r chi.Router) {
+			r.Get("/", h.ListPlays)
+			r.Get("/recent", h.ListRecentlyPlayedTracks)
+			r.Get("/top", h.ListMostPlayedTracks)
+			r.Get("/stats", h.GetPlayStats)
+		})
 		r.Route("/artists", func(r chi.Router) {
 			r.Get("/", h.ListArtists)
 			r.Get("/{id}", h.GetArtist)
@@ -107,6 +158,8 @@ func main() {
 			r.Get("/{id}", h.GetAlbum)
 			r.Get("/{id}/tracks", h.ListAlbumTracks)
 			r.Put("/{id}", h.UpdateAlbum)
+			r.Post("/{id}/merge", h.MergeAlbums)
+			r.Post("/{id}/split", h.SplitAlbum)
 			r.Delete("/{id}", h.DeleteAlbum)
 			r.Get("/{id}/image", h.GetAlbumImage)
 		})
@@ -155,6 +208,7 @@ func main() {
 			r.Delete("/{id}", h.DeletePlaylist)
 			r.Post("/{id}/clear", h.ClearPlaylist)
 			r.Post("/{id}/enqueue", h.EnqueuePlaylistTrack)
+			r.Post("/{id}/prewarm", h.PrewarmPlaylistTranscodes)
 			r.Route("/{id}/tracks", func(r chi.Router) {
 				r.Get("/", h.ListPlaylistTracks)
 				r.Post("/", h.AddPlaylistTrack)
@@ -165,8 +219,25 @@ func main() {
 	})
 
 	addr := ":8080"
-	log.Printf("Listening on http://0.0.0.0%s (db=%s)\n", addr, dbPath)
-	log.Fatal(http.ListenAndServe(addr, r))
+	srv := &http.Server{Addr: addr, Handler: r}
+	stop, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	go func() {
+		log.Printf("Listening on http://0.0.0.0%s (db=%s)\n", addr, dbPath)
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-stop.Done()
+	log.Printf("shutting down")
+	ctx, done := context.WithTimeout(context.Background(), 10*time.Second)
+	defer done()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("http shutdown: %v", err)
+	}
+	// Running scans stop between files; recovery picks them up on the next start.
+	s.StopScans(ctx)
 }
 
 func getenv(key, fallback string) string {
@@ -176,6 +247,30 @@ func getenv(key, fallback string) string {
 	return fallback
 }
 
+func getenvInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Fatalf("invalid %s: %q", key, v)
+	}
+	return n
+}
+
+func getenvBool(key string, fallback bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Fatalf("invalid %s: %q", key, v)
+	}
+	return b
+}
+
 func requireFFmpeg() {
 	if _, err := exec.LookPath("ffmpeg"); err != nil {
 		log.Panic("ffmpeg not installed or not on PATH")
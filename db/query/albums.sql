@@ -33,11 +33,13 @@ ON CONFLICT(artist_id, title) DO UPDATE SET
   deleted_at = NULL
 RETURNING *;
 
--- List albums (optionally include unavailable folders)
+-- List albums (optionally include unavailable folders), filtered by decade and sorted by title/year/artist/added/duration
+-- total_count is the number of matches before LIMIT/OFFSET; LIMIT -1 means no limit.
 -- name: ListAlbumsWithArtist :many
 SELECT
   sqlc.embed(a),
-  sqlc.embed(ar)
+  sqlc.embed(ar),
+  COUNT(*) OVER () AS total_count
 FROM albums a
 LEFT JOIN artists ar ON ar.id = a.artist_id
 WHERE a.deleted_at IS NULL
@@ -54,7 +56,21 @@ WHERE a.deleted_at IS NULL
         AND t.album_id = a.id
     )
   )
-ORDER BY a.title;
+  AND (sqlc.narg('decade') IS NULL OR a.year BETWEEN sqlc.narg('decade') AND sqlc.narg('decade') + 9)
+ORDER BY
+  CASE WHEN sqlc.arg('sort') = 'year' THEN a.year IS NULL END,
+  CASE WHEN sqlc.arg('sort') = 'year' AND sqlc.arg('order') = 'asc' THEN a.year END ASC,
+  CASE WHEN sqlc.arg('sort') = 'year' AND sqlc.arg('order') = 'desc' THEN a.year END DESC,
+  CASE WHEN sqlc.arg('sort') = 'artist' AND sqlc.arg('order') = 'asc' THEN ar.name END ASC,
+  CASE WHEN sqlc.arg('sort') = 'artist' AND sqlc.arg('order') = 'desc' THEN ar.name END DESC,
+  CASE WHEN sqlc.arg('sort') = 'added' AND sqlc.arg('order') = 'asc' THEN a.created_at END ASC,
+  CASE WHEN sqlc.arg('sort') = 'added' AND sqlc.arg('order') = 'desc' THEN a.created_at END DESC,
+  CASE WHEN sqlc.arg('sort') = 'duration' AND sqlc.arg('order') = 'asc' THEN a.duration_seconds END ASC,
+  CASE WHEN sqlc.arg('sort') = 'duration' AND sqlc.arg('order') = 'desc' THEN a.duration_seconds END DESC,
+  CASE WHEN sqlc.arg('order') = 'desc' THEN a.title END DESC,
+  a.title,
+  a.id
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
 
 -- Update album title/artist
 -- name: UpdateAlbum :one
@@ -71,3 +87,109 @@ SET deleted_at = CURRENT_TIMESTAMP
 WHERE id = ?
   AND deleted_at IS NULL
 RETURNING *;
+
+-- Soft delete one album if it has no live tracks left. Returns its artist when it
+-- was deleted, since that artist may now be orphaned too.
+-- name: SoftDeleteOrphanedAlbumByID :many
+UPDATE albums
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE id = ?
+  AND deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  )
+RETURNING artist_id;
+
+-- Soft delete albums left without any live tracks
+-- name: SoftDeleteOrphanedAlbums :execrows
+UPDATE albums
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  );
+
+-- Recompute a live album's rolled-up fields from its live tracks, or every live
+-- album's when id is NULL
+-- name: RefreshAlbumRollups :exec
+UPDATE albums
+SET compilation = EXISTS (
+    SELECT 1
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.compilation = 1
+      AND t.deleted_at IS NULL
+  ),
+  year = (
+    SELECT MIN(t.year)
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  ),
+  release_date = (
+    SELECT MIN(t.release_date)
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  ),
+  genre = (
+    SELECT t.genre
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+      AND t.genre IS NOT NULL
+    GROUP BY t.genre
+    ORDER BY COUNT(*) DESC, t.genre
+    LIMIT 1
+  ),
+  track_count = (
+    SELECT COUNT(*)
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  ),
+  disc_count = (
+    SELECT MAX(COALESCE(MAX(t.disc_total), 0), COUNT(DISTINCT COALESCE(t.disc_number, 1)))
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  ),
+  duration_seconds = (
+    SELECT COALESCE(SUM(t.duration_seconds), 0)
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  ),
+  -- Album loudness is the duration-weighted energy mean of the track loudnesses, and
+  -- only set once every live track has been analyzed. Gain is relative to -18 LUFS.
+  loudness_lufs = (
+    SELECT CASE WHEN COUNT(*) = COUNT(t.loudness_lufs) THEN
+      round(10 * log10(SUM(pow(10, t.loudness_lufs / 10) * COALESCE(t.duration_seconds, 1)) / SUM(COALESCE(t.duration_seconds, 1))), 2)
+    END
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  ),
+  peak_dbtp = (
+    SELECT CASE WHEN COUNT(*) = COUNT(t.peak_dbtp) THEN MAX(t.peak_dbtp) END
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  ),
+  replay_gain_db = (
+    SELECT CASE WHEN COUNT(*) = COUNT(t.loudness_lufs) THEN
+      round(-18 - 10 * log10(SUM(pow(10, t.loudness_lufs / 10) * COALESCE(t.duration_seconds, 1)) / SUM(COALESCE(t.duration_seconds, 1))), 2)
+    END
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  )
+WHERE deleted_at IS NULL
+  AND (sqlc.narg('id') IS NULL OR id = sqlc.narg('id'));
+
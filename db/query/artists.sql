@@ -14,13 +14,41 @@ ON CONFLICT(name) DO UPDATE SET
   deleted_at = NULL
 RETURNING *;
 
--- List artists (optional prefix filter)
+-- List artists (optional prefix filter; compilation-only artists only when include_compilation_only = 1), sorted by name/added
+-- total_count is the number of matches before LIMIT/OFFSET; LIMIT -1 means no limit.
 -- name: ListArtists :many
-SELECT *
+SELECT
+  sqlc.embed(artists),
+  COUNT(*) OVER () AS total_count
 FROM artists
 WHERE deleted_at IS NULL
-  AND (?1 IS NULL OR name LIKE (?1 || '%'))
-ORDER BY name;
+  AND (sqlc.narg('startswith') IS NULL OR name LIKE (sqlc.narg('startswith') || '%'))
+  AND (
+    sqlc.arg('include_compilation_only') = 1
+    OR EXISTS (
+      SELECT 1
+      FROM tracks t
+      LEFT JOIN albums al ON al.id = t.album_id
+      WHERE t.artist_id = artists.id
+        AND t.deleted_at IS NULL
+        AND t.compilation = 0
+        AND COALESCE(al.compilation, 0) = 0
+    )
+    OR EXISTS (
+      SELECT 1
+      FROM albums a
+      WHERE a.artist_id = artists.id
+        AND a.deleted_at IS NULL
+        AND a.compilation = 0
+    )
+  )
+ORDER BY
+  CASE WHEN sqlc.arg('sort') = 'added' AND sqlc.arg('order') = 'asc' THEN created_at END ASC,
+  CASE WHEN sqlc.arg('sort') = 'added' AND sqlc.arg('order') = 'desc' THEN created_at END DESC,
+  CASE WHEN sqlc.arg('order') = 'desc' THEN name END DESC,
+  name,
+  id
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
 
 -- Update artist name
 -- name: UpdateArtist :one
@@ -37,3 +65,40 @@ SET deleted_at = CURRENT_TIMESTAMP
 WHERE id = ?
   AND deleted_at IS NULL
 RETURNING *;
+
+-- Soft delete one artist if it has no live tracks or albums left
+-- name: SoftDeleteOrphanedArtistByID :execrows
+UPDATE artists
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE id = ?
+  AND deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM tracks t
+    WHERE t.artist_id = artists.id
+      AND t.deleted_at IS NULL
+  )
+  AND NOT EXISTS (
+    SELECT 1
+    FROM albums a
+    WHERE a.artist_id = artists.id
+      AND a.deleted_at IS NULL
+  );
+
+-- Soft delete artists left without any live tracks or albums
+-- name: SoftDeleteOrphanedArtists :execrows
+UPDATE artists
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM tracks t
+    WHERE t.artist_id = artists.id
+      AND t.deleted_at IS NULL
+  )
+  AND NOT EXISTS (
+    SELECT 1
+    FROM albums a
+    WHERE a.artist_id = artists.id
+      AND a.deleted_at IS NULL
+  );
@@ -20,6 +20,27 @@ SET deleted_at = CURRENT_TIMESTAMP
 WHERE id = ? AND deleted_at IS NULL
 RETURNING *;
 
+-- Toggle filesystem watching for a folder
+-- name: SetFolderWatch :one
+UPDATE folders
+SET watch = ?
+WHERE id = ? AND deleted_at IS NULL
+RETURNING *;
+
+-- name: ListWatchedFolders :many
+SELECT *
+FROM folders
+WHERE deleted_at IS NULL
+  AND watch = 1
+ORDER BY path;
+
+-- Set or clear the rescan schedule for a folder
+-- name: SetFolderScanSchedule :one
+UPDATE folders
+SET scan_schedule = ?
+WHERE id = ? AND deleted_at IS NULL
+RETURNING *;
+
 -- name: SetFolderAvailability :exec
 UPDATE folders
 SET
@@ -55,6 +76,16 @@ SET
   available = 0
 WHERE id = ?;
 
+-- Reconcile folders left "running" by a crash or restart
+-- name: InterruptRunningFolderScans :many
+UPDATE folders
+SET
+  last_scan_status = 'error',
+  last_scan_error = ?
+WHERE deleted_at IS NULL
+  AND last_scan_status = 'running'
+RETURNING id;
+
 -- name: FinishFolderScanError :exec
 UPDATE folders
 SET
@@ -78,23 +78,40 @@ WHERE playlist_id = ?
   AND track_id = ?
   AND deleted_at IS NULL;
 
--- List playlist tracks with track metadata
+-- List playlist tracks with track metadata, sorted by position/title/artist/album/duration/added
+-- total_count is the number of matches before LIMIT/OFFSET; LIMIT -1 means no limit.
 -- name: ListPlaylistTracks :many
 SELECT
   sqlc.embed(pt),
   sqlc.embed(t),
   sqlc.embed(ar),
   sqlc.embed(al),
-  sqlc.embed(al_ar)
+  sqlc.embed(al_ar),
+  COUNT(*) OVER () AS total_count
 FROM playlist_tracks pt
 JOIN tracks t ON t.id = pt.track_id
 LEFT JOIN artists ar ON ar.id = t.artist_id
 LEFT JOIN albums al ON al.id = t.album_id
 LEFT JOIN artists al_ar ON al_ar.id = al.artist_id
-WHERE pt.playlist_id = ?
+WHERE pt.playlist_id = sqlc.arg('playlist_id')
   AND pt.deleted_at IS NULL
   AND t.deleted_at IS NULL
-ORDER BY pt.position;
+ORDER BY
+  CASE WHEN sqlc.arg('sort') = 'title' AND sqlc.arg('order') = 'asc' THEN t.title END ASC,
+  CASE WHEN sqlc.arg('sort') = 'title' AND sqlc.arg('order') = 'desc' THEN t.title END DESC,
+  CASE WHEN sqlc.arg('sort') = 'artist' AND sqlc.arg('order') = 'asc' THEN ar.name END ASC,
+  CASE WHEN sqlc.arg('sort') = 'artist' AND sqlc.arg('order') = 'desc' THEN ar.name END DESC,
+  CASE WHEN sqlc.arg('sort') = 'album' AND sqlc.arg('order') = 'asc' THEN al.title END ASC,
+  CASE WHEN sqlc.arg('sort') = 'album' AND sqlc.arg('order') = 'desc' THEN al.title END DESC,
+  CASE WHEN sqlc.arg('sort') = 'duration' THEN t.duration_seconds IS NULL END,
+  CASE WHEN sqlc.arg('sort') = 'duration' AND sqlc.arg('order') = 'asc' THEN t.duration_seconds END ASC,
+  CASE WHEN sqlc.arg('sort') = 'duration' AND sqlc.arg('order') = 'desc' THEN t.duration_seconds END DESC,
+  CASE WHEN sqlc.arg('sort') = 'added' AND sqlc.arg('order') = 'asc' THEN pt.created_at END ASC,
+  CASE WHEN sqlc.arg('sort') = 'added' AND sqlc.arg('order') = 'desc' THEN pt.created_at END DESC,
+  CASE WHEN sqlc.arg('order') = 'desc' THEN pt.position END DESC,
+  pt.position,
+  pt.id
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
 
 -- Delete a track from playlist
 -- name: DeletePlaylistTrack :execrows
@@ -1,6 +1,6 @@
--- Create playlist
+-- Create playlist (rules is the JSON rule set of a smart playlist)
 -- name: CreatePlaylist :one
-INSERT INTO playlists (name) VALUES (?)
+INSERT INTO playlists (name, type, rules) VALUES (?, ?, ?)
 RETURNING *;
 
 -- List playlists (excluding deleted)
@@ -24,10 +24,10 @@ SET deleted_at = CURRENT_TIMESTAMP
 WHERE id = ?
   AND deleted_at IS NULL;
 
--- Update playlist name
+-- Update playlist name and smart playlist rules
 -- name: UpdatePlaylist :one
 UPDATE playlists
-SET name = ?
+SET name = ?, rules = ?
 WHERE id = ?
   AND deleted_at IS NULL
 RETURNING *;
@@ -0,0 +1,121 @@
+-- Record a play; the plays_update_track trigger updates the track's counters.
+-- played_at defaults to now for live plays.
+-- name: CreatePlay :one
+INSERT INTO plays (track_id, played_at, position_seconds, completed, counted, source)
+VALUES (
+  sqlc.arg('track_id'),
+  COALESCE(sqlc.narg('played_at'), CURRENT_TIMESTAMP),
+  sqlc.narg('position_seconds'),
+  sqlc.arg('completed'),
+  sqlc.arg('counted'),
+  sqlc.arg('source')
+)
+RETURNING *;
+
+-- Whether the track has a play at or after since (used to de-duplicate inferred plays)
+-- name: HasPlaySince :one
+SELECT EXISTS (
+  SELECT 1
+  FROM plays
+  WHERE track_id = sqlc.arg('track_id')
+    AND played_at >= sqlc.arg('since')
+) AS found;
+
+-- Turn the track's latest inferred play in [since, until] into the client's report of
+-- it; the plays_update_track_counted trigger adjusts play_count. No row when there is none.
+-- name: UpgradeStreamPlay :one
+UPDATE plays
+SET position_seconds = sqlc.narg('position_seconds'),
+    completed = sqlc.arg('completed'),
+    counted = sqlc.arg('counted'),
+    source = 'client'
+WHERE id = (
+  SELECT id
+  FROM plays
+  WHERE track_id = sqlc.arg('track_id')
+    AND source = 'stream'
+    AND played_at >= sqlc.arg('since')
+    AND played_at <= sqlc.arg('until')
+  ORDER BY played_at DESC, id DESC
+  LIMIT 1
+)
+RETURNING *;
+
+-- The play log in played_at order (newest first unless order is 'asc'), with each play's
+-- track. NULL filters are ignored; played_to is exclusive. total_count is the number of
+-- plays before LIMIT/OFFSET.
+-- name: ListPlays :many
+SELECT
+  sqlc.embed(p),
+  sqlc.embed(t),
+  ar.name AS artist_name,
+  al.artist_id AS album_artist_id,
+  al.title AS album_title,
+  al.image_path AS album_image_path,
+  COUNT(*) OVER () AS total_count
+FROM plays p
+JOIN tracks t ON t.id = p.track_id
+LEFT JOIN artists ar ON ar.id = t.artist_id
+LEFT JOIN albums al ON al.id = t.album_id
+WHERE (sqlc.narg('track_id') IS NULL OR p.track_id = sqlc.narg('track_id'))
+  AND (sqlc.narg('played_from') IS NULL OR p.played_at >= sqlc.narg('played_from'))
+  AND (sqlc.narg('played_to') IS NULL OR p.played_at < sqlc.narg('played_to'))
+ORDER BY
+  CASE WHEN sqlc.arg('order') = 'asc' THEN p.played_at END ASC,
+  CASE WHEN sqlc.arg('order') = 'asc' THEN p.id END ASC,
+  p.played_at DESC,
+  p.id DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- Live tracks by counted plays in [played_from, played_to), most played first unless
+-- order is 'asc'; ties go to the most recently played.
+-- NULL bounds are open. total_count is the number of tracks before LIMIT/OFFSET.
+-- name: ListMostPlayedTracks :many
+SELECT
+  sqlc.embed(t),
+  ar.name AS artist_name,
+  al.artist_id AS album_artist_id,
+  al.title AS album_title,
+  al.image_path AS album_image_path,
+  s.plays,
+  COUNT(*) OVER () AS total_count
+FROM (
+  SELECT
+    track_id,
+    CAST(SUM(counted) AS INTEGER) AS plays,
+    MAX(played_at) AS last_played_at
+  FROM plays
+  WHERE (sqlc.narg('played_from') IS NULL OR played_at >= sqlc.narg('played_from'))
+    AND (sqlc.narg('played_to') IS NULL OR played_at < sqlc.narg('played_to'))
+  GROUP BY track_id
+  HAVING SUM(counted) > 0
+) s
+JOIN tracks t ON t.id = s.track_id
+JOIN folders f ON f.id = t.folder_id
+LEFT JOIN artists ar ON ar.id = t.artist_id
+LEFT JOIN albums al ON al.id = t.album_id
+WHERE t.deleted_at IS NULL
+  AND f.deleted_at IS NULL
+ORDER BY
+  CASE WHEN sqlc.arg('order') = 'asc' THEN s.plays END ASC,
+  s.plays DESC,
+  s.last_played_at DESC,
+  t.id
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- Listening per UTC day in [played_from, played_to). skipped counts reported plays below
+-- the scrobble threshold (inferred plays are neither); seconds is the reported position,
+-- else the track duration. Days without plays are absent.
+-- name: ListDailyPlayStats :many
+SELECT
+  CAST(date(p.played_at) AS TEXT) AS day,
+  CAST(SUM(p.counted) AS INTEGER) AS plays,
+  CAST(SUM(p.counted = 0 AND p.source = 'client') AS INTEGER) AS skipped,
+  COUNT(DISTINCT p.track_id) AS tracks,
+  CAST(SUM(COALESCE(p.position_seconds, t.duration_seconds, 0)) AS INTEGER) AS seconds
+FROM plays p
+JOIN tracks t ON t.id = p.track_id
+WHERE p.played_at >= sqlc.arg('played_from')
+  AND p.played_at < sqlc.arg('played_to')
+GROUP BY date(p.played_at)
+ORDER BY day;
@@ -0,0 +1,57 @@
+-- name: CreateScanRun :one
+INSERT INTO scan_runs (folder_id, full_rescan, triggered_by)
+VALUES (?, ?, ?)
+RETURNING *;
+
+-- name: FinishScanRun :exec
+UPDATE scan_runs
+SET
+  status = ?,
+  error = ?,
+  files_seen = ?,
+  tracks_added = ?,
+  tracks_updated = ?,
+  tracks_removed = ?,
+  files_failed = ?,
+  finished_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
+-- name: GetScanRun :one
+SELECT *
+FROM scan_runs
+WHERE id = ?;
+
+-- name: GetLatestScanRunForFolder :one
+SELECT *
+FROM scan_runs
+WHERE folder_id = ?
+ORDER BY id DESC
+LIMIT 1;
+
+-- Scan history for a folder, newest first
+-- name: ListScanRunsForFolder :many
+SELECT *
+FROM scan_runs
+WHERE folder_id = ?
+ORDER BY id DESC
+LIMIT ?;
+
+-- Mark runs the process never finished; returns them so they can be restarted
+-- name: InterruptRunningScanRuns :many
+UPDATE scan_runs
+SET
+  status = 'interrupted',
+  error = sqlc.arg('message'),
+  finished_at = CURRENT_TIMESTAMP
+WHERE status = 'running'
+RETURNING *;
+
+-- name: CreateScanRunError :exec
+INSERT INTO scan_run_errors (scan_run_id, rel_path, error)
+VALUES (?, ?, ?);
+
+-- name: ListScanRunErrors :many
+SELECT *
+FROM scan_run_errors
+WHERE scan_run_id = ?
+ORDER BY id;
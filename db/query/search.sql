@@ -0,0 +1,65 @@
+-- Full-text search. Matches are wrapped in char(2)/char(3) so the handler can escape
+-- the text before turning them into <mark> tags. Lower scores are better matches.
+
+-- Search track title, artist, album and genre (title weighs most)
+-- name: SearchTracks :many
+SELECT
+  t.id,
+  CAST(highlight(tracks_fts, 0, char(2), char(3)) AS TEXT) AS title,
+  CAST(snippet(tracks_fts, -1, char(2), char(3), '…', 12) AS TEXT) AS snippet,
+  ar.name AS artist_name,
+  al.title AS album_title,
+  CAST(bm25(tracks_fts, 10.0, 5.0, 3.0, 1.0) AS REAL) AS score
+FROM tracks_fts
+JOIN tracks t ON t.id = tracks_fts.rowid
+JOIN folders f ON f.id = t.folder_id
+LEFT JOIN artists ar ON ar.id = t.artist_id
+LEFT JOIN albums al ON al.id = t.album_id
+WHERE tracks_fts MATCH sqlc.arg('query')
+  AND t.deleted_at IS NULL
+  AND f.deleted_at IS NULL
+  AND (sqlc.narg('include_unavailable') = 1 OR f.available = 1)
+ORDER BY score
+LIMIT sqlc.arg('limit');
+
+-- Search album titles
+-- name: SearchAlbums :many
+SELECT
+  al.id,
+  CAST(highlight(albums_fts, 0, char(2), char(3)) AS TEXT) AS title,
+  ar.name AS artist_name,
+  CAST(bm25(albums_fts) AS REAL) AS score
+FROM albums_fts
+JOIN albums al ON al.id = albums_fts.rowid
+JOIN artists ar ON ar.id = al.artist_id
+WHERE albums_fts MATCH sqlc.arg('query')
+  AND al.deleted_at IS NULL
+ORDER BY score
+LIMIT sqlc.arg('limit');
+
+-- Search artist names
+-- name: SearchArtists :many
+SELECT
+  ar.id,
+  CAST(highlight(artists_fts, 0, char(2), char(3)) AS TEXT) AS name,
+  CAST(bm25(artists_fts) AS REAL) AS score
+FROM artists_fts
+JOIN artists ar ON ar.id = artists_fts.rowid
+WHERE artists_fts MATCH sqlc.arg('query')
+  AND ar.deleted_at IS NULL
+ORDER BY score
+LIMIT sqlc.arg('limit');
+
+-- Search journal entry titles and bodies (title weighs most)
+-- name: SearchJournalEntries :many
+SELECT
+  je.id,
+  CAST(highlight(journal_entries_fts, 0, char(2), char(3)) AS TEXT) AS title,
+  CAST(snippet(journal_entries_fts, 1, char(2), char(3), '…', 24) AS TEXT) AS snippet,
+  je.journal_date,
+  CAST(bm25(journal_entries_fts, 5.0, 1.0) AS REAL) AS score
+FROM journal_entries_fts
+JOIN journal_entries je ON je.id = journal_entries_fts.rowid
+WHERE journal_entries_fts MATCH sqlc.arg('query')
+ORDER BY score
+LIMIT sqlc.arg('limit');
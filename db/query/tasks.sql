@@ -20,32 +20,56 @@ INSERT INTO journal_entries (
 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 RETURNING *;
 
+-- List journal entries filtered by date prefix, type, statuses and tags (JSON arrays), sorted by date/title/status/scheduled/deadline.
+-- Entries on the same day stay in file order. total_count is the number of matches before LIMIT/OFFSET; LIMIT -1 means no limit.
 -- name: ListJournalEntries :many
-SELECT *
+SELECT
+  sqlc.embed(journal_entries),
+  COUNT(*) OVER () AS total_count
 FROM journal_entries
 WHERE (
-    ?1 IS NULL
-    OR journal_date LIKE ?1
-    OR scheduled_at LIKE ?1
-    OR deadline_at LIKE ?1
+    sqlc.narg('date') IS NULL
+    OR journal_date LIKE sqlc.narg('date')
+    OR scheduled_at LIKE sqlc.narg('date')
+    OR deadline_at LIKE sqlc.narg('date')
   )
   AND (
-    ?2 IS NULL
-    OR type = ?2
+    sqlc.narg('type') IS NULL
+    OR type = sqlc.narg('type')
   )
   AND (
-    ?3 IS NULL
-    OR status IN (SELECT value FROM json_each(?3))
+    sqlc.narg('statuses') IS NULL
+    OR status IN (SELECT value FROM json_each(sqlc.narg('statuses')))
   )
   AND (
-    ?4 IS NULL
+    sqlc.narg('tags') IS NULL
     OR EXISTS (
       SELECT 1
       FROM json_each(journal_entries.tags)
-      WHERE LOWER(value) IN (SELECT LOWER(value) FROM json_each(?4))
+      WHERE LOWER(value) IN (SELECT LOWER(value) FROM json_each(sqlc.narg('tags')))
     )
   )
-ORDER BY year DESC, month DESC, day DESC, position ASC;
+ORDER BY
+  CASE WHEN sqlc.arg('sort') = 'title' AND sqlc.arg('order') = 'asc' THEN title END ASC,
+  CASE WHEN sqlc.arg('sort') = 'title' AND sqlc.arg('order') = 'desc' THEN title END DESC,
+  CASE WHEN sqlc.arg('sort') = 'status' THEN status IS NULL END,
+  CASE WHEN sqlc.arg('sort') = 'status' AND sqlc.arg('order') = 'asc' THEN status END ASC,
+  CASE WHEN sqlc.arg('sort') = 'status' AND sqlc.arg('order') = 'desc' THEN status END DESC,
+  CASE WHEN sqlc.arg('sort') = 'scheduled' THEN scheduled_at IS NULL END,
+  CASE WHEN sqlc.arg('sort') = 'scheduled' AND sqlc.arg('order') = 'asc' THEN scheduled_at END ASC,
+  CASE WHEN sqlc.arg('sort') = 'scheduled' AND sqlc.arg('order') = 'desc' THEN scheduled_at END DESC,
+  CASE WHEN sqlc.arg('sort') = 'deadline' THEN deadline_at IS NULL END,
+  CASE WHEN sqlc.arg('sort') = 'deadline' AND sqlc.arg('order') = 'asc' THEN deadline_at END ASC,
+  CASE WHEN sqlc.arg('sort') = 'deadline' AND sqlc.arg('order') = 'desc' THEN deadline_at END DESC,
+  CASE WHEN sqlc.arg('order') = 'desc' THEN year END DESC,
+  CASE WHEN sqlc.arg('order') = 'desc' THEN month END DESC,
+  CASE WHEN sqlc.arg('order') = 'desc' THEN day END DESC,
+  year,
+  month,
+  day,
+  position,
+  id
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
 
 -- name: ListJournalEntryTags :many
 SELECT tags
@@ -13,7 +13,12 @@ ON CONFLICT(folder_id, rel_path) DO UPDATE SET
   size_bytes    = excluded.size_bytes,
   last_modified = excluded.last_modified,
   last_seen_at  = CURRENT_TIMESTAMP,
-  deleted_at    = NULL
+  deleted_at    = NULL,
+  -- A changed file needs analyzing again.
+  loudness_lufs        = CASE WHEN tracks.size_bytes = excluded.size_bytes AND tracks.last_modified = excluded.last_modified THEN tracks.loudness_lufs END,
+  peak_dbtp            = CASE WHEN tracks.size_bytes = excluded.size_bytes AND tracks.last_modified = excluded.last_modified THEN tracks.peak_dbtp END,
+  replay_gain_db       = CASE WHEN tracks.size_bytes = excluded.size_bytes AND tracks.last_modified = excluded.last_modified THEN tracks.replay_gain_db END,
+  loudness_analyzed_at = CASE WHEN tracks.size_bytes = excluded.size_bytes AND tracks.last_modified = excluded.last_modified THEN tracks.loudness_analyzed_at END
 RETURNING *;
 
 -- List tracks for a folder (excluding deleted)
@@ -23,6 +28,60 @@ FROM tracks
 WHERE folder_id = ? AND deleted_at IS NULL
 ORDER BY rel_path;
 
+-- File facts for incremental scans (excluding deleted)
+-- name: ListTrackFileStatsForFolder :many
+SELECT id, rel_path, size_bytes, last_modified
+FROM tracks
+WHERE folder_id = ?
+  AND deleted_at IS NULL;
+
+-- Live tracks in a folder that the loudness pass has not analyzed, grouped by album
+-- name: ListTracksNeedingLoudnessForFolder :many
+SELECT id, rel_path, album_id
+FROM tracks
+WHERE folder_id = ?
+  AND deleted_at IS NULL
+  AND loudness_analyzed_at IS NULL
+ORDER BY album_id, rel_path;
+
+-- Store a loudness analysis; NULL values record a file that could not be analyzed
+-- name: UpdateTrackLoudness :exec
+UPDATE tracks
+SET loudness_lufs = ?,
+  peak_dbtp = ?,
+  replay_gain_db = ?,
+  loudness_analyzed_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
+-- Store the audio properties and gapless info read by ffprobe
+-- name: UpdateTrackAudio :exec
+UPDATE tracks
+SET duration_ms = ?,
+  sample_rate = ?,
+  bit_depth = ?,
+  channels = ?,
+  codec = ?,
+  lossless = ?,
+  bitrate = ?,
+  encoder_delay = ?,
+  encoder_padding = ?,
+  sample_count = ?
+WHERE id = ?;
+
+-- Look up a live track by its path within a folder
+-- name: GetTrackIDByRelPath :one
+SELECT id
+FROM tracks
+WHERE folder_id = ?
+  AND rel_path = ?
+  AND deleted_at IS NULL;
+
+-- Bump last_seen_at for a file that is unchanged since the last scan
+-- name: TouchTrackSeen :exec
+UPDATE tracks
+SET last_seen_at = CURRENT_TIMESTAMP
+WHERE id = ?;
+
 -- Get a single track by ID (excluding deleted)
 -- name: GetTrackByID :one
 SELECT *
@@ -46,23 +105,27 @@ WHERE t.id = ?
 
 -- Mark tracks missing if not seen during this scan pass.
 -- Pass scan_start_time from StartFolderScan (folders.last_scan_at returned value).
--- name: MarkMissingTracksForFolder :exec
+-- Bind scan_started_at as UTC text; datetime() keeps it comparable with CURRENT_TIMESTAMP values.
+-- Returns the album of each track marked, so only those albums need their rollups refreshed.
+-- name: MarkMissingTracksForFolder :many
 UPDATE tracks
 SET deleted_at = CURRENT_TIMESTAMP
-WHERE folder_id = ?
+WHERE folder_id = sqlc.arg('folder_id')
   AND deleted_at IS NULL
-  AND last_seen_at < ?;
+  AND last_seen_at < datetime(sqlc.arg('scan_started_at'))
+RETURNING album_id;
 
--- Default: list all playable tracks (roots currently available)
--- name: ListPlayableTracks :many
-SELECT t.*
-FROM tracks t
-JOIN folders f ON f.id = t.folder_id
-WHERE t.deleted_at IS NULL
-  AND f.deleted_at IS NULL
-  AND (sqlc.narg('include_unavailable') = 1 OR f.available = 1)
-  AND (sqlc.narg('prefix') IS NULL OR t.filename LIKE (sqlc.narg('prefix') || '%'))
-ORDER BY t.rel_path;
+-- Soft delete a removed file, or every track under a removed directory. The prefix is
+-- compared exactly (LIKE would ignore case and treat _ and % as wildcards). Returns the
+-- album of each track removed.
+-- name: SoftDeleteTracksUnderPath :many
+UPDATE tracks
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE folder_id = sqlc.arg('folder_id')
+  AND deleted_at IS NULL
+  AND (rel_path = sqlc.arg('rel_path')
+    OR substr(rel_path, 1, length(sqlc.arg('rel_path')) + 1) = sqlc.arg('rel_path') || '/')
+RETURNING album_id;
 
 -- Include unavailable roots too (for admin/debug UI)
 -- name: ListAllIndexedTracks :many
@@ -74,82 +137,6 @@ WHERE t.deleted_at IS NULL
   AND (sqlc.narg('prefix') IS NULL OR t.filename LIKE (sqlc.narg('prefix') || '%'))
 ORDER BY t.rel_path;
 
--- Default: list all playable tracks with artist/album info (roots currently available)
--- name: ListPlayableTracksWithJoins :many
-SELECT
-  sqlc.embed(t),
-  sqlc.embed(ar),
-  sqlc.embed(al),
-  sqlc.embed(al_ar)
-FROM tracks t
-JOIN folders f ON f.id = t.folder_id
-LEFT JOIN artists ar ON ar.id = t.artist_id
-LEFT JOIN albums al ON al.id = t.album_id
-LEFT JOIN artists al_ar ON al_ar.id = al.artist_id
-WHERE t.deleted_at IS NULL
-  AND f.deleted_at IS NULL
-  AND (sqlc.narg('include_unavailable') = 1 OR f.available = 1)
-  AND (sqlc.narg('prefix') IS NULL OR t.filename LIKE (sqlc.narg('prefix') || '%'))
-ORDER BY t.rel_path;
-
--- List playable tracks for an album (roots currently available)
--- name: ListPlayableTracksForAlbum :many
-SELECT
-  sqlc.embed(t),
-  sqlc.embed(ar),
-  sqlc.embed(al),
-  sqlc.embed(al_ar)
-FROM tracks t
-JOIN folders f ON f.id = t.folder_id
-LEFT JOIN artists ar ON ar.id = t.artist_id
-LEFT JOIN albums al ON al.id = t.album_id
-LEFT JOIN artists al_ar ON al_ar.id = al.artist_id
-WHERE t.deleted_at IS NULL
-  AND f.deleted_at IS NULL
-  AND (?1 = 1 OR f.available = 1)
-  AND t.album_id = ?2
-  AND (?3 IS NULL OR t.filename LIKE (?3 || '%'))
-ORDER BY t.rel_path;
-
--- List playable tracks for an album + artist (roots currently available)
--- name: ListPlayableTracksForAlbumArtist :many
-SELECT
-  sqlc.embed(t),
-  sqlc.embed(ar),
-  sqlc.embed(al),
-  sqlc.embed(al_ar)
-FROM tracks t
-JOIN folders f ON f.id = t.folder_id
-LEFT JOIN artists ar ON ar.id = t.artist_id
-LEFT JOIN albums al ON al.id = t.album_id
-LEFT JOIN artists al_ar ON al_ar.id = al.artist_id
-WHERE t.deleted_at IS NULL
-  AND f.deleted_at IS NULL
-  AND (?1 = 1 OR f.available = 1)
-  AND t.album_id = ?2
-  AND t.artist_id = ?3
-  AND (?4 IS NULL OR t.filename LIKE (?4 || '%'))
-ORDER BY t.rel_path;
-
--- List playable tracks for an artist (roots currently available)
--- name: ListPlayableTracksForArtist :many
-SELECT
-  sqlc.embed(t),
-  sqlc.embed(ar),
-  sqlc.embed(al),
-  sqlc.embed(al_ar)
-FROM tracks t
-JOIN folders f ON f.id = t.folder_id
-LEFT JOIN artists ar ON ar.id = t.artist_id
-LEFT JOIN albums al ON al.id = t.album_id
-LEFT JOIN artists al_ar ON al_ar.id = al.artist_id
-WHERE t.deleted_at IS NULL
-  AND f.deleted_at IS NULL
-  AND (?1 = 1 OR f.available = 1)
-  AND t.artist_id = ?2
-  AND (?3 IS NULL OR t.filename LIKE (?3 || '%'))
-ORDER BY t.rel_path;
-
 -- Include unavailable roots too (for admin/debug UI) with artist/album info
 -- name: ListAllIndexedTracksWithJoins :many
 SELECT
@@ -166,43 +153,6 @@ WHERE t.deleted_at IS NULL
   AND f.deleted_at IS NULL
 ORDER BY t.rel_path;
 
--- List playable tracks for an album without joins (roots currently available)
--- name: ListPlayableTracksForAlbumBase :many
-SELECT t.*
-FROM tracks t
-JOIN folders f ON f.id = t.folder_id
-WHERE t.deleted_at IS NULL
-  AND f.deleted_at IS NULL
-  AND (?1 = 1 OR f.available = 1)
-  AND t.album_id = ?2
-  AND (?3 IS NULL OR t.filename LIKE (?3 || '%'))
-ORDER BY t.rel_path;
-
--- List playable tracks for an album + artist without joins (roots currently available)
--- name: ListPlayableTracksForAlbumArtistBase :many
-SELECT t.*
-FROM tracks t
-JOIN folders f ON f.id = t.folder_id
-WHERE t.deleted_at IS NULL
-  AND f.deleted_at IS NULL
-  AND (?1 = 1 OR f.available = 1)
-  AND t.album_id = ?2
-  AND t.artist_id = ?3
-  AND (?4 IS NULL OR t.filename LIKE (?4 || '%'))
-ORDER BY t.rel_path;
-
--- List playable tracks for an artist without joins (roots currently available)
--- name: ListPlayableTracksForArtistBase :many
-SELECT t.*
-FROM tracks t
-JOIN folders f ON f.id = t.folder_id
-WHERE t.deleted_at IS NULL
-  AND f.deleted_at IS NULL
-  AND (?1 = 1 OR f.available = 1)
-  AND t.artist_id = ?2
-  AND (?3 IS NULL OR t.filename LIKE (?3 || '%'))
-ORDER BY t.rel_path;
-
 -- Optional: get absolute path pieces for playback (folder path + rel path)
 -- name: GetPlayableTrackPathPartsByID :one
 SELECT
@@ -227,7 +177,10 @@ RETURNING *;
 -- Update track metadata from tags
 -- name: UpdateTrackMetadata :one
 UPDATE tracks
-SET artist_id = ?, album_id = ?, title = ?, genre = ?, year = ?, image_path = COALESCE(?, image_path), duration_seconds = COALESCE(?, duration_seconds)
+SET artist_id = ?, album_id = ?, title = ?, genre = ?, year = ?, image_path = COALESCE(?, image_path), duration_seconds = COALESCE(?, duration_seconds),
+  track_number = ?, track_total = ?, disc_number = ?, disc_total = ?,
+  album_artist = ?, composer = ?, comment = ?, lyrics = ?, bpm = ?, compilation = ?,
+  release_date = ?, rating = COALESCE(?, rating)
 WHERE id = ?
   AND deleted_at IS NULL
 RETURNING *;
@@ -249,3 +202,189 @@ WHERE album_id = ?
   AND image_path IS NOT NULL
 ORDER BY id
 LIMIT 1;
+
+-- Move every track of an album into another album and pin it there (manual merge)
+-- name: MoveAlbumTracks :execrows
+UPDATE tracks
+SET album_id = sqlc.arg('target_id'),
+    album_pinned = 1
+WHERE album_id = sqlc.arg('source_id');
+
+-- Move one track into another album and pin it there (manual split)
+-- name: MoveTrackToAlbum :execrows
+UPDATE tracks
+SET album_id = sqlc.arg('target_id'),
+    album_pinned = 1
+WHERE id = sqlc.arg('id')
+  AND album_id = sqlc.arg('source_id')
+  AND deleted_at IS NULL;
+
+-- Release a track from a manual merge or split so scans place it by its tags again
+-- name: UnpinTrackAlbum :exec
+UPDATE tracks
+SET album_pinned = 0
+WHERE id = ?;
+
+-- Set the disc number for an album's tracks that have none or are tagged as a
+-- single-disc set ("1/1", or no disc total)
+-- name: SetMissingDiscNumbers :exec
+UPDATE tracks
+SET disc_number = ?,
+    disc_total = NULLIF(disc_total, 1)
+WHERE album_id = ?
+  AND (disc_number IS NULL OR COALESCE(disc_total, 1) <= 1);
+
+-- List an album's live tracks with their folder availability (for tag write-back)
+-- name: ListAlbumTrackFiles :many
+SELECT
+  t.id,
+  f.available
+FROM tracks t
+JOIN folders f ON f.id = t.folder_id
+WHERE t.album_id = ?
+  AND t.deleted_at IS NULL
+  AND f.deleted_at IS NULL
+ORDER BY t.id;
+
+-- List live tracks an artist performs or is album artist of, with folder availability
+-- and which of the artist/album_artist tags name them (for tag write-back)
+-- name: ListArtistTrackFiles :many
+SELECT
+  t.id,
+  f.available,
+  CAST(t.artist_id IS sqlc.arg('artist_id') AS INTEGER) AS performer,
+  CAST(COALESCE(al.artist_id = sqlc.arg('artist_id') AND (t.album_artist IS NOT NULL OR t.artist_id IS NOT sqlc.arg('artist_id')), 0) AS INTEGER) AS album_artist
+FROM tracks t
+JOIN folders f ON f.id = t.folder_id
+LEFT JOIN albums al ON al.id = t.album_id
+WHERE t.deleted_at IS NULL
+  AND f.deleted_at IS NULL
+  AND (t.artist_id = sqlc.arg('artist_id') OR al.artist_id = sqlc.arg('artist_id'))
+ORDER BY t.id;
+
+-- Tracks matching the GET /tracks filters and parsed `q` terms, sorted and paged.
+-- NULL filters are ignored; text is an FTS5 expression over tracks_fts. LIMIT -1 means no limit.
+-- total_count is the number of matches before LIMIT/OFFSET.
+-- name: QueryTracks :many
+SELECT
+  sqlc.embed(t),
+  ar.name AS artist_name,
+  al.artist_id AS album_artist_id,
+  al.title AS album_title,
+  al.image_path AS album_image_path,
+  COUNT(*) OVER () AS total_count
+FROM tracks t
+JOIN folders f ON f.id = t.folder_id
+LEFT JOIN artists ar ON ar.id = t.artist_id
+LEFT JOIN albums al ON al.id = t.album_id
+WHERE t.deleted_at IS NULL
+  AND f.deleted_at IS NULL
+  AND (sqlc.narg('include_unavailable') = 1 OR f.available = 1)
+  AND (sqlc.narg('prefix') IS NULL OR t.filename LIKE (sqlc.narg('prefix') || '%'))
+  AND (sqlc.narg('album_id') IS NULL OR t.album_id = sqlc.narg('album_id'))
+  AND (sqlc.narg('artist_id') IS NULL OR t.artist_id = sqlc.narg('artist_id'))
+  AND (sqlc.narg('text') IS NULL OR t.id IN (SELECT rowid FROM tracks_fts WHERE tracks_fts MATCH sqlc.narg('text')))
+  AND (sqlc.narg('genre') IS NULL OR t.genre = sqlc.narg('genre') COLLATE NOCASE)
+  AND (sqlc.narg('artist') IS NULL OR ar.name = sqlc.narg('artist') COLLATE NOCASE)
+  AND (sqlc.narg('album') IS NULL OR al.title = sqlc.narg('album') COLLATE NOCASE)
+  AND (sqlc.narg('ext') IS NULL OR t.ext = sqlc.narg('ext') COLLATE NOCASE)
+  AND (sqlc.narg('year_min') IS NULL OR t.year >= sqlc.narg('year_min'))
+  AND (sqlc.narg('year_max') IS NULL OR t.year <= sqlc.narg('year_max'))
+  AND (sqlc.narg('rating_min') IS NULL OR t.rating >= sqlc.narg('rating_min'))
+  AND (sqlc.narg('rating_max') IS NULL OR t.rating <= sqlc.narg('rating_max'))
+  AND (sqlc.narg('duration_min') IS NULL OR t.duration_seconds >= sqlc.narg('duration_min'))
+  AND (sqlc.narg('duration_max') IS NULL OR t.duration_seconds <= sqlc.narg('duration_max'))
+  AND (sqlc.narg('bpm_min') IS NULL OR t.bpm >= sqlc.narg('bpm_min'))
+  AND (sqlc.narg('bpm_max') IS NULL OR t.bpm <= sqlc.narg('bpm_max'))
+  AND (sqlc.narg('added_after') IS NULL OR t.created_at >= sqlc.narg('added_after'))
+  AND (sqlc.narg('added_before') IS NULL OR t.created_at <= sqlc.narg('added_before'))
+  AND (sqlc.narg('plays_min') IS NULL OR t.play_count >= sqlc.narg('plays_min'))
+  AND (sqlc.narg('plays_max') IS NULL OR t.play_count <= sqlc.narg('plays_max'))
+  AND (sqlc.narg('played_after') IS NULL OR t.last_played_at >= sqlc.narg('played_after'))
+  AND (sqlc.narg('played_before') IS NULL OR t.last_played_at <= sqlc.narg('played_before'))
+  AND (sqlc.narg('codec') IS NULL OR t.codec = sqlc.narg('codec') COLLATE NOCASE)
+  AND (sqlc.narg('lossless') IS NULL OR t.lossless = sqlc.narg('lossless'))
+  AND (sqlc.narg('bitrate_min') IS NULL OR t.bitrate >= sqlc.narg('bitrate_min'))
+  AND (sqlc.narg('bitrate_max') IS NULL OR t.bitrate <= sqlc.narg('bitrate_max'))
+  AND (sqlc.narg('sample_rate_min') IS NULL OR t.sample_rate >= sqlc.narg('sample_rate_min'))
+  AND (sqlc.narg('sample_rate_max') IS NULL OR t.sample_rate <= sqlc.narg('sample_rate_max'))
+  AND (sqlc.narg('bit_depth_min') IS NULL OR t.bit_depth >= sqlc.narg('bit_depth_min'))
+  AND (sqlc.narg('bit_depth_max') IS NULL OR t.bit_depth <= sqlc.narg('bit_depth_max'))
+  AND (sqlc.narg('channels_min') IS NULL OR t.channels >= sqlc.narg('channels_min'))
+  AND (sqlc.narg('channels_max') IS NULL OR t.channels <= sqlc.narg('channels_max'))
+ORDER BY
+  CASE WHEN sqlc.arg('sort') = 'title' AND sqlc.arg('order') = 'asc' THEN t.title END ASC,
+  CASE WHEN sqlc.arg('sort') = 'title' AND sqlc.arg('order') = 'desc' THEN t.title END DESC,
+  CASE WHEN sqlc.arg('sort') = 'artist' AND sqlc.arg('order') = 'asc' THEN ar.name END ASC,
+  CASE WHEN sqlc.arg('sort') = 'artist' AND sqlc.arg('order') = 'desc' THEN ar.name END DESC,
+  CASE WHEN sqlc.arg('sort') = 'album' AND sqlc.arg('order') = 'asc' THEN al.title END ASC,
+  CASE WHEN sqlc.arg('sort') = 'album' AND sqlc.arg('order') = 'desc' THEN al.title END DESC,
+  CASE WHEN sqlc.arg('sort') = 'year' THEN t.year IS NULL END,
+  CASE WHEN sqlc.arg('sort') = 'year' AND sqlc.arg('order') = 'asc' THEN t.year END ASC,
+  CASE WHEN sqlc.arg('sort') = 'year' AND sqlc.arg('order') = 'desc' THEN t.year END DESC,
+  CASE WHEN sqlc.arg('sort') = 'rating' THEN t.rating IS NULL END,
+  CASE WHEN sqlc.arg('sort') = 'rating' AND sqlc.arg('order') = 'asc' THEN t.rating END ASC,
+  CASE WHEN sqlc.arg('sort') = 'rating' AND sqlc.arg('order') = 'desc' THEN t.rating END DESC,
+  CASE WHEN sqlc.arg('sort') = 'duration' THEN t.duration_seconds IS NULL END,
+  CASE WHEN sqlc.arg('sort') = 'duration' AND sqlc.arg('order') = 'asc' THEN t.duration_seconds END ASC,
+  CASE WHEN sqlc.arg('sort') = 'duration' AND sqlc.arg('order') = 'desc' THEN t.duration_seconds END DESC,
+  CASE WHEN sqlc.arg('sort') = 'added' AND sqlc.arg('order') = 'asc' THEN t.created_at END ASC,
+  CASE WHEN sqlc.arg('sort') = 'added' AND sqlc.arg('order') = 'desc' THEN t.created_at END DESC,
+  CASE WHEN sqlc.arg('sort') = 'plays' AND sqlc.arg('order') = 'asc' THEN t.play_count END ASC,
+  CASE WHEN sqlc.arg('sort') = 'plays' AND sqlc.arg('order') = 'desc' THEN t.play_count END DESC,
+  CASE WHEN sqlc.arg('sort') = 'played' THEN t.last_played_at IS NULL END,
+  CASE WHEN sqlc.arg('sort') = 'played' AND sqlc.arg('order') = 'asc' THEN t.last_played_at END ASC,
+  CASE WHEN sqlc.arg('sort') = 'played' AND sqlc.arg('order') = 'desc' THEN t.last_played_at END DESC,
+  CASE WHEN sqlc.arg('sort') = 'path' AND sqlc.arg('order') = 'desc' THEN t.rel_path END DESC,
+  -- Album order (disc, then track, untagged last) within an album, and for sort = 'track'.
+  CASE WHEN sqlc.arg('sort') IN ('album', 'track') THEN t.album_id END,
+  CASE WHEN sqlc.arg('sort') IN ('album', 'track') THEN COALESCE(t.disc_number, 1) END,
+  CASE WHEN sqlc.arg('sort') IN ('album', 'track') THEN t.track_number IS NULL END,
+  CASE WHEN sqlc.arg('sort') IN ('album', 'track') THEN t.track_number END,
+  t.rel_path,
+  t.id
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+
+-- Live tracks per codec for the library format report. low_bitrate counts lossy tracks
+-- under the given bitrate (kbit/s); hi_res counts tracks above 48 kHz or 16 bits. Tracks
+-- not probed yet have a NULL codec. Bitrates are 0 when none in the group is known.
+-- name: ListTrackFormatStats :many
+SELECT
+  t.codec,
+  t.lossless,
+  COUNT(*) AS tracks,
+  CAST(COALESCE(SUM(t.size_bytes), 0) AS INTEGER) AS size_bytes,
+  CAST(COALESCE(SUM(t.duration_seconds), 0) AS INTEGER) AS duration_seconds,
+  CAST(COALESCE(MIN(t.bitrate), 0) AS INTEGER) AS min_bitrate,
+  CAST(COALESCE(ROUND(AVG(t.bitrate)), 0) AS INTEGER) AS avg_bitrate,
+  CAST(COALESCE(MAX(t.bitrate), 0) AS INTEGER) AS max_bitrate,
+  CAST(SUM(CASE WHEN t.lossless = 0 AND t.bitrate < sqlc.arg('low_bitrate') THEN 1 ELSE 0 END) AS INTEGER) AS low_bitrate,
+  CAST(SUM(CASE WHEN t.sample_rate > 48000 OR t.bit_depth > 16 THEN 1 ELSE 0 END) AS INTEGER) AS hi_res
+FROM tracks t
+JOIN folders f ON f.id = t.folder_id
+WHERE t.deleted_at IS NULL
+  AND f.deleted_at IS NULL
+GROUP BY t.codec, t.lossless
+ORDER BY tracks DESC, t.codec;
+
+-- Albums holding lossy tracks under the given bitrate (kbit/s), most such tracks first
+-- name: ListLowBitrateAlbums :many
+SELECT
+  al.id,
+  al.artist_id,
+  al.title,
+  ar.name AS artist_name,
+  al.track_count,
+  COUNT(*) AS low_bitrate_tracks,
+  CAST(MIN(t.bitrate) AS INTEGER) AS min_bitrate
+FROM tracks t
+JOIN folders f ON f.id = t.folder_id
+JOIN albums al ON al.id = t.album_id
+JOIN artists ar ON ar.id = al.artist_id
+WHERE t.deleted_at IS NULL
+  AND f.deleted_at IS NULL
+  AND t.lossless = 0
+  AND t.bitrate < sqlc.arg('low_bitrate')
+GROUP BY al.id
+ORDER BY low_bitrate_tracks DESC, min_bitrate, al.title, al.id
+LIMIT sqlc.arg('limit');
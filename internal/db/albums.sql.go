@@ -7,12 +7,13 @@ package db
 
 import (
 	"context"
+	"database/sql"
 
 	dbtypes "bottomley.ian/musicserver/internal/dbtypes"
 )
 
 const getAlbumByID = `-- name: GetAlbumByID :one
-SELECT id, artist_id, title, image_path, deleted_at, created_at, updated_at
+SELECT id, artist_id, title, image_path, deleted_at, created_at, updated_at, compilation, year, release_date, genre, track_count, disc_count, duration_seconds, loudness_lufs, peak_dbtp, replay_gain_db
 FROM albums
 WHERE id = ?
   AND deleted_at IS NULL
@@ -30,13 +31,23 @@ func (q *Queries) GetAlbumByID(ctx context.Context, id int64) (Album, error) {
 		&i.DeletedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Compilation,
+		&i.Year,
+		&i.ReleaseDate,
+		&i.Genre,
+		&i.TrackCount,
+		&i.DiscCount,
+		&i.DurationSeconds,
+		&i.LoudnessLufs,
+		&i.PeakDbtp,
+		&i.ReplayGainDb,
 	)
 	return i, err
 }
 
 const getAlbumWithArtist = `-- name: GetAlbumWithArtist :one
 SELECT
-  a.id, a.artist_id, a.title, a.image_path, a.deleted_at, a.created_at, a.updated_at,
+  a.id, a.artist_id, a.title, a.image_path, a.deleted_at, a.created_at, a.updated_at, a.compilation, a.year, a.release_date, a.genre, a.track_count, a.disc_count, a.duration_seconds, a.loudness_lufs, a.peak_dbtp, a.replay_gain_db,
   ar.id, ar.name, ar.deleted_at, ar.created_at, ar.updated_at
 FROM albums a
 LEFT JOIN artists ar ON ar.id = a.artist_id
@@ -61,6 +72,16 @@ func (q *Queries) GetAlbumWithArtist(ctx context.Context, id int64) (GetAlbumWit
 		&i.Album.DeletedAt,
 		&i.Album.CreatedAt,
 		&i.Album.UpdatedAt,
+		&i.Album.Compilation,
+		&i.Album.Year,
+		&i.Album.ReleaseDate,
+		&i.Album.Genre,
+		&i.Album.TrackCount,
+		&i.Album.DiscCount,
+		&i.Album.DurationSeconds,
+		&i.Album.LoudnessLufs,
+		&i.Album.PeakDbtp,
+		&i.Album.ReplayGainDb,
 		&i.Artist.ID,
 		&i.Artist.Name,
 		&i.Artist.DeletedAt,
@@ -72,8 +93,9 @@ func (q *Queries) GetAlbumWithArtist(ctx context.Context, id int64) (GetAlbumWit
 
 const listAlbumsWithArtist = `-- name: ListAlbumsWithArtist :many
 SELECT
-  a.id, a.artist_id, a.title, a.image_path, a.deleted_at, a.created_at, a.updated_at,
-  ar.id, ar.name, ar.deleted_at, ar.created_at, ar.updated_at
+  a.id, a.artist_id, a.title, a.image_path, a.deleted_at, a.created_at, a.updated_at, a.compilation, a.year, a.release_date, a.genre, a.track_count, a.disc_count, a.duration_seconds, a.loudness_lufs, a.peak_dbtp, a.replay_gain_db,
+  ar.id, ar.name, ar.deleted_at, ar.created_at, ar.updated_at,
+  COUNT(*) OVER () AS total_count
 FROM albums a
 LEFT JOIN artists ar ON ar.id = a.artist_id
 WHERE a.deleted_at IS NULL
@@ -90,22 +112,51 @@ WHERE a.deleted_at IS NULL
         AND t.album_id = a.id
     )
   )
-ORDER BY a.title
+  AND (?3 IS NULL OR a.year BETWEEN ?3 AND ?3 + 9)
+ORDER BY
+  CASE WHEN ?4 = 'year' THEN a.year IS NULL END,
+  CASE WHEN ?4 = 'year' AND ?5 = 'asc' THEN a.year END ASC,
+  CASE WHEN ?4 = 'year' AND ?5 = 'desc' THEN a.year END DESC,
+  CASE WHEN ?4 = 'artist' AND ?5 = 'asc' THEN ar.name END ASC,
+  CASE WHEN ?4 = 'artist' AND ?5 = 'desc' THEN ar.name END DESC,
+  CASE WHEN ?4 = 'added' AND ?5 = 'asc' THEN a.created_at END ASC,
+  CASE WHEN ?4 = 'added' AND ?5 = 'desc' THEN a.created_at END DESC,
+  CASE WHEN ?4 = 'duration' AND ?5 = 'asc' THEN a.duration_seconds END ASC,
+  CASE WHEN ?4 = 'duration' AND ?5 = 'desc' THEN a.duration_seconds END DESC,
+  CASE WHEN ?5 = 'desc' THEN a.title END DESC,
+  a.title,
+  a.id
+LIMIT ?6 OFFSET ?7
 `
 
 type ListAlbumsWithArtistParams struct {
 	Startswith         interface{}
 	IncludeUnavailable interface{}
+	Decade             interface{}
+	Sort               interface{}
+	Order              interface{}
+	Limit              int64
+	Offset             int64
 }
 
 type ListAlbumsWithArtistRow struct {
-	Album  Album
-	Artist Artist
+	Album      Album
+	Artist     Artist
+	TotalCount int64
 }
 
-// List albums (optionally include unavailable folders)
+// List albums (optionally include unavailable folders), filtered by decade and sorted by title/year/artist/added/duration
+// total_count is the number of matches before LIMIT/OFFSET; LIMIT -1 means no limit.
 func (q *Queries) ListAlbumsWithArtist(ctx context.Context, arg ListAlbumsWithArtistParams) ([]ListAlbumsWithArtistRow, error) {
-	rows, err := q.db.QueryContext(ctx, listAlbumsWithArtist, arg.Startswith, arg.IncludeUnavailable)
+	rows, err := q.db.QueryContext(ctx, listAlbumsWithArtist,
+		arg.Startswith,
+		arg.IncludeUnavailable,
+		arg.Decade,
+		arg.Sort,
+		arg.Order,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -121,11 +172,22 @@ func (q *Queries) ListAlbumsWithArtist(ctx context.Context, arg ListAlbumsWithAr
 			&i.Album.DeletedAt,
 			&i.Album.CreatedAt,
 			&i.Album.UpdatedAt,
+			&i.Album.Compilation,
+			&i.Album.Year,
+			&i.Album.ReleaseDate,
+			&i.Album.Genre,
+			&i.Album.TrackCount,
+			&i.Album.DiscCount,
+			&i.Album.DurationSeconds,
+			&i.Album.LoudnessLufs,
+			&i.Album.PeakDbtp,
+			&i.Album.ReplayGainDb,
 			&i.Artist.ID,
 			&i.Artist.Name,
 			&i.Artist.DeletedAt,
 			&i.Artist.CreatedAt,
 			&i.Artist.UpdatedAt,
+			&i.TotalCount,
 		); err != nil {
 			return nil, err
 		}
@@ -140,12 +202,96 @@ func (q *Queries) ListAlbumsWithArtist(ctx context.Context, arg ListAlbumsWithAr
 	return items, nil
 }
 
+const refreshAlbumRollups = `-- name: RefreshAlbumRollups :exec
+UPDATE albums
+SET compilation = EXISTS (
+    SELECT 1
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.compilation = 1
+      AND t.deleted_at IS NULL
+  ),
+  year = (
+    SELECT MIN(t.year)
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  ),
+  release_date = (
+    SELECT MIN(t.release_date)
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  ),
+  genre = (
+    SELECT t.genre
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+      AND t.genre IS NOT NULL
+    GROUP BY t.genre
+    ORDER BY COUNT(*) DESC, t.genre
+    LIMIT 1
+  ),
+  track_count = (
+    SELECT COUNT(*)
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  ),
+  disc_count = (
+    SELECT MAX(COALESCE(MAX(t.disc_total), 0), COUNT(DISTINCT COALESCE(t.disc_number, 1)))
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  ),
+  duration_seconds = (
+    SELECT COALESCE(SUM(t.duration_seconds), 0)
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  ),
+  -- Album loudness is the duration-weighted energy mean of the track loudnesses, and
+  -- only set once every live track has been analyzed. Gain is relative to -18 LUFS.
+  loudness_lufs = (
+    SELECT CASE WHEN COUNT(*) = COUNT(t.loudness_lufs) THEN
+      round(10 * log10(SUM(pow(10, t.loudness_lufs / 10) * COALESCE(t.duration_seconds, 1)) / SUM(COALESCE(t.duration_seconds, 1))), 2)
+    END
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  ),
+  peak_dbtp = (
+    SELECT CASE WHEN COUNT(*) = COUNT(t.peak_dbtp) THEN MAX(t.peak_dbtp) END
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  ),
+  replay_gain_db = (
+    SELECT CASE WHEN COUNT(*) = COUNT(t.loudness_lufs) THEN
+      round(-18 - 10 * log10(SUM(pow(10, t.loudness_lufs / 10) * COALESCE(t.duration_seconds, 1)) / SUM(COALESCE(t.duration_seconds, 1))), 2)
+    END
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  )
+WHERE deleted_at IS NULL
+  AND (?1 IS NULL OR id = ?1)
+`
+
+// Recompute a live album's rolled-up fields from its live tracks, or every live
+// album's when id is NULL
+func (q *Queries) RefreshAlbumRollups(ctx context.Context, id sql.NullInt64) error {
+	_, err := q.db.ExecContext(ctx, refreshAlbumRollups, id)
+	return err
+}
+
 const softDeleteAlbum = `-- name: SoftDeleteAlbum :one
 UPDATE albums
 SET deleted_at = CURRENT_TIMESTAMP
 WHERE id = ?
   AND deleted_at IS NULL
-RETURNING id, artist_id, title, image_path, deleted_at, created_at, updated_at
+RETURNING id, artist_id, title, image_path, deleted_at, created_at, updated_at, compilation, year, release_date, genre, track_count, disc_count, duration_seconds, loudness_lufs, peak_dbtp, replay_gain_db
 `
 
 // Soft delete album
@@ -160,16 +306,86 @@ func (q *Queries) SoftDeleteAlbum(ctx context.Context, id int64) (Album, error)
 		&i.DeletedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Compilation,
+		&i.Year,
+		&i.ReleaseDate,
+		&i.Genre,
+		&i.TrackCount,
+		&i.DiscCount,
+		&i.DurationSeconds,
+		&i.LoudnessLufs,
+		&i.PeakDbtp,
+		&i.ReplayGainDb,
 	)
 	return i, err
 }
 
+const softDeleteOrphanedAlbumByID = `-- name: SoftDeleteOrphanedAlbumByID :many
+UPDATE albums
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE id = ?
+  AND deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  )
+RETURNING artist_id
+`
+
+// Soft delete one album if it has no live tracks left. Returns its artist when it
+// was deleted, since that artist may now be orphaned too.
+func (q *Queries) SoftDeleteOrphanedAlbumByID(ctx context.Context, id int64) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, softDeleteOrphanedAlbumByID, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var artist_id int64
+		if err := rows.Scan(&artist_id); err != nil {
+			return nil, err
+		}
+		items = append(items, artist_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const softDeleteOrphanedAlbums = `-- name: SoftDeleteOrphanedAlbums :execrows
+UPDATE albums
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  )
+`
+
+// Soft delete albums left without any live tracks
+func (q *Queries) SoftDeleteOrphanedAlbums(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, softDeleteOrphanedAlbums)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const updateAlbum = `-- name: UpdateAlbum :one
 UPDATE albums
 SET artist_id = ?, title = ?
 WHERE id = ?
   AND deleted_at IS NULL
-RETURNING id, artist_id, title, image_path, deleted_at, created_at, updated_at
+RETURNING id, artist_id, title, image_path, deleted_at, created_at, updated_at, compilation, year, release_date, genre, track_count, disc_count, duration_seconds, loudness_lufs, peak_dbtp, replay_gain_db
 `
 
 type UpdateAlbumParams struct {
@@ -190,6 +406,16 @@ func (q *Queries) UpdateAlbum(ctx context.Context, arg UpdateAlbumParams) (Album
 		&i.DeletedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Compilation,
+		&i.Year,
+		&i.ReleaseDate,
+		&i.Genre,
+		&i.TrackCount,
+		&i.DiscCount,
+		&i.DurationSeconds,
+		&i.LoudnessLufs,
+		&i.PeakDbtp,
+		&i.ReplayGainDb,
 	)
 	return i, err
 }
@@ -199,7 +425,7 @@ UPDATE albums
 SET image_path = COALESCE(?, image_path)
 WHERE id = ?
   AND deleted_at IS NULL
-RETURNING id, artist_id, title, image_path, deleted_at, created_at, updated_at
+RETURNING id, artist_id, title, image_path, deleted_at, created_at, updated_at, compilation, year, release_date, genre, track_count, disc_count, duration_seconds, loudness_lufs, peak_dbtp, replay_gain_db
 `
 
 type UpdateAlbumImagePathParams struct {
@@ -219,6 +445,16 @@ func (q *Queries) UpdateAlbumImagePath(ctx context.Context, arg UpdateAlbumImage
 		&i.DeletedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Compilation,
+		&i.Year,
+		&i.ReleaseDate,
+		&i.Genre,
+		&i.TrackCount,
+		&i.DiscCount,
+		&i.DurationSeconds,
+		&i.LoudnessLufs,
+		&i.PeakDbtp,
+		&i.ReplayGainDb,
 	)
 	return i, err
 }
@@ -230,7 +466,7 @@ ON CONFLICT(artist_id, title) DO UPDATE SET
   artist_id = excluded.artist_id,
   title = excluded.title,
   deleted_at = NULL
-RETURNING id, artist_id, title, image_path, deleted_at, created_at, updated_at
+RETURNING id, artist_id, title, image_path, deleted_at, created_at, updated_at, compilation, year, release_date, genre, track_count, disc_count, duration_seconds, loudness_lufs, peak_dbtp, replay_gain_db
 `
 
 type UpsertAlbumParams struct {
@@ -250,6 +486,16 @@ func (q *Queries) UpsertAlbum(ctx context.Context, arg UpsertAlbumParams) (Album
 		&i.DeletedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Compilation,
+		&i.Year,
+		&i.ReleaseDate,
+		&i.Genre,
+		&i.TrackCount,
+		&i.DiscCount,
+		&i.DurationSeconds,
+		&i.LoudnessLufs,
+		&i.PeakDbtp,
+		&i.ReplayGainDb,
 	)
 	return i, err
 }
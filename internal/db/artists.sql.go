@@ -31,29 +31,79 @@ func (q *Queries) GetArtistByID(ctx context.Context, id int64) (Artist, error) {
 }
 
 const listArtists = `-- name: ListArtists :many
-SELECT id, name, deleted_at, created_at, updated_at
+SELECT
+  artists.id, artists.name, artists.deleted_at, artists.created_at, artists.updated_at,
+  COUNT(*) OVER () AS total_count
 FROM artists
 WHERE deleted_at IS NULL
   AND (?1 IS NULL OR name LIKE (?1 || '%'))
-ORDER BY name
+  AND (
+    ?2 = 1
+    OR EXISTS (
+      SELECT 1
+      FROM tracks t
+      LEFT JOIN albums al ON al.id = t.album_id
+      WHERE t.artist_id = artists.id
+        AND t.deleted_at IS NULL
+        AND t.compilation = 0
+        AND COALESCE(al.compilation, 0) = 0
+    )
+    OR EXISTS (
+      SELECT 1
+      FROM albums a
+      WHERE a.artist_id = artists.id
+        AND a.deleted_at IS NULL
+        AND a.compilation = 0
+    )
+  )
+ORDER BY
+  CASE WHEN ?3 = 'added' AND ?4 = 'asc' THEN created_at END ASC,
+  CASE WHEN ?3 = 'added' AND ?4 = 'desc' THEN created_at END DESC,
+  CASE WHEN ?4 = 'desc' THEN name END DESC,
+  name,
+  id
+LIMIT ?5 OFFSET ?6
 `
 
-// List artists (optional prefix filter)
-func (q *Queries) ListArtists(ctx context.Context, dollar_1 interface{}) ([]Artist, error) {
-	rows, err := q.db.QueryContext(ctx, listArtists, dollar_1)
+type ListArtistsParams struct {
+	Startswith             interface{}
+	IncludeCompilationOnly interface{}
+	Sort                   interface{}
+	Order                  interface{}
+	Limit                  int64
+	Offset                 int64
+}
+
+type ListArtistsRow struct {
+	Artist     Artist
+	TotalCount int64
+}
+
+// List artists (optional prefix filter; compilation-only artists only when include_compilation_only = 1), sorted by name/added
+// total_count is the number of matches before LIMIT/OFFSET; LIMIT -1 means no limit.
+func (q *Queries) ListArtists(ctx context.Context, arg ListArtistsParams) ([]ListArtistsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listArtists,
+		arg.Startswith,
+		arg.IncludeCompilationOnly,
+		arg.Sort,
+		arg.Order,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Artist
+	var items []ListArtistsRow
 	for rows.Next() {
-		var i Artist
+		var i ListArtistsRow
 		if err := rows.Scan(
-			&i.ID,
-			&i.Name,
-			&i.DeletedAt,
-			&i.CreatedAt,
-			&i.UpdatedAt,
+			&i.Artist.ID,
+			&i.Artist.Name,
+			&i.Artist.DeletedAt,
+			&i.Artist.CreatedAt,
+			&i.Artist.UpdatedAt,
+			&i.TotalCount,
 		); err != nil {
 			return nil, err
 		}
@@ -90,6 +140,61 @@ func (q *Queries) SoftDeleteArtist(ctx context.Context, id int64) (Artist, error
 	return i, err
 }
 
+const softDeleteOrphanedArtistByID = `-- name: SoftDeleteOrphanedArtistByID :execrows
+UPDATE artists
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE id = ?
+  AND deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM tracks t
+    WHERE t.artist_id = artists.id
+      AND t.deleted_at IS NULL
+  )
+  AND NOT EXISTS (
+    SELECT 1
+    FROM albums a
+    WHERE a.artist_id = artists.id
+      AND a.deleted_at IS NULL
+  )
+`
+
+// Soft delete one artist if it has no live tracks or albums left
+func (q *Queries) SoftDeleteOrphanedArtistByID(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, softDeleteOrphanedArtistByID, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const softDeleteOrphanedArtists = `-- name: SoftDeleteOrphanedArtists :execrows
+UPDATE artists
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE deleted_at IS NULL
+  AND NOT EXISTS (
+    SELECT 1
+    FROM tracks t
+    WHERE t.artist_id = artists.id
+      AND t.deleted_at IS NULL
+  )
+  AND NOT EXISTS (
+    SELECT 1
+    FROM albums a
+    WHERE a.artist_id = artists.id
+      AND a.deleted_at IS NULL
+  )
+`
+
+// Soft delete artists left without any live tracks or albums
+func (q *Queries) SoftDeleteOrphanedArtists(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, softDeleteOrphanedArtists)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const updateArtist = `-- name: UpdateArtist :one
 UPDATE artists
 SET name = ?
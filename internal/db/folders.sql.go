@@ -14,7 +14,7 @@ import (
 const createFolder = `-- name: CreateFolder :one
 INSERT INTO folders (path)
 VALUES (?)
-RETURNING id, path, deleted_at, available, last_seen_at, last_scan_at, last_scan_status, last_scan_error, created_at, updated_at
+RETURNING id, path, deleted_at, available, last_seen_at, last_scan_at, last_scan_status, last_scan_error, created_at, updated_at, watch, scan_schedule
 `
 
 func (q *Queries) CreateFolder(ctx context.Context, path string) (Folder, error) {
@@ -31,6 +31,8 @@ func (q *Queries) CreateFolder(ctx context.Context, path string) (Folder, error)
 		&i.LastScanError,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Watch,
+		&i.ScanSchedule,
 	)
 	return i, err
 }
@@ -90,7 +92,7 @@ func (q *Queries) FinishFolderScanUnavailable(ctx context.Context, arg FinishFol
 }
 
 const getFolderByID = `-- name: GetFolderByID :one
-SELECT id, path, deleted_at, available, last_seen_at, last_scan_at, last_scan_status, last_scan_error, created_at, updated_at
+SELECT id, path, deleted_at, available, last_seen_at, last_scan_at, last_scan_status, last_scan_error, created_at, updated_at, watch, scan_schedule
 FROM folders
 WHERE id = ?
 `
@@ -109,12 +111,48 @@ func (q *Queries) GetFolderByID(ctx context.Context, id int64) (Folder, error) {
 		&i.LastScanError,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Watch,
+		&i.ScanSchedule,
 	)
 	return i, err
 }
 
+const interruptRunningFolderScans = `-- name: InterruptRunningFolderScans :many
+UPDATE folders
+SET
+  last_scan_status = 'error',
+  last_scan_error = ?
+WHERE deleted_at IS NULL
+  AND last_scan_status = 'running'
+RETURNING id
+`
+
+// Reconcile folders left "running" by a crash or restart
+func (q *Queries) InterruptRunningFolderScans(ctx context.Context, lastScanError dbtypes.NullString) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, interruptRunningFolderScans, lastScanError)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listFolders = `-- name: ListFolders :many
-SELECT id, path, deleted_at, available, last_seen_at, last_scan_at, last_scan_status, last_scan_error, created_at, updated_at
+SELECT id, path, deleted_at, available, last_seen_at, last_scan_at, last_scan_status, last_scan_error, created_at, updated_at, watch, scan_schedule
 FROM folders
 WHERE deleted_at IS NULL
 ORDER BY path
@@ -140,6 +178,52 @@ func (q *Queries) ListFolders(ctx context.Context) ([]Folder, error) {
 			&i.LastScanError,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Watch,
+			&i.ScanSchedule,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listWatchedFolders = `-- name: ListWatchedFolders :many
+SELECT id, path, deleted_at, available, last_seen_at, last_scan_at, last_scan_status, last_scan_error, created_at, updated_at, watch, scan_schedule
+FROM folders
+WHERE deleted_at IS NULL
+  AND watch = 1
+ORDER BY path
+`
+
+func (q *Queries) ListWatchedFolders(ctx context.Context) ([]Folder, error) {
+	rows, err := q.db.QueryContext(ctx, listWatchedFolders)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Folder
+	for rows.Next() {
+		var i Folder
+		if err := rows.Scan(
+			&i.ID,
+			&i.Path,
+			&i.DeletedAt,
+			&i.Available,
+			&i.LastSeenAt,
+			&i.LastScanAt,
+			&i.LastScanStatus,
+			&i.LastScanError,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Watch,
+			&i.ScanSchedule,
 		); err != nil {
 			return nil, err
 		}
@@ -173,11 +257,77 @@ func (q *Queries) SetFolderAvailability(ctx context.Context, arg SetFolderAvaila
 	return err
 }
 
+const setFolderScanSchedule = `-- name: SetFolderScanSchedule :one
+UPDATE folders
+SET scan_schedule = ?
+WHERE id = ? AND deleted_at IS NULL
+RETURNING id, path, deleted_at, available, last_seen_at, last_scan_at, last_scan_status, last_scan_error, created_at, updated_at, watch, scan_schedule
+`
+
+type SetFolderScanScheduleParams struct {
+	ScanSchedule dbtypes.NullString
+	ID           int64
+}
+
+// Set or clear the rescan schedule for a folder
+func (q *Queries) SetFolderScanSchedule(ctx context.Context, arg SetFolderScanScheduleParams) (Folder, error) {
+	row := q.db.QueryRowContext(ctx, setFolderScanSchedule, arg.ScanSchedule, arg.ID)
+	var i Folder
+	err := row.Scan(
+		&i.ID,
+		&i.Path,
+		&i.DeletedAt,
+		&i.Available,
+		&i.LastSeenAt,
+		&i.LastScanAt,
+		&i.LastScanStatus,
+		&i.LastScanError,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Watch,
+		&i.ScanSchedule,
+	)
+	return i, err
+}
+
+const setFolderWatch = `-- name: SetFolderWatch :one
+UPDATE folders
+SET watch = ?
+WHERE id = ? AND deleted_at IS NULL
+RETURNING id, path, deleted_at, available, last_seen_at, last_scan_at, last_scan_status, last_scan_error, created_at, updated_at, watch, scan_schedule
+`
+
+type SetFolderWatchParams struct {
+	Watch int64
+	ID    int64
+}
+
+// Toggle filesystem watching for a folder
+func (q *Queries) SetFolderWatch(ctx context.Context, arg SetFolderWatchParams) (Folder, error) {
+	row := q.db.QueryRowContext(ctx, setFolderWatch, arg.Watch, arg.ID)
+	var i Folder
+	err := row.Scan(
+		&i.ID,
+		&i.Path,
+		&i.DeletedAt,
+		&i.Available,
+		&i.LastSeenAt,
+		&i.LastScanAt,
+		&i.LastScanStatus,
+		&i.LastScanError,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Watch,
+		&i.ScanSchedule,
+	)
+	return i, err
+}
+
 const softDeleteFolder = `-- name: SoftDeleteFolder :one
 UPDATE folders
 SET deleted_at = CURRENT_TIMESTAMP
 WHERE id = ? AND deleted_at IS NULL
-RETURNING id, path, deleted_at, available, last_seen_at, last_scan_at, last_scan_status, last_scan_error, created_at, updated_at
+RETURNING id, path, deleted_at, available, last_seen_at, last_scan_at, last_scan_status, last_scan_error, created_at, updated_at, watch, scan_schedule
 `
 
 func (q *Queries) SoftDeleteFolder(ctx context.Context, id int64) (Folder, error) {
@@ -194,6 +344,8 @@ func (q *Queries) SoftDeleteFolder(ctx context.Context, id int64) (Folder, error
 		&i.LastScanError,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Watch,
+		&i.ScanSchedule,
 	)
 	return i, err
 }
@@ -5,19 +5,30 @@
 package db
 
 import (
+	"database/sql"
 	"time"
 
 	dbtypes "bottomley.ian/musicserver/internal/dbtypes"
 )
 
 type Album struct {
-	ID        int64
-	ArtistID  int64
-	Title     string
-	ImagePath dbtypes.NullString
-	DeletedAt dbtypes.NullTime
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID              int64
+	ArtistID        int64
+	Title           string
+	ImagePath       dbtypes.NullString
+	DeletedAt       dbtypes.NullTime
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Compilation     int64
+	Year            dbtypes.NullInt64
+	ReleaseDate     dbtypes.NullString
+	Genre           dbtypes.NullString
+	TrackCount      int64
+	DiscCount       int64
+	DurationSeconds int64
+	LoudnessLufs    sql.NullFloat64
+	PeakDbtp        sql.NullFloat64
+	ReplayGainDb    sql.NullFloat64
 }
 
 type Artist struct {
@@ -39,6 +50,8 @@ type Folder struct {
 	LastScanError  dbtypes.NullString
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
+	Watch          int64
+	ScanSchedule   dbtypes.NullString
 }
 
 type Journal struct {
@@ -74,12 +87,25 @@ type JournalEntry struct {
 	UpdatedAt    time.Time
 }
 
+type Play struct {
+	ID              int64
+	TrackID         int64
+	PlayedAt        time.Time
+	PositionSeconds dbtypes.NullInt64
+	Completed       int64
+	Counted         int64
+	Source          string
+	CreatedAt       time.Time
+}
+
 type Playlist struct {
 	ID        int64
 	Name      string
 	DeletedAt dbtypes.NullTime
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	Type      string
+	Rules     dbtypes.NullString
 }
 
 type PlaylistTrack struct {
@@ -92,6 +118,30 @@ type PlaylistTrack struct {
 	UpdatedAt  time.Time
 }
 
+type ScanRun struct {
+	ID            int64
+	FolderID      int64
+	Status        string
+	FullRescan    int64
+	Error         dbtypes.NullString
+	StartedAt     time.Time
+	FinishedAt    dbtypes.NullTime
+	TriggeredBy   string
+	FilesSeen     int64
+	TracksAdded   int64
+	TracksUpdated int64
+	TracksRemoved int64
+	FilesFailed   int64
+}
+
+type ScanRunError struct {
+	ID        int64
+	ScanRunID int64
+	RelPath   string
+	Error     string
+	CreatedAt time.Time
+}
+
 type Setting struct {
 	Key       string
 	Value     string
@@ -100,23 +150,53 @@ type Setting struct {
 }
 
 type Track struct {
-	ID              int64
-	FolderID        int64
-	ArtistID        dbtypes.NullInt64
-	AlbumID         dbtypes.NullInt64
-	RelPath         string
-	Title           string
-	Filename        string
-	Ext             string
-	Genre           dbtypes.NullString
-	Year            dbtypes.NullInt64
-	Rating          dbtypes.NullInt64
-	ImagePath       dbtypes.NullString
-	SizeBytes       int64
-	LastModified    int64
-	DurationSeconds dbtypes.NullInt64
-	LastSeenAt      time.Time
-	DeletedAt       dbtypes.NullTime
-	CreatedAt       time.Time
-	UpdatedAt       time.Time
+	ID                 int64
+	FolderID           int64
+	ArtistID           dbtypes.NullInt64
+	AlbumID            dbtypes.NullInt64
+	RelPath            string
+	Title              string
+	Filename           string
+	Ext                string
+	Genre              dbtypes.NullString
+	Year               dbtypes.NullInt64
+	Rating             dbtypes.NullInt64
+	ImagePath          dbtypes.NullString
+	SizeBytes          int64
+	LastModified       int64
+	DurationSeconds    dbtypes.NullInt64
+	LastSeenAt         time.Time
+	DeletedAt          dbtypes.NullTime
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	TrackNumber        dbtypes.NullInt64
+	TrackTotal         dbtypes.NullInt64
+	DiscNumber         dbtypes.NullInt64
+	DiscTotal          dbtypes.NullInt64
+	AlbumArtist        dbtypes.NullString
+	Composer           dbtypes.NullString
+	Comment            dbtypes.NullString
+	Lyrics             dbtypes.NullString
+	Bpm                dbtypes.NullInt64
+	Compilation        int64
+	AlbumPinned        int64
+	ReleaseDate        dbtypes.NullString
+	PlayCount          int64
+	LastPlayedAt       dbtypes.NullTime
+	LoudnessLufs       sql.NullFloat64
+	PeakDbtp           sql.NullFloat64
+	ReplayGainDb       sql.NullFloat64
+	LoudnessAnalyzedAt dbtypes.NullTime
+	AlbumGainDb        sql.NullFloat64
+	AlbumPeakDbtp      sql.NullFloat64
+	DurationMs         dbtypes.NullInt64
+	SampleRate         dbtypes.NullInt64
+	BitDepth           dbtypes.NullInt64
+	Channels           dbtypes.NullInt64
+	Codec              dbtypes.NullString
+	Bitrate            dbtypes.NullInt64
+	EncoderDelay       dbtypes.NullInt64
+	EncoderPadding     dbtypes.NullInt64
+	SampleCount        dbtypes.NullInt64
+	Lossless           dbtypes.NullInt64
 }
@@ -154,32 +154,64 @@ func (q *Queries) ListPlaylistTrackIDs(ctx context.Context, playlistID int64) ([
 const listPlaylistTracks = `-- name: ListPlaylistTracks :many
 SELECT
   pt.id, pt.playlist_id, pt.track_id, pt.position, pt.deleted_at, pt.created_at, pt.updated_at,
-  t.id, t.folder_id, t.artist_id, t.album_id, t.rel_path, t.title, t.filename, t.ext, t.genre, t.year, t.rating, t.image_path, t.size_bytes, t.last_modified, t.duration_seconds, t.last_seen_at, t.deleted_at, t.created_at, t.updated_at,
+  t.id, t.folder_id, t.artist_id, t.album_id, t.rel_path, t.title, t.filename, t.ext, t.genre, t.year, t.rating, t.image_path, t.size_bytes, t.last_modified, t.duration_seconds, t.last_seen_at, t.deleted_at, t.created_at, t.updated_at, t.track_number, t.track_total, t.disc_number, t.disc_total, t.album_artist, t.composer, t.comment, t.lyrics, t.bpm, t.compilation, t.album_pinned, t.release_date, t.play_count, t.last_played_at, t.loudness_lufs, t.peak_dbtp, t.replay_gain_db, t.loudness_analyzed_at, t.album_gain_db, t.album_peak_dbtp, t.duration_ms, t.sample_rate, t.bit_depth, t.channels, t.codec, t.bitrate, t.encoder_delay, t.encoder_padding, t.sample_count, t.lossless,
   ar.id, ar.name, ar.deleted_at, ar.created_at, ar.updated_at,
-  al.id, al.artist_id, al.title, al.image_path, al.deleted_at, al.created_at, al.updated_at,
-  al_ar.id, al_ar.name, al_ar.deleted_at, al_ar.created_at, al_ar.updated_at
+  al.id, al.artist_id, al.title, al.image_path, al.deleted_at, al.created_at, al.updated_at, al.compilation, al.year, al.release_date, al.genre, al.track_count, al.disc_count, al.duration_seconds, al.loudness_lufs, al.peak_dbtp, al.replay_gain_db,
+  al_ar.id, al_ar.name, al_ar.deleted_at, al_ar.created_at, al_ar.updated_at,
+  COUNT(*) OVER () AS total_count
 FROM playlist_tracks pt
 JOIN tracks t ON t.id = pt.track_id
 LEFT JOIN artists ar ON ar.id = t.artist_id
 LEFT JOIN albums al ON al.id = t.album_id
 LEFT JOIN artists al_ar ON al_ar.id = al.artist_id
-WHERE pt.playlist_id = ?
+WHERE pt.playlist_id = ?1
   AND pt.deleted_at IS NULL
   AND t.deleted_at IS NULL
-ORDER BY pt.position
+ORDER BY
+  CASE WHEN ?2 = 'title' AND ?3 = 'asc' THEN t.title END ASC,
+  CASE WHEN ?2 = 'title' AND ?3 = 'desc' THEN t.title END DESC,
+  CASE WHEN ?2 = 'artist' AND ?3 = 'asc' THEN ar.name END ASC,
+  CASE WHEN ?2 = 'artist' AND ?3 = 'desc' THEN ar.name END DESC,
+  CASE WHEN ?2 = 'album' AND ?3 = 'asc' THEN al.title END ASC,
+  CASE WHEN ?2 = 'album' AND ?3 = 'desc' THEN al.title END DESC,
+  CASE WHEN ?2 = 'duration' THEN t.duration_seconds IS NULL END,
+  CASE WHEN ?2 = 'duration' AND ?3 = 'asc' THEN t.duration_seconds END ASC,
+  CASE WHEN ?2 = 'duration' AND ?3 = 'desc' THEN t.duration_seconds END DESC,
+  CASE WHEN ?2 = 'added' AND ?3 = 'asc' THEN pt.created_at END ASC,
+  CASE WHEN ?2 = 'added' AND ?3 = 'desc' THEN pt.created_at END DESC,
+  CASE WHEN ?3 = 'desc' THEN pt.position END DESC,
+  pt.position,
+  pt.id
+LIMIT ?4 OFFSET ?5
 `
 
+type ListPlaylistTracksParams struct {
+	PlaylistID int64
+	Sort       interface{}
+	Order      interface{}
+	Limit      int64
+	Offset     int64
+}
+
 type ListPlaylistTracksRow struct {
 	PlaylistTrack PlaylistTrack
 	Track         Track
 	Artist        Artist
 	Album         Album
 	Artist_2      Artist
+	TotalCount    int64
 }
 
-// List playlist tracks with track metadata
-func (q *Queries) ListPlaylistTracks(ctx context.Context, playlistID int64) ([]ListPlaylistTracksRow, error) {
-	rows, err := q.db.QueryContext(ctx, listPlaylistTracks, playlistID)
+// List playlist tracks with track metadata, sorted by position/title/artist/album/duration/added
+// total_count is the number of matches before LIMIT/OFFSET; LIMIT -1 means no limit.
+func (q *Queries) ListPlaylistTracks(ctx context.Context, arg ListPlaylistTracksParams) ([]ListPlaylistTracksRow, error) {
+	rows, err := q.db.QueryContext(ctx, listPlaylistTracks,
+		arg.PlaylistID,
+		arg.Sort,
+		arg.Order,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -214,6 +246,36 @@ func (q *Queries) ListPlaylistTracks(ctx context.Context, playlistID int64) ([]L
 			&i.Track.DeletedAt,
 			&i.Track.CreatedAt,
 			&i.Track.UpdatedAt,
+			&i.Track.TrackNumber,
+			&i.Track.TrackTotal,
+			&i.Track.DiscNumber,
+			&i.Track.DiscTotal,
+			&i.Track.AlbumArtist,
+			&i.Track.Composer,
+			&i.Track.Comment,
+			&i.Track.Lyrics,
+			&i.Track.Bpm,
+			&i.Track.Compilation,
+			&i.Track.AlbumPinned,
+			&i.Track.ReleaseDate,
+			&i.Track.PlayCount,
+			&i.Track.LastPlayedAt,
+			&i.Track.LoudnessLufs,
+			&i.Track.PeakDbtp,
+			&i.Track.ReplayGainDb,
+			&i.Track.LoudnessAnalyzedAt,
+			&i.Track.AlbumGainDb,
+			&i.Track.AlbumPeakDbtp,
+			&i.Track.DurationMs,
+			&i.Track.SampleRate,
+			&i.Track.BitDepth,
+			&i.Track.Channels,
+			&i.Track.Codec,
+			&i.Track.Bitrate,
+			&i.Track.EncoderDelay,
+			&i.Track.EncoderPadding,
+			&i.Track.SampleCount,
+			&i.Track.Lossless,
 			&i.Artist.ID,
 			&i.Artist.Name,
 			&i.Artist.DeletedAt,
@@ -226,11 +288,22 @@ func (q *Queries) ListPlaylistTracks(ctx context.Context, playlistID int64) ([]L
 			&i.Album.DeletedAt,
 			&i.Album.CreatedAt,
 			&i.Album.UpdatedAt,
+			&i.Album.Compilation,
+			&i.Album.Year,
+			&i.Album.ReleaseDate,
+			&i.Album.Genre,
+			&i.Album.TrackCount,
+			&i.Album.DiscCount,
+			&i.Album.DurationSeconds,
+			&i.Album.LoudnessLufs,
+			&i.Album.PeakDbtp,
+			&i.Album.ReplayGainDb,
 			&i.Artist_2.ID,
 			&i.Artist_2.Name,
 			&i.Artist_2.DeletedAt,
 			&i.Artist_2.CreatedAt,
 			&i.Artist_2.UpdatedAt,
+			&i.TotalCount,
 		); err != nil {
 			return nil, err
 		}
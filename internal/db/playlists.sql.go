@@ -7,16 +7,24 @@ package db
 
 import (
 	"context"
+
+	dbtypes "bottomley.ian/musicserver/internal/dbtypes"
 )
 
 const createPlaylist = `-- name: CreatePlaylist :one
-INSERT INTO playlists (name) VALUES (?)
-RETURNING id, name, deleted_at, created_at, updated_at
+INSERT INTO playlists (name, type, rules) VALUES (?, ?, ?)
+RETURNING id, name, deleted_at, created_at, updated_at, type, rules
 `
 
-// Create playlist
-func (q *Queries) CreatePlaylist(ctx context.Context, name string) (Playlist, error) {
-	row := q.db.QueryRowContext(ctx, createPlaylist, name)
+type CreatePlaylistParams struct {
+	Name  string
+	Type  string
+	Rules dbtypes.NullString
+}
+
+// Create playlist (rules is the JSON rule set of a smart playlist)
+func (q *Queries) CreatePlaylist(ctx context.Context, arg CreatePlaylistParams) (Playlist, error) {
+	row := q.db.QueryRowContext(ctx, createPlaylist, arg.Name, arg.Type, arg.Rules)
 	var i Playlist
 	err := row.Scan(
 		&i.ID,
@@ -24,12 +32,14 @@ func (q *Queries) CreatePlaylist(ctx context.Context, name string) (Playlist, er
 		&i.DeletedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Type,
+		&i.Rules,
 	)
 	return i, err
 }
 
 const getPlaylistByID = `-- name: GetPlaylistByID :one
-SELECT id, name, deleted_at, created_at, updated_at
+SELECT id, name, deleted_at, created_at, updated_at, type, rules
 FROM playlists
 WHERE id = ?
   AND deleted_at IS NULL
@@ -45,12 +55,14 @@ func (q *Queries) GetPlaylistByID(ctx context.Context, id int64) (Playlist, erro
 		&i.DeletedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Type,
+		&i.Rules,
 	)
 	return i, err
 }
 
 const listPlaylists = `-- name: ListPlaylists :many
-SELECT id, name, deleted_at, created_at, updated_at
+SELECT id, name, deleted_at, created_at, updated_at, type, rules
 FROM playlists
 WHERE deleted_at IS NULL
 ORDER BY name
@@ -72,6 +84,8 @@ func (q *Queries) ListPlaylists(ctx context.Context) ([]Playlist, error) {
 			&i.DeletedAt,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Type,
+			&i.Rules,
 		); err != nil {
 			return nil, err
 		}
@@ -104,20 +118,21 @@ func (q *Queries) SoftDeletePlaylist(ctx context.Context, id int64) (int64, erro
 
 const updatePlaylist = `-- name: UpdatePlaylist :one
 UPDATE playlists
-SET name = ?
+SET name = ?, rules = ?
 WHERE id = ?
   AND deleted_at IS NULL
-RETURNING id, name, deleted_at, created_at, updated_at
+RETURNING id, name, deleted_at, created_at, updated_at, type, rules
 `
 
 type UpdatePlaylistParams struct {
-	Name string
-	ID   int64
+	Name  string
+	Rules dbtypes.NullString
+	ID    int64
 }
 
-// Update playlist name
+// Update playlist name and smart playlist rules
 func (q *Queries) UpdatePlaylist(ctx context.Context, arg UpdatePlaylistParams) (Playlist, error) {
-	row := q.db.QueryRowContext(ctx, updatePlaylist, arg.Name, arg.ID)
+	row := q.db.QueryRowContext(ctx, updatePlaylist, arg.Name, arg.Rules, arg.ID)
 	var i Playlist
 	err := row.Scan(
 		&i.ID,
@@ -125,6 +140,8 @@ func (q *Queries) UpdatePlaylist(ctx context.Context, arg UpdatePlaylistParams)
 		&i.DeletedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Type,
+		&i.Rules,
 	)
 	return i, err
 }
@@ -225,7 +225,9 @@ func (q *Queries) GetJournalEntryByDatePosition(ctx context.Context, arg GetJour
 }
 
 const listJournalEntries = `-- name: ListJournalEntries :many
-SELECT id, year, month, day, journal_date, position, title, raw_line, hash, body, status, tags, property_keys, type, scheduled_at, deadline_at, created_at, updated_at
+SELECT
+  journal_entries.id, journal_entries.year, journal_entries.month, journal_entries.day, journal_entries.journal_date, journal_entries.position, journal_entries.title, journal_entries.raw_line, journal_entries.hash, journal_entries.body, journal_entries.status, journal_entries.tags, journal_entries.property_keys, journal_entries.type, journal_entries.scheduled_at, journal_entries.deadline_at, journal_entries.created_at, journal_entries.updated_at,
+  COUNT(*) OVER () AS total_count
 FROM journal_entries
 WHERE (
     ?1 IS NULL
@@ -249,49 +251,85 @@ WHERE (
       WHERE LOWER(value) IN (SELECT LOWER(value) FROM json_each(?4))
     )
   )
-ORDER BY year DESC, month DESC, day DESC, position ASC
+ORDER BY
+  CASE WHEN ?5 = 'title' AND ?6 = 'asc' THEN title END ASC,
+  CASE WHEN ?5 = 'title' AND ?6 = 'desc' THEN title END DESC,
+  CASE WHEN ?5 = 'status' THEN status IS NULL END,
+  CASE WHEN ?5 = 'status' AND ?6 = 'asc' THEN status END ASC,
+  CASE WHEN ?5 = 'status' AND ?6 = 'desc' THEN status END DESC,
+  CASE WHEN ?5 = 'scheduled' THEN scheduled_at IS NULL END,
+  CASE WHEN ?5 = 'scheduled' AND ?6 = 'asc' THEN scheduled_at END ASC,
+  CASE WHEN ?5 = 'scheduled' AND ?6 = 'desc' THEN scheduled_at END DESC,
+  CASE WHEN ?5 = 'deadline' THEN deadline_at IS NULL END,
+  CASE WHEN ?5 = 'deadline' AND ?6 = 'asc' THEN deadline_at END ASC,
+  CASE WHEN ?5 = 'deadline' AND ?6 = 'desc' THEN deadline_at END DESC,
+  CASE WHEN ?6 = 'desc' THEN year END DESC,
+  CASE WHEN ?6 = 'desc' THEN month END DESC,
+  CASE WHEN ?6 = 'desc' THEN day END DESC,
+  year,
+  month,
+  day,
+  position,
+  id
+LIMIT ?7 OFFSET ?8
 `
 
 type ListJournalEntriesParams struct {
-	Column1 interface{}
-	Column2 interface{}
-	Column3 interface{}
-	Column4 interface{}
+	Date     interface{}
+	Type     interface{}
+	Statuses interface{}
+	Tags     interface{}
+	Sort     interface{}
+	Order    interface{}
+	Limit    int64
+	Offset   int64
 }
 
-func (q *Queries) ListJournalEntries(ctx context.Context, arg ListJournalEntriesParams) ([]JournalEntry, error) {
+type ListJournalEntriesRow struct {
+	JournalEntry JournalEntry
+	TotalCount   int64
+}
+
+// List journal entries filtered by date prefix, type, statuses and tags (JSON arrays), sorted by date/title/status/scheduled/deadline.
+// Entries on the same day stay in file order. total_count is the number of matches before LIMIT/OFFSET; LIMIT -1 means no limit.
+func (q *Queries) ListJournalEntries(ctx context.Context, arg ListJournalEntriesParams) ([]ListJournalEntriesRow, error) {
 	rows, err := q.db.QueryContext(ctx, listJournalEntries,
-		arg.Column1,
-		arg.Column2,
-		arg.Column3,
-		arg.Column4,
+		arg.Date,
+		arg.Type,
+		arg.Statuses,
+		arg.Tags,
+		arg.Sort,
+		arg.Order,
+		arg.Limit,
+		arg.Offset,
 	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []JournalEntry
+	var items []ListJournalEntriesRow
 	for rows.Next() {
-		var i JournalEntry
+		var i ListJournalEntriesRow
 		if err := rows.Scan(
-			&i.ID,
-			&i.Year,
-			&i.Month,
-			&i.Day,
-			&i.JournalDate,
-			&i.Position,
-			&i.Title,
-			&i.RawLine,
-			&i.Hash,
-			&i.Body,
-			&i.Status,
-			&i.Tags,
-			&i.PropertyKeys,
-			&i.Type,
-			&i.ScheduledAt,
-			&i.DeadlineAt,
-			&i.CreatedAt,
-			&i.UpdatedAt,
+			&i.JournalEntry.ID,
+			&i.JournalEntry.Year,
+			&i.JournalEntry.Month,
+			&i.JournalEntry.Day,
+			&i.JournalEntry.JournalDate,
+			&i.JournalEntry.Position,
+			&i.JournalEntry.Title,
+			&i.JournalEntry.RawLine,
+			&i.JournalEntry.Hash,
+			&i.JournalEntry.Body,
+			&i.JournalEntry.Status,
+			&i.JournalEntry.Tags,
+			&i.JournalEntry.PropertyKeys,
+			&i.JournalEntry.Type,
+			&i.JournalEntry.ScheduledAt,
+			&i.JournalEntry.DeadlineAt,
+			&i.JournalEntry.CreatedAt,
+			&i.JournalEntry.UpdatedAt,
+			&i.TotalCount,
 		); err != nil {
 			return nil, err
 		}
@@ -7,7 +7,7 @@ package db
 
 import (
 	"context"
-	"time"
+	"database/sql"
 
 	dbtypes "bottomley.ian/musicserver/internal/dbtypes"
 )
@@ -58,7 +58,7 @@ func (q *Queries) GetPlayableTrackPathPartsByID(ctx context.Context, id int64) (
 }
 
 const getTrackByID = `-- name: GetTrackByID :one
-SELECT id, folder_id, artist_id, album_id, rel_path, title, filename, ext, genre, year, rating, image_path, size_bytes, last_modified, duration_seconds, last_seen_at, deleted_at, created_at, updated_at
+SELECT id, folder_id, artist_id, album_id, rel_path, title, filename, ext, genre, year, rating, image_path, size_bytes, last_modified, duration_seconds, last_seen_at, deleted_at, created_at, updated_at, track_number, track_total, disc_number, disc_total, album_artist, composer, comment, lyrics, bpm, compilation, album_pinned, release_date, play_count, last_played_at, loudness_lufs, peak_dbtp, replay_gain_db, loudness_analyzed_at, album_gain_db, album_peak_dbtp, duration_ms, sample_rate, bit_depth, channels, codec, bitrate, encoder_delay, encoder_padding, sample_count, lossless
 FROM tracks
 WHERE id = ?
   AND deleted_at IS NULL
@@ -88,15 +88,66 @@ func (q *Queries) GetTrackByID(ctx context.Context, id int64) (Track, error) {
 		&i.DeletedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.TrackNumber,
+		&i.TrackTotal,
+		&i.DiscNumber,
+		&i.DiscTotal,
+		&i.AlbumArtist,
+		&i.Composer,
+		&i.Comment,
+		&i.Lyrics,
+		&i.Bpm,
+		&i.Compilation,
+		&i.AlbumPinned,
+		&i.ReleaseDate,
+		&i.PlayCount,
+		&i.LastPlayedAt,
+		&i.LoudnessLufs,
+		&i.PeakDbtp,
+		&i.ReplayGainDb,
+		&i.LoudnessAnalyzedAt,
+		&i.AlbumGainDb,
+		&i.AlbumPeakDbtp,
+		&i.DurationMs,
+		&i.SampleRate,
+		&i.BitDepth,
+		&i.Channels,
+		&i.Codec,
+		&i.Bitrate,
+		&i.EncoderDelay,
+		&i.EncoderPadding,
+		&i.SampleCount,
+		&i.Lossless,
 	)
 	return i, err
 }
 
+const getTrackIDByRelPath = `-- name: GetTrackIDByRelPath :one
+SELECT id
+FROM tracks
+WHERE folder_id = ?
+  AND rel_path = ?
+  AND deleted_at IS NULL
+`
+
+type GetTrackIDByRelPathParams struct {
+	FolderID int64
+	RelPath  string
+}
+
+// Look up a live track by its path within a folder
+func (q *Queries) GetTrackIDByRelPath(ctx context.Context, arg GetTrackIDByRelPathParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getTrackIDByRelPath, arg.FolderID, arg.RelPath)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
 const getTrackWithJoins = `-- name: GetTrackWithJoins :one
 SELECT
-  t.id, t.folder_id, t.artist_id, t.album_id, t.rel_path, t.title, t.filename, t.ext, t.genre, t.year, t.rating, t.image_path, t.size_bytes, t.last_modified, t.duration_seconds, t.last_seen_at, t.deleted_at, t.created_at, t.updated_at,
+  t.id, t.folder_id, t.artist_id, t.album_id, t.rel_path, t.title, t.filename, t.ext, t.genre, t.year, t.rating, t.image_path, t.size_bytes, t.last_modified, t.duration_seconds, t.last_seen_at, t.deleted_at, t.created_at, t.updated_at, t.track_number, t.track_total, t.disc_number, t.disc_total, t.album_artist, t.composer, t.comment, t.lyrics, t.bpm, t.compilation, t.album_pinned, t.release_date, t.play_count, t.last_played_at, t.loudness_lufs, t.peak_dbtp, t.replay_gain_db, t.loudness_analyzed_at, t.album_gain_db, t.album_peak_dbtp, t.duration_ms, t.sample_rate, t.bit_depth, t.channels, t.codec, t.bitrate, t.encoder_delay, t.encoder_padding, t.sample_count, t.lossless,
   ar.id, ar.name, ar.deleted_at, ar.created_at, ar.updated_at,
-  al.id, al.artist_id, al.title, al.image_path, al.deleted_at, al.created_at, al.updated_at,
+  al.id, al.artist_id, al.title, al.image_path, al.deleted_at, al.created_at, al.updated_at, al.compilation, al.year, al.release_date, al.genre, al.track_count, al.disc_count, al.duration_seconds, al.loudness_lufs, al.peak_dbtp, al.replay_gain_db,
   al_ar.id, al_ar.name, al_ar.deleted_at, al_ar.created_at, al_ar.updated_at
 FROM tracks t
 LEFT JOIN artists ar ON ar.id = t.artist_id
@@ -137,6 +188,36 @@ func (q *Queries) GetTrackWithJoins(ctx context.Context, id int64) (GetTrackWith
 		&i.Track.DeletedAt,
 		&i.Track.CreatedAt,
 		&i.Track.UpdatedAt,
+		&i.Track.TrackNumber,
+		&i.Track.TrackTotal,
+		&i.Track.DiscNumber,
+		&i.Track.DiscTotal,
+		&i.Track.AlbumArtist,
+		&i.Track.Composer,
+		&i.Track.Comment,
+		&i.Track.Lyrics,
+		&i.Track.Bpm,
+		&i.Track.Compilation,
+		&i.Track.AlbumPinned,
+		&i.Track.ReleaseDate,
+		&i.Track.PlayCount,
+		&i.Track.LastPlayedAt,
+		&i.Track.LoudnessLufs,
+		&i.Track.PeakDbtp,
+		&i.Track.ReplayGainDb,
+		&i.Track.LoudnessAnalyzedAt,
+		&i.Track.AlbumGainDb,
+		&i.Track.AlbumPeakDbtp,
+		&i.Track.DurationMs,
+		&i.Track.SampleRate,
+		&i.Track.BitDepth,
+		&i.Track.Channels,
+		&i.Track.Codec,
+		&i.Track.Bitrate,
+		&i.Track.EncoderDelay,
+		&i.Track.EncoderPadding,
+		&i.Track.SampleCount,
+		&i.Track.Lossless,
 		&i.Artist.ID,
 		&i.Artist.Name,
 		&i.Artist.DeletedAt,
@@ -149,6 +230,16 @@ func (q *Queries) GetTrackWithJoins(ctx context.Context, id int64) (GetTrackWith
 		&i.Album.DeletedAt,
 		&i.Album.CreatedAt,
 		&i.Album.UpdatedAt,
+		&i.Album.Compilation,
+		&i.Album.Year,
+		&i.Album.ReleaseDate,
+		&i.Album.Genre,
+		&i.Album.TrackCount,
+		&i.Album.DiscCount,
+		&i.Album.DurationSeconds,
+		&i.Album.LoudnessLufs,
+		&i.Album.PeakDbtp,
+		&i.Album.ReplayGainDb,
 		&i.Artist_2.ID,
 		&i.Artist_2.Name,
 		&i.Artist_2.DeletedAt,
@@ -158,8 +249,49 @@ func (q *Queries) GetTrackWithJoins(ctx context.Context, id int64) (GetTrackWith
 	return i, err
 }
 
+const listAlbumTrackFiles = `-- name: ListAlbumTrackFiles :many
+SELECT
+  t.id,
+  f.available
+FROM tracks t
+JOIN folders f ON f.id = t.folder_id
+WHERE t.album_id = ?
+  AND t.deleted_at IS NULL
+  AND f.deleted_at IS NULL
+ORDER BY t.id
+`
+
+type ListAlbumTrackFilesRow struct {
+	ID        int64
+	Available int64
+}
+
+// List an album's live tracks with their folder availability (for tag write-back)
+func (q *Queries) ListAlbumTrackFiles(ctx context.Context, albumID dbtypes.NullInt64) ([]ListAlbumTrackFilesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listAlbumTrackFiles, albumID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAlbumTrackFilesRow
+	for rows.Next() {
+		var i ListAlbumTrackFilesRow
+		if err := rows.Scan(&i.ID, &i.Available); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listAllIndexedTracks = `-- name: ListAllIndexedTracks :many
-SELECT t.id, t.folder_id, t.artist_id, t.album_id, t.rel_path, t.title, t.filename, t.ext, t.genre, t.year, t.rating, t.image_path, t.size_bytes, t.last_modified, t.duration_seconds, t.last_seen_at, t.deleted_at, t.created_at, t.updated_at
+SELECT t.id, t.folder_id, t.artist_id, t.album_id, t.rel_path, t.title, t.filename, t.ext, t.genre, t.year, t.rating, t.image_path, t.size_bytes, t.last_modified, t.duration_seconds, t.last_seen_at, t.deleted_at, t.created_at, t.updated_at, t.track_number, t.track_total, t.disc_number, t.disc_total, t.album_artist, t.composer, t.comment, t.lyrics, t.bpm, t.compilation, t.album_pinned, t.release_date, t.play_count, t.last_played_at, t.loudness_lufs, t.peak_dbtp, t.replay_gain_db, t.loudness_analyzed_at, t.album_gain_db, t.album_peak_dbtp, t.duration_ms, t.sample_rate, t.bit_depth, t.channels, t.codec, t.bitrate, t.encoder_delay, t.encoder_padding, t.sample_count, t.lossless
 FROM tracks t
 JOIN folders f ON f.id = t.folder_id
 WHERE t.deleted_at IS NULL
@@ -198,6 +330,36 @@ func (q *Queries) ListAllIndexedTracks(ctx context.Context, prefix interface{})
 			&i.DeletedAt,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.TrackNumber,
+			&i.TrackTotal,
+			&i.DiscNumber,
+			&i.DiscTotal,
+			&i.AlbumArtist,
+			&i.Composer,
+			&i.Comment,
+			&i.Lyrics,
+			&i.Bpm,
+			&i.Compilation,
+			&i.AlbumPinned,
+			&i.ReleaseDate,
+			&i.PlayCount,
+			&i.LastPlayedAt,
+			&i.LoudnessLufs,
+			&i.PeakDbtp,
+			&i.ReplayGainDb,
+			&i.LoudnessAnalyzedAt,
+			&i.AlbumGainDb,
+			&i.AlbumPeakDbtp,
+			&i.DurationMs,
+			&i.SampleRate,
+			&i.BitDepth,
+			&i.Channels,
+			&i.Codec,
+			&i.Bitrate,
+			&i.EncoderDelay,
+			&i.EncoderPadding,
+			&i.SampleCount,
+			&i.Lossless,
 		); err != nil {
 			return nil, err
 		}
@@ -214,9 +376,9 @@ func (q *Queries) ListAllIndexedTracks(ctx context.Context, prefix interface{})
 
 const listAllIndexedTracksWithJoins = `-- name: ListAllIndexedTracksWithJoins :many
 SELECT
-  t.id, t.folder_id, t.artist_id, t.album_id, t.rel_path, t.title, t.filename, t.ext, t.genre, t.year, t.rating, t.image_path, t.size_bytes, t.last_modified, t.duration_seconds, t.last_seen_at, t.deleted_at, t.created_at, t.updated_at,
+  t.id, t.folder_id, t.artist_id, t.album_id, t.rel_path, t.title, t.filename, t.ext, t.genre, t.year, t.rating, t.image_path, t.size_bytes, t.last_modified, t.duration_seconds, t.last_seen_at, t.deleted_at, t.created_at, t.updated_at, t.track_number, t.track_total, t.disc_number, t.disc_total, t.album_artist, t.composer, t.comment, t.lyrics, t.bpm, t.compilation, t.album_pinned, t.release_date, t.play_count, t.last_played_at, t.loudness_lufs, t.peak_dbtp, t.replay_gain_db, t.loudness_analyzed_at, t.album_gain_db, t.album_peak_dbtp, t.duration_ms, t.sample_rate, t.bit_depth, t.channels, t.codec, t.bitrate, t.encoder_delay, t.encoder_padding, t.sample_count, t.lossless,
   ar.id, ar.name, ar.deleted_at, ar.created_at, ar.updated_at,
-  al.id, al.artist_id, al.title, al.image_path, al.deleted_at, al.created_at, al.updated_at,
+  al.id, al.artist_id, al.title, al.image_path, al.deleted_at, al.created_at, al.updated_at, al.compilation, al.year, al.release_date, al.genre, al.track_count, al.disc_count, al.duration_seconds, al.loudness_lufs, al.peak_dbtp, al.replay_gain_db,
   al_ar.id, al_ar.name, al_ar.deleted_at, al_ar.created_at, al_ar.updated_at
 FROM tracks t
 JOIN folders f ON f.id = t.folder_id
@@ -265,6 +427,36 @@ func (q *Queries) ListAllIndexedTracksWithJoins(ctx context.Context) ([]ListAllI
 			&i.Track.DeletedAt,
 			&i.Track.CreatedAt,
 			&i.Track.UpdatedAt,
+			&i.Track.TrackNumber,
+			&i.Track.TrackTotal,
+			&i.Track.DiscNumber,
+			&i.Track.DiscTotal,
+			&i.Track.AlbumArtist,
+			&i.Track.Composer,
+			&i.Track.Comment,
+			&i.Track.Lyrics,
+			&i.Track.Bpm,
+			&i.Track.Compilation,
+			&i.Track.AlbumPinned,
+			&i.Track.ReleaseDate,
+			&i.Track.PlayCount,
+			&i.Track.LastPlayedAt,
+			&i.Track.LoudnessLufs,
+			&i.Track.PeakDbtp,
+			&i.Track.ReplayGainDb,
+			&i.Track.LoudnessAnalyzedAt,
+			&i.Track.AlbumGainDb,
+			&i.Track.AlbumPeakDbtp,
+			&i.Track.DurationMs,
+			&i.Track.SampleRate,
+			&i.Track.BitDepth,
+			&i.Track.Channels,
+			&i.Track.Codec,
+			&i.Track.Bitrate,
+			&i.Track.EncoderDelay,
+			&i.Track.EncoderPadding,
+			&i.Track.SampleCount,
+			&i.Track.Lossless,
 			&i.Artist.ID,
 			&i.Artist.Name,
 			&i.Artist.DeletedAt,
@@ -277,6 +469,16 @@ func (q *Queries) ListAllIndexedTracksWithJoins(ctx context.Context) ([]ListAllI
 			&i.Album.DeletedAt,
 			&i.Album.CreatedAt,
 			&i.Album.UpdatedAt,
+			&i.Album.Compilation,
+			&i.Album.Year,
+			&i.Album.ReleaseDate,
+			&i.Album.Genre,
+			&i.Album.TrackCount,
+			&i.Album.DiscCount,
+			&i.Album.DurationSeconds,
+			&i.Album.LoudnessLufs,
+			&i.Album.PeakDbtp,
+			&i.Album.ReplayGainDb,
 			&i.Artist_2.ID,
 			&i.Artist_2.Name,
 			&i.Artist_2.DeletedAt,
@@ -296,52 +498,44 @@ func (q *Queries) ListAllIndexedTracksWithJoins(ctx context.Context) ([]ListAllI
 	return items, nil
 }
 
-const listPlayableTracks = `-- name: ListPlayableTracks :many
-SELECT t.id, t.folder_id, t.artist_id, t.album_id, t.rel_path, t.title, t.filename, t.ext, t.genre, t.year, t.rating, t.image_path, t.size_bytes, t.last_modified, t.duration_seconds, t.last_seen_at, t.deleted_at, t.created_at, t.updated_at
+const listArtistTrackFiles = `-- name: ListArtistTrackFiles :many
+SELECT
+  t.id,
+  f.available,
+  CAST(t.artist_id IS ?1 AS INTEGER) AS performer,
+  CAST(COALESCE(al.artist_id = ?1 AND (t.album_artist IS NOT NULL OR t.artist_id IS NOT ?1), 0) AS INTEGER) AS album_artist
 FROM tracks t
 JOIN folders f ON f.id = t.folder_id
+LEFT JOIN albums al ON al.id = t.album_id
 WHERE t.deleted_at IS NULL
   AND f.deleted_at IS NULL
-  AND (?1 = 1 OR f.available = 1)
-  AND (?2 IS NULL OR t.filename LIKE (?2 || '%'))
-ORDER BY t.rel_path
+  AND (t.artist_id = ?1 OR al.artist_id = ?1)
+ORDER BY t.id
 `
 
-type ListPlayableTracksParams struct {
-	IncludeUnavailable interface{}
-	Prefix             interface{}
+type ListArtistTrackFilesRow struct {
+	ID          int64
+	Available   int64
+	Performer   int64
+	AlbumArtist int64
 }
 
-// Default: list all playable tracks (roots currently available)
-func (q *Queries) ListPlayableTracks(ctx context.Context, arg ListPlayableTracksParams) ([]Track, error) {
-	rows, err := q.db.QueryContext(ctx, listPlayableTracks, arg.IncludeUnavailable, arg.Prefix)
+// List live tracks an artist performs or is album artist of, with folder availability
+// and which of the artist/album_artist tags name them (for tag write-back)
+func (q *Queries) ListArtistTrackFiles(ctx context.Context, artistID dbtypes.NullInt64) ([]ListArtistTrackFilesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listArtistTrackFiles, artistID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Track
+	var items []ListArtistTrackFilesRow
 	for rows.Next() {
-		var i Track
+		var i ListArtistTrackFilesRow
 		if err := rows.Scan(
 			&i.ID,
-			&i.FolderID,
-			&i.ArtistID,
-			&i.AlbumID,
-			&i.RelPath,
-			&i.Title,
-			&i.Filename,
-			&i.Ext,
-			&i.Genre,
-			&i.Year,
-			&i.Rating,
-			&i.ImagePath,
-			&i.SizeBytes,
-			&i.LastModified,
-			&i.DurationSeconds,
-			&i.LastSeenAt,
-			&i.DeletedAt,
-			&i.CreatedAt,
-			&i.UpdatedAt,
+			&i.Available,
+			&i.Performer,
+			&i.AlbumArtist,
 		); err != nil {
 			return nil, err
 		}
@@ -356,85 +550,61 @@ func (q *Queries) ListPlayableTracks(ctx context.Context, arg ListPlayableTracks
 	return items, nil
 }
 
-const listPlayableTracksForAlbum = `-- name: ListPlayableTracksForAlbum :many
+const listLowBitrateAlbums = `-- name: ListLowBitrateAlbums :many
 SELECT
-  t.id, t.folder_id, t.artist_id, t.album_id, t.rel_path, t.title, t.filename, t.ext, t.genre, t.year, t.rating, t.image_path, t.size_bytes, t.last_modified, t.duration_seconds, t.last_seen_at, t.deleted_at, t.created_at, t.updated_at,
-  ar.id, ar.name, ar.deleted_at, ar.created_at, ar.updated_at,
-  al.id, al.artist_id, al.title, al.image_path, al.deleted_at, al.created_at, al.updated_at,
-  al_ar.id, al_ar.name, al_ar.deleted_at, al_ar.created_at, al_ar.updated_at
+  al.id,
+  al.artist_id,
+  al.title,
+  ar.name AS artist_name,
+  al.track_count,
+  COUNT(*) AS low_bitrate_tracks,
+  CAST(MIN(t.bitrate) AS INTEGER) AS min_bitrate
 FROM tracks t
 JOIN folders f ON f.id = t.folder_id
-LEFT JOIN artists ar ON ar.id = t.artist_id
-LEFT JOIN albums al ON al.id = t.album_id
-LEFT JOIN artists al_ar ON al_ar.id = al.artist_id
+JOIN albums al ON al.id = t.album_id
+JOIN artists ar ON ar.id = al.artist_id
 WHERE t.deleted_at IS NULL
   AND f.deleted_at IS NULL
-  AND (?1 = 1 OR f.available = 1)
-  AND t.album_id = ?2
-  AND (?3 IS NULL OR t.filename LIKE (?3 || '%'))
-ORDER BY t.rel_path
+  AND t.lossless = 0
+  AND t.bitrate < ?1
+GROUP BY al.id
+ORDER BY low_bitrate_tracks DESC, min_bitrate, al.title, al.id
+LIMIT ?2
 `
 
-type ListPlayableTracksForAlbumParams struct {
-	Column1 interface{}
-	AlbumID dbtypes.NullInt64
-	Column3 interface{}
+type ListLowBitrateAlbumsParams struct {
+	LowBitrate interface{}
+	Limit      int64
 }
 
-type ListPlayableTracksForAlbumRow struct {
-	Track    Track
-	Artist   Artist
-	Album    Album
-	Artist_2 Artist
+type ListLowBitrateAlbumsRow struct {
+	ID               int64
+	ArtistID         int64
+	Title            string
+	ArtistName       string
+	TrackCount       int64
+	LowBitrateTracks int64
+	MinBitrate       int64
 }
 
-// List playable tracks for an album (roots currently available)
-func (q *Queries) ListPlayableTracksForAlbum(ctx context.Context, arg ListPlayableTracksForAlbumParams) ([]ListPlayableTracksForAlbumRow, error) {
-	rows, err := q.db.QueryContext(ctx, listPlayableTracksForAlbum, arg.Column1, arg.AlbumID, arg.Column3)
+// Albums holding lossy tracks under the given bitrate (kbit/s), most such tracks first
+func (q *Queries) ListLowBitrateAlbums(ctx context.Context, arg ListLowBitrateAlbumsParams) ([]ListLowBitrateAlbumsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listLowBitrateAlbums, arg.LowBitrate, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ListPlayableTracksForAlbumRow
+	var items []ListLowBitrateAlbumsRow
 	for rows.Next() {
-		var i ListPlayableTracksForAlbumRow
+		var i ListLowBitrateAlbumsRow
 		if err := rows.Scan(
-			&i.Track.ID,
-			&i.Track.FolderID,
-			&i.Track.ArtistID,
-			&i.Track.AlbumID,
-			&i.Track.RelPath,
-			&i.Track.Title,
-			&i.Track.Filename,
-			&i.Track.Ext,
-			&i.Track.Genre,
-			&i.Track.Year,
-			&i.Track.Rating,
-			&i.Track.ImagePath,
-			&i.Track.SizeBytes,
-			&i.Track.LastModified,
-			&i.Track.DurationSeconds,
-			&i.Track.LastSeenAt,
-			&i.Track.DeletedAt,
-			&i.Track.CreatedAt,
-			&i.Track.UpdatedAt,
-			&i.Artist.ID,
-			&i.Artist.Name,
-			&i.Artist.DeletedAt,
-			&i.Artist.CreatedAt,
-			&i.Artist.UpdatedAt,
-			&i.Album.ID,
-			&i.Album.ArtistID,
-			&i.Album.Title,
-			&i.Album.ImagePath,
-			&i.Album.DeletedAt,
-			&i.Album.CreatedAt,
-			&i.Album.UpdatedAt,
-			&i.Artist_2.ID,
-			&i.Artist_2.Name,
-			&i.Artist_2.DeletedAt,
-			&i.Artist_2.CreatedAt,
-			&i.Artist_2.UpdatedAt,
+			&i.ID,
+			&i.ArtistID,
+			&i.Title,
+			&i.ArtistName,
+			&i.TrackCount,
+			&i.LowBitrateTracks,
+			&i.MinBitrate,
 		); err != nil {
 			return nil, err
 		}
@@ -449,92 +619,35 @@ func (q *Queries) ListPlayableTracksForAlbum(ctx context.Context, arg ListPlayab
 	return items, nil
 }
 
-const listPlayableTracksForAlbumArtist = `-- name: ListPlayableTracksForAlbumArtist :many
-SELECT
-  t.id, t.folder_id, t.artist_id, t.album_id, t.rel_path, t.title, t.filename, t.ext, t.genre, t.year, t.rating, t.image_path, t.size_bytes, t.last_modified, t.duration_seconds, t.last_seen_at, t.deleted_at, t.created_at, t.updated_at,
-  ar.id, ar.name, ar.deleted_at, ar.created_at, ar.updated_at,
-  al.id, al.artist_id, al.title, al.image_path, al.deleted_at, al.created_at, al.updated_at,
-  al_ar.id, al_ar.name, al_ar.deleted_at, al_ar.created_at, al_ar.updated_at
-FROM tracks t
-JOIN folders f ON f.id = t.folder_id
-LEFT JOIN artists ar ON ar.id = t.artist_id
-LEFT JOIN albums al ON al.id = t.album_id
-LEFT JOIN artists al_ar ON al_ar.id = al.artist_id
-WHERE t.deleted_at IS NULL
-  AND f.deleted_at IS NULL
-  AND (?1 = 1 OR f.available = 1)
-  AND t.album_id = ?2
-  AND t.artist_id = ?3
-  AND (?4 IS NULL OR t.filename LIKE (?4 || '%'))
-ORDER BY t.rel_path
+const listTrackFileStatsForFolder = `-- name: ListTrackFileStatsForFolder :many
+SELECT id, rel_path, size_bytes, last_modified
+FROM tracks
+WHERE folder_id = ?
+  AND deleted_at IS NULL
 `
 
-type ListPlayableTracksForAlbumArtistParams struct {
-	Column1  interface{}
-	AlbumID  dbtypes.NullInt64
-	ArtistID dbtypes.NullInt64
-	Column4  interface{}
-}
-
-type ListPlayableTracksForAlbumArtistRow struct {
-	Track    Track
-	Artist   Artist
-	Album    Album
-	Artist_2 Artist
+type ListTrackFileStatsForFolderRow struct {
+	ID           int64
+	RelPath      string
+	SizeBytes    int64
+	LastModified int64
 }
 
-// List playable tracks for an album + artist (roots currently available)
-func (q *Queries) ListPlayableTracksForAlbumArtist(ctx context.Context, arg ListPlayableTracksForAlbumArtistParams) ([]ListPlayableTracksForAlbumArtistRow, error) {
-	rows, err := q.db.QueryContext(ctx, listPlayableTracksForAlbumArtist,
-		arg.Column1,
-		arg.AlbumID,
-		arg.ArtistID,
-		arg.Column4,
-	)
+// File facts for incremental scans (excluding deleted)
+func (q *Queries) ListTrackFileStatsForFolder(ctx context.Context, folderID int64) ([]ListTrackFileStatsForFolderRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTrackFileStatsForFolder, folderID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ListPlayableTracksForAlbumArtistRow
+	var items []ListTrackFileStatsForFolderRow
 	for rows.Next() {
-		var i ListPlayableTracksForAlbumArtistRow
+		var i ListTrackFileStatsForFolderRow
 		if err := rows.Scan(
-			&i.Track.ID,
-			&i.Track.FolderID,
-			&i.Track.ArtistID,
-			&i.Track.AlbumID,
-			&i.Track.RelPath,
-			&i.Track.Title,
-			&i.Track.Filename,
-			&i.Track.Ext,
-			&i.Track.Genre,
-			&i.Track.Year,
-			&i.Track.Rating,
-			&i.Track.ImagePath,
-			&i.Track.SizeBytes,
-			&i.Track.LastModified,
-			&i.Track.DurationSeconds,
-			&i.Track.LastSeenAt,
-			&i.Track.DeletedAt,
-			&i.Track.CreatedAt,
-			&i.Track.UpdatedAt,
-			&i.Artist.ID,
-			&i.Artist.Name,
-			&i.Artist.DeletedAt,
-			&i.Artist.CreatedAt,
-			&i.Artist.UpdatedAt,
-			&i.Album.ID,
-			&i.Album.ArtistID,
-			&i.Album.Title,
-			&i.Album.ImagePath,
-			&i.Album.DeletedAt,
-			&i.Album.CreatedAt,
-			&i.Album.UpdatedAt,
-			&i.Artist_2.ID,
-			&i.Artist_2.Name,
-			&i.Artist_2.DeletedAt,
-			&i.Artist_2.CreatedAt,
-			&i.Artist_2.UpdatedAt,
+			&i.ID,
+			&i.RelPath,
+			&i.SizeBytes,
+			&i.LastModified,
 		); err != nil {
 			return nil, err
 		}
@@ -549,61 +662,62 @@ func (q *Queries) ListPlayableTracksForAlbumArtist(ctx context.Context, arg List
 	return items, nil
 }
 
-const listPlayableTracksForAlbumArtistBase = `-- name: ListPlayableTracksForAlbumArtistBase :many
-SELECT t.id, t.folder_id, t.artist_id, t.album_id, t.rel_path, t.title, t.filename, t.ext, t.genre, t.year, t.rating, t.image_path, t.size_bytes, t.last_modified, t.duration_seconds, t.last_seen_at, t.deleted_at, t.created_at, t.updated_at
+const listTrackFormatStats = `-- name: ListTrackFormatStats :many
+SELECT
+  t.codec,
+  t.lossless,
+  COUNT(*) AS tracks,
+  CAST(COALESCE(SUM(t.size_bytes), 0) AS INTEGER) AS size_bytes,
+  CAST(COALESCE(SUM(t.duration_seconds), 0) AS INTEGER) AS duration_seconds,
+  CAST(COALESCE(MIN(t.bitrate), 0) AS INTEGER) AS min_bitrate,
+  CAST(COALESCE(ROUND(AVG(t.bitrate)), 0) AS INTEGER) AS avg_bitrate,
+  CAST(COALESCE(MAX(t.bitrate), 0) AS INTEGER) AS max_bitrate,
+  CAST(SUM(CASE WHEN t.lossless = 0 AND t.bitrate < ?1 THEN 1 ELSE 0 END) AS INTEGER) AS low_bitrate,
+  CAST(SUM(CASE WHEN t.sample_rate > 48000 OR t.bit_depth > 16 THEN 1 ELSE 0 END) AS INTEGER) AS hi_res
 FROM tracks t
 JOIN folders f ON f.id = t.folder_id
 WHERE t.deleted_at IS NULL
   AND f.deleted_at IS NULL
-  AND (?1 = 1 OR f.available = 1)
-  AND t.album_id = ?2
-  AND t.artist_id = ?3
-  AND (?4 IS NULL OR t.filename LIKE (?4 || '%'))
-ORDER BY t.rel_path
+GROUP BY t.codec, t.lossless
+ORDER BY tracks DESC, t.codec
 `
 
-type ListPlayableTracksForAlbumArtistBaseParams struct {
-	Column1  interface{}
-	AlbumID  dbtypes.NullInt64
-	ArtistID dbtypes.NullInt64
-	Column4  interface{}
+type ListTrackFormatStatsRow struct {
+	Codec           dbtypes.NullString
+	Lossless        dbtypes.NullInt64
+	Tracks          int64
+	SizeBytes       int64
+	DurationSeconds int64
+	MinBitrate      int64
+	AvgBitrate      int64
+	MaxBitrate      int64
+	LowBitrate      int64
+	HiRes           int64
 }
 
-// List playable tracks for an album + artist without joins (roots currently available)
-func (q *Queries) ListPlayableTracksForAlbumArtistBase(ctx context.Context, arg ListPlayableTracksForAlbumArtistBaseParams) ([]Track, error) {
-	rows, err := q.db.QueryContext(ctx, listPlayableTracksForAlbumArtistBase,
-		arg.Column1,
-		arg.AlbumID,
-		arg.ArtistID,
-		arg.Column4,
-	)
+// Live tracks per codec for the library format report. low_bitrate counts lossy tracks
+// under the given bitrate (kbit/s); hi_res counts tracks above 48 kHz or 16 bits. Tracks
+// not probed yet have a NULL codec. Bitrates are 0 when none in the group is known.
+func (q *Queries) ListTrackFormatStats(ctx context.Context, lowBitrate interface{}) ([]ListTrackFormatStatsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTrackFormatStats, lowBitrate)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Track
+	var items []ListTrackFormatStatsRow
 	for rows.Next() {
-		var i Track
+		var i ListTrackFormatStatsRow
 		if err := rows.Scan(
-			&i.ID,
-			&i.FolderID,
-			&i.ArtistID,
-			&i.AlbumID,
-			&i.RelPath,
-			&i.Title,
-			&i.Filename,
-			&i.Ext,
-			&i.Genre,
-			&i.Year,
-			&i.Rating,
-			&i.ImagePath,
+			&i.Codec,
+			&i.Lossless,
+			&i.Tracks,
 			&i.SizeBytes,
-			&i.LastModified,
 			&i.DurationSeconds,
-			&i.LastSeenAt,
-			&i.DeletedAt,
-			&i.CreatedAt,
-			&i.UpdatedAt,
+			&i.MinBitrate,
+			&i.AvgBitrate,
+			&i.MaxBitrate,
+			&i.LowBitrate,
+			&i.HiRes,
 		); err != nil {
 			return nil, err
 		}
@@ -618,27 +732,16 @@ func (q *Queries) ListPlayableTracksForAlbumArtistBase(ctx context.Context, arg
 	return items, nil
 }
 
-const listPlayableTracksForAlbumBase = `-- name: ListPlayableTracksForAlbumBase :many
-SELECT t.id, t.folder_id, t.artist_id, t.album_id, t.rel_path, t.title, t.filename, t.ext, t.genre, t.year, t.rating, t.image_path, t.size_bytes, t.last_modified, t.duration_seconds, t.last_seen_at, t.deleted_at, t.created_at, t.updated_at
-FROM tracks t
-JOIN folders f ON f.id = t.folder_id
-WHERE t.deleted_at IS NULL
-  AND f.deleted_at IS NULL
-  AND (?1 = 1 OR f.available = 1)
-  AND t.album_id = ?2
-  AND (?3 IS NULL OR t.filename LIKE (?3 || '%'))
-ORDER BY t.rel_path
+const listTracksForFolder = `-- name: ListTracksForFolder :many
+SELECT id, folder_id, artist_id, album_id, rel_path, title, filename, ext, genre, year, rating, image_path, size_bytes, last_modified, duration_seconds, last_seen_at, deleted_at, created_at, updated_at, track_number, track_total, disc_number, disc_total, album_artist, composer, comment, lyrics, bpm, compilation, album_pinned, release_date, play_count, last_played_at, loudness_lufs, peak_dbtp, replay_gain_db, loudness_analyzed_at, album_gain_db, album_peak_dbtp, duration_ms, sample_rate, bit_depth, channels, codec, bitrate, encoder_delay, encoder_padding, sample_count, lossless
+FROM tracks
+WHERE folder_id = ? AND deleted_at IS NULL
+ORDER BY rel_path
 `
 
-type ListPlayableTracksForAlbumBaseParams struct {
-	Column1 interface{}
-	AlbumID dbtypes.NullInt64
-	Column3 interface{}
-}
-
-// List playable tracks for an album without joins (roots currently available)
-func (q *Queries) ListPlayableTracksForAlbumBase(ctx context.Context, arg ListPlayableTracksForAlbumBaseParams) ([]Track, error) {
-	rows, err := q.db.QueryContext(ctx, listPlayableTracksForAlbumBase, arg.Column1, arg.AlbumID, arg.Column3)
+// List tracks for a folder (excluding deleted)
+func (q *Queries) ListTracksForFolder(ctx context.Context, folderID int64) ([]Track, error) {
+	rows, err := q.db.QueryContext(ctx, listTracksForFolder, folderID)
 	if err != nil {
 		return nil, err
 	}
@@ -666,6 +769,36 @@ func (q *Queries) ListPlayableTracksForAlbumBase(ctx context.Context, arg ListPl
 			&i.DeletedAt,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.TrackNumber,
+			&i.TrackTotal,
+			&i.DiscNumber,
+			&i.DiscTotal,
+			&i.AlbumArtist,
+			&i.Composer,
+			&i.Comment,
+			&i.Lyrics,
+			&i.Bpm,
+			&i.Compilation,
+			&i.AlbumPinned,
+			&i.ReleaseDate,
+			&i.PlayCount,
+			&i.LastPlayedAt,
+			&i.LoudnessLufs,
+			&i.PeakDbtp,
+			&i.ReplayGainDb,
+			&i.LoudnessAnalyzedAt,
+			&i.AlbumGainDb,
+			&i.AlbumPeakDbtp,
+			&i.DurationMs,
+			&i.SampleRate,
+			&i.BitDepth,
+			&i.Channels,
+			&i.Codec,
+			&i.Bitrate,
+			&i.EncoderDelay,
+			&i.EncoderPadding,
+			&i.SampleCount,
+			&i.Lossless,
 		); err != nil {
 			return nil, err
 		}
@@ -680,86 +813,32 @@ func (q *Queries) ListPlayableTracksForAlbumBase(ctx context.Context, arg ListPl
 	return items, nil
 }
 
-const listPlayableTracksForArtist = `-- name: ListPlayableTracksForArtist :many
-SELECT
-  t.id, t.folder_id, t.artist_id, t.album_id, t.rel_path, t.title, t.filename, t.ext, t.genre, t.year, t.rating, t.image_path, t.size_bytes, t.last_modified, t.duration_seconds, t.last_seen_at, t.deleted_at, t.created_at, t.updated_at,
-  ar.id, ar.name, ar.deleted_at, ar.created_at, ar.updated_at,
-  al.id, al.artist_id, al.title, al.image_path, al.deleted_at, al.created_at, al.updated_at,
-  al_ar.id, al_ar.name, al_ar.deleted_at, al_ar.created_at, al_ar.updated_at
-FROM tracks t
-JOIN folders f ON f.id = t.folder_id
-LEFT JOIN artists ar ON ar.id = t.artist_id
-LEFT JOIN albums al ON al.id = t.album_id
-LEFT JOIN artists al_ar ON al_ar.id = al.artist_id
-WHERE t.deleted_at IS NULL
-  AND f.deleted_at IS NULL
-  AND (?1 = 1 OR f.available = 1)
-  AND t.artist_id = ?2
-  AND (?3 IS NULL OR t.filename LIKE (?3 || '%'))
-ORDER BY t.rel_path
+const listTracksNeedingLoudnessForFolder = `-- name: ListTracksNeedingLoudnessForFolder :many
+SELECT id, rel_path, album_id
+FROM tracks
+WHERE folder_id = ?
+  AND deleted_at IS NULL
+  AND loudness_analyzed_at IS NULL
+ORDER BY album_id, rel_path
 `
 
-type ListPlayableTracksForArtistParams struct {
-	Column1  interface{}
-	ArtistID dbtypes.NullInt64
-	Column3  interface{}
-}
-
-type ListPlayableTracksForArtistRow struct {
-	Track    Track
-	Artist   Artist
-	Album    Album
-	Artist_2 Artist
+type ListTracksNeedingLoudnessForFolderRow struct {
+	ID      int64
+	RelPath string
+	AlbumID dbtypes.NullInt64
 }
 
-// List playable tracks for an artist (roots currently available)
-func (q *Queries) ListPlayableTracksForArtist(ctx context.Context, arg ListPlayableTracksForArtistParams) ([]ListPlayableTracksForArtistRow, error) {
-	rows, err := q.db.QueryContext(ctx, listPlayableTracksForArtist, arg.Column1, arg.ArtistID, arg.Column3)
+// Live tracks in a folder that the loudness pass has not analyzed, grouped by album
+func (q *Queries) ListTracksNeedingLoudnessForFolder(ctx context.Context, folderID int64) ([]ListTracksNeedingLoudnessForFolderRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTracksNeedingLoudnessForFolder, folderID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ListPlayableTracksForArtistRow
+	var items []ListTracksNeedingLoudnessForFolderRow
 	for rows.Next() {
-		var i ListPlayableTracksForArtistRow
-		if err := rows.Scan(
-			&i.Track.ID,
-			&i.Track.FolderID,
-			&i.Track.ArtistID,
-			&i.Track.AlbumID,
-			&i.Track.RelPath,
-			&i.Track.Title,
-			&i.Track.Filename,
-			&i.Track.Ext,
-			&i.Track.Genre,
-			&i.Track.Year,
-			&i.Track.Rating,
-			&i.Track.ImagePath,
-			&i.Track.SizeBytes,
-			&i.Track.LastModified,
-			&i.Track.DurationSeconds,
-			&i.Track.LastSeenAt,
-			&i.Track.DeletedAt,
-			&i.Track.CreatedAt,
-			&i.Track.UpdatedAt,
-			&i.Artist.ID,
-			&i.Artist.Name,
-			&i.Artist.DeletedAt,
-			&i.Artist.CreatedAt,
-			&i.Artist.UpdatedAt,
-			&i.Album.ID,
-			&i.Album.ArtistID,
-			&i.Album.Title,
-			&i.Album.ImagePath,
-			&i.Album.DeletedAt,
-			&i.Album.CreatedAt,
-			&i.Album.UpdatedAt,
-			&i.Artist_2.ID,
-			&i.Artist_2.Name,
-			&i.Artist_2.DeletedAt,
-			&i.Artist_2.CreatedAt,
-			&i.Artist_2.UpdatedAt,
-		); err != nil {
+		var i ListTracksNeedingLoudnessForFolderRow
+		if err := rows.Scan(&i.ID, &i.RelPath, &i.AlbumID); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -773,58 +852,37 @@ func (q *Queries) ListPlayableTracksForArtist(ctx context.Context, arg ListPlaya
 	return items, nil
 }
 
-const listPlayableTracksForArtistBase = `-- name: ListPlayableTracksForArtistBase :many
-SELECT t.id, t.folder_id, t.artist_id, t.album_id, t.rel_path, t.title, t.filename, t.ext, t.genre, t.year, t.rating, t.image_path, t.size_bytes, t.last_modified, t.duration_seconds, t.last_seen_at, t.deleted_at, t.created_at, t.updated_at
-FROM tracks t
-JOIN folders f ON f.id = t.folder_id
-WHERE t.deleted_at IS NULL
-  AND f.deleted_at IS NULL
-  AND (?1 = 1 OR f.available = 1)
-  AND t.artist_id = ?2
-  AND (?3 IS NULL OR t.filename LIKE (?3 || '%'))
-ORDER BY t.rel_path
+const markMissingTracksForFolder = `-- name: MarkMissingTracksForFolder :many
+UPDATE tracks
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE folder_id = ?1
+  AND deleted_at IS NULL
+  AND last_seen_at < datetime(?2)
+RETURNING album_id
 `
 
-type ListPlayableTracksForArtistBaseParams struct {
-	Column1  interface{}
-	ArtistID dbtypes.NullInt64
-	Column3  interface{}
+type MarkMissingTracksForFolderParams struct {
+	FolderID      int64
+	ScanStartedAt interface{}
 }
 
-// List playable tracks for an artist without joins (roots currently available)
-func (q *Queries) ListPlayableTracksForArtistBase(ctx context.Context, arg ListPlayableTracksForArtistBaseParams) ([]Track, error) {
-	rows, err := q.db.QueryContext(ctx, listPlayableTracksForArtistBase, arg.Column1, arg.ArtistID, arg.Column3)
+// Mark tracks missing if not seen during this scan pass.
+// Pass scan_start_time from StartFolderScan (folders.last_scan_at returned value).
+// Bind scan_started_at as UTC text; datetime() keeps it comparable with CURRENT_TIMESTAMP values.
+// Returns the album of each track marked, so only those albums need their rollups refreshed.
+func (q *Queries) MarkMissingTracksForFolder(ctx context.Context, arg MarkMissingTracksForFolderParams) ([]dbtypes.NullInt64, error) {
+	rows, err := q.db.QueryContext(ctx, markMissingTracksForFolder, arg.FolderID, arg.ScanStartedAt)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Track
+	var items []dbtypes.NullInt64
 	for rows.Next() {
-		var i Track
-		if err := rows.Scan(
-			&i.ID,
-			&i.FolderID,
-			&i.ArtistID,
-			&i.AlbumID,
-			&i.RelPath,
-			&i.Title,
-			&i.Filename,
-			&i.Ext,
-			&i.Genre,
-			&i.Year,
-			&i.Rating,
-			&i.ImagePath,
-			&i.SizeBytes,
-			&i.LastModified,
-			&i.DurationSeconds,
-			&i.LastSeenAt,
-			&i.DeletedAt,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-		); err != nil {
+		var album_id dbtypes.NullInt64
+		if err := rows.Scan(&album_id); err != nil {
 			return nil, err
 		}
-		items = append(items, i)
+		items = append(items, album_id)
 	}
 	if err := rows.Close(); err != nil {
 		return nil, err
@@ -835,46 +893,231 @@ func (q *Queries) ListPlayableTracksForArtistBase(ctx context.Context, arg ListP
 	return items, nil
 }
 
-const listPlayableTracksWithJoins = `-- name: ListPlayableTracksWithJoins :many
+const moveAlbumTracks = `-- name: MoveAlbumTracks :execrows
+UPDATE tracks
+SET album_id = ?1,
+    album_pinned = 1
+WHERE album_id = ?2
+`
+
+type MoveAlbumTracksParams struct {
+	TargetID dbtypes.NullInt64
+	SourceID dbtypes.NullInt64
+}
+
+// Move every track of an album into another album and pin it there (manual merge)
+func (q *Queries) MoveAlbumTracks(ctx context.Context, arg MoveAlbumTracksParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, moveAlbumTracks, arg.TargetID, arg.SourceID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const moveTrackToAlbum = `-- name: MoveTrackToAlbum :execrows
+UPDATE tracks
+SET album_id = ?1,
+    album_pinned = 1
+WHERE id = ?2
+  AND album_id = ?3
+  AND deleted_at IS NULL
+`
+
+type MoveTrackToAlbumParams struct {
+	TargetID dbtypes.NullInt64
+	ID       int64
+	SourceID dbtypes.NullInt64
+}
+
+// Move one track into another album and pin it there (manual split)
+func (q *Queries) MoveTrackToAlbum(ctx context.Context, arg MoveTrackToAlbumParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, moveTrackToAlbum, arg.TargetID, arg.ID, arg.SourceID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const queryTracks = `-- name: QueryTracks :many
 SELECT
-  t.id, t.folder_id, t.artist_id, t.album_id, t.rel_path, t.title, t.filename, t.ext, t.genre, t.year, t.rating, t.image_path, t.size_bytes, t.last_modified, t.duration_seconds, t.last_seen_at, t.deleted_at, t.created_at, t.updated_at,
-  ar.id, ar.name, ar.deleted_at, ar.created_at, ar.updated_at,
-  al.id, al.artist_id, al.title, al.image_path, al.deleted_at, al.created_at, al.updated_at,
-  al_ar.id, al_ar.name, al_ar.deleted_at, al_ar.created_at, al_ar.updated_at
+  t.id, t.folder_id, t.artist_id, t.album_id, t.rel_path, t.title, t.filename, t.ext, t.genre, t.year, t.rating, t.image_path, t.size_bytes, t.last_modified, t.duration_seconds, t.last_seen_at, t.deleted_at, t.created_at, t.updated_at, t.track_number, t.track_total, t.disc_number, t.disc_total, t.album_artist, t.composer, t.comment, t.lyrics, t.bpm, t.compilation, t.album_pinned, t.release_date, t.play_count, t.last_played_at, t.loudness_lufs, t.peak_dbtp, t.replay_gain_db, t.loudness_analyzed_at, t.album_gain_db, t.album_peak_dbtp, t.duration_ms, t.sample_rate, t.bit_depth, t.channels, t.codec, t.bitrate, t.encoder_delay, t.encoder_padding, t.sample_count, t.lossless,
+  ar.name AS artist_name,
+  al.artist_id AS album_artist_id,
+  al.title AS album_title,
+  al.image_path AS album_image_path,
+  COUNT(*) OVER () AS total_count
 FROM tracks t
 JOIN folders f ON f.id = t.folder_id
 LEFT JOIN artists ar ON ar.id = t.artist_id
 LEFT JOIN albums al ON al.id = t.album_id
-LEFT JOIN artists al_ar ON al_ar.id = al.artist_id
 WHERE t.deleted_at IS NULL
   AND f.deleted_at IS NULL
   AND (?1 = 1 OR f.available = 1)
   AND (?2 IS NULL OR t.filename LIKE (?2 || '%'))
-ORDER BY t.rel_path
+  AND (?3 IS NULL OR t.album_id = ?3)
+  AND (?4 IS NULL OR t.artist_id = ?4)
+  AND (?5 IS NULL OR t.id IN (SELECT rowid FROM tracks_fts WHERE tracks_fts MATCH ?5))
+  AND (?6 IS NULL OR t.genre = ?6 COLLATE NOCASE)
+  AND (?7 IS NULL OR ar.name = ?7 COLLATE NOCASE)
+  AND (?8 IS NULL OR al.title = ?8 COLLATE NOCASE)
+  AND (?9 IS NULL OR t.ext = ?9 COLLATE NOCASE)
+  AND (?10 IS NULL OR t.year >= ?10)
+  AND (?11 IS NULL OR t.year <= ?11)
+  AND (?12 IS NULL OR t.rating >= ?12)
+  AND (?13 IS NULL OR t.rating <= ?13)
+  AND (?14 IS NULL OR t.duration_seconds >= ?14)
+  AND (?15 IS NULL OR t.duration_seconds <= ?15)
+  AND (?16 IS NULL OR t.bpm >= ?16)
+  AND (?17 IS NULL OR t.bpm <= ?17)
+  AND (?18 IS NULL OR t.created_at >= ?18)
+  AND (?19 IS NULL OR t.created_at <= ?19)
+  AND (?20 IS NULL OR t.play_count >= ?20)
+  AND (?21 IS NULL OR t.play_count <= ?21)
+  AND (?22 IS NULL OR t.last_played_at >= ?22)
+  AND (?23 IS NULL OR t.last_played_at <= ?23)
+  AND (?24 IS NULL OR t.codec = ?24 COLLATE NOCASE)
+  AND (?25 IS NULL OR t.lossless = ?25)
+  AND (?26 IS NULL OR t.bitrate >= ?26)
+  AND (?27 IS NULL OR t.bitrate <= ?27)
+  AND (?28 IS NULL OR t.sample_rate >= ?28)
+  AND (?29 IS NULL OR t.sample_rate <= ?29)
+  AND (?30 IS NULL OR t.bit_depth >= ?30)
+  AND (?31 IS NULL OR t.bit_depth <= ?31)
+  AND (?32 IS NULL OR t.channels >= ?32)
+  AND (?33 IS NULL OR t.channels <= ?33)
+ORDER BY
+  CASE WHEN ?34 = 'title' AND ?35 = 'asc' THEN t.title END ASC,
+  CASE WHEN ?34 = 'title' AND ?35 = 'desc' THEN t.title END DESC,
+  CASE WHEN ?34 = 'artist' AND ?35 = 'asc' THEN ar.name END ASC,
+  CASE WHEN ?34 = 'artist' AND ?35 = 'desc' THEN ar.name END DESC,
+  CASE WHEN ?34 = 'album' AND ?35 = 'asc' THEN al.title END ASC,
+  CASE WHEN ?34 = 'album' AND ?35 = 'desc' THEN al.title END DESC,
+  CASE WHEN ?34 = 'year' THEN t.year IS NULL END,
+  CASE WHEN ?34 = 'year' AND ?35 = 'asc' THEN t.year END ASC,
+  CASE WHEN ?34 = 'year' AND ?35 = 'desc' THEN t.year END DESC,
+  CASE WHEN ?34 = 'rating' THEN t.rating IS NULL END,
+  CASE WHEN ?34 = 'rating' AND ?35 = 'asc' THEN t.rating END ASC,
+  CASE WHEN ?34 = 'rating' AND ?35 = 'desc' THEN t.rating END DESC,
+  CASE WHEN ?34 = 'duration' THEN t.duration_seconds IS NULL END,
+  CASE WHEN ?34 = 'duration' AND ?35 = 'asc' THEN t.duration_seconds END ASC,
+  CASE WHEN ?34 = 'duration' AND ?35 = 'desc' THEN t.duration_seconds END DESC,
+  CASE WHEN ?34 = 'added' AND ?35 = 'asc' THEN t.created_at END ASC,
+  CASE WHEN ?34 = 'added' AND ?35 = 'desc' THEN t.created_at END DESC,
+  CASE WHEN ?34 = 'plays' AND ?35 = 'asc' THEN t.play_count END ASC,
+  CASE WHEN ?34 = 'plays' AND ?35 = 'desc' THEN t.play_count END DESC,
+  CASE WHEN ?34 = 'played' THEN t.last_played_at IS NULL END,
+  CASE WHEN ?34 = 'played' AND ?35 = 'asc' THEN t.last_played_at END ASC,
+  CASE WHEN ?34 = 'played' AND ?35 = 'desc' THEN t.last_played_at END DESC,
+  CASE WHEN ?34 = 'path' AND ?35 = 'desc' THEN t.rel_path END DESC,
+  -- Album order (disc, then track, untagged last) within an album, and for sort = 'track'.
+  CASE WHEN ?34 IN ('album', 'track') THEN t.album_id END,
+  CASE WHEN ?34 IN ('album', 'track') THEN COALESCE(t.disc_number, 1) END,
+  CASE WHEN ?34 IN ('album', 'track') THEN t.track_number IS NULL END,
+  CASE WHEN ?34 IN ('album', 'track') THEN t.track_number END,
+  t.rel_path,
+  t.id
+LIMIT ?36 OFFSET ?37
 `
 
-type ListPlayableTracksWithJoinsParams struct {
+type QueryTracksParams struct {
 	IncludeUnavailable interface{}
 	Prefix             interface{}
+	AlbumID            interface{}
+	ArtistID           interface{}
+	Text               interface{}
+	Genre              interface{}
+	Artist             interface{}
+	Album              interface{}
+	Ext                interface{}
+	YearMin            interface{}
+	YearMax            interface{}
+	RatingMin          interface{}
+	RatingMax          interface{}
+	DurationMin        interface{}
+	DurationMax        interface{}
+	BpmMin             interface{}
+	BpmMax             interface{}
+	AddedAfter         interface{}
+	AddedBefore        interface{}
+	PlaysMin           interface{}
+	PlaysMax           interface{}
+	PlayedAfter        interface{}
+	PlayedBefore       interface{}
+	Codec              interface{}
+	Lossless           interface{}
+	BitrateMin         interface{}
+	BitrateMax         interface{}
+	SampleRateMin      interface{}
+	SampleRateMax      interface{}
+	BitDepthMin        interface{}
+	BitDepthMax        interface{}
+	ChannelsMin        interface{}
+	ChannelsMax        interface{}
+	Sort               interface{}
+	Order              interface{}
+	Limit              int64
+	Offset             int64
 }
 
-type ListPlayableTracksWithJoinsRow struct {
-	Track    Track
-	Artist   Artist
-	Album    Album
-	Artist_2 Artist
+type QueryTracksRow struct {
+	Track          Track
+	ArtistName     sql.NullString
+	AlbumArtistID  sql.NullInt64
+	AlbumTitle     sql.NullString
+	AlbumImagePath dbtypes.NullString
+	TotalCount     int64
 }
 
-// Default: list all playable tracks with artist/album info (roots currently available)
-func (q *Queries) ListPlayableTracksWithJoins(ctx context.Context, arg ListPlayableTracksWithJoinsParams) ([]ListPlayableTracksWithJoinsRow, error) {
-	rows, err := q.db.QueryContext(ctx, listPlayableTracksWithJoins, arg.IncludeUnavailable, arg.Prefix)
+// Tracks matching the GET /tracks filters and parsed `q` terms, sorted and paged.
+// NULL filters are ignored; text is an FTS5 expression over tracks_fts. LIMIT -1 means no limit.
+// total_count is the number of matches before LIMIT/OFFSET.
+func (q *Queries) QueryTracks(ctx context.Context, arg QueryTracksParams) ([]QueryTracksRow, error) {
+	rows, err := q.db.QueryContext(ctx, queryTracks,
+		arg.IncludeUnavailable,
+		arg.Prefix,
+		arg.AlbumID,
+		arg.ArtistID,
+		arg.Text,
+		arg.Genre,
+		arg.Artist,
+		arg.Album,
+		arg.Ext,
+		arg.YearMin,
+		arg.YearMax,
+		arg.RatingMin,
+		arg.RatingMax,
+		arg.DurationMin,
+		arg.DurationMax,
+		arg.BpmMin,
+		arg.BpmMax,
+		arg.AddedAfter,
+		arg.AddedBefore,
+		arg.PlaysMin,
+		arg.PlaysMax,
+		arg.PlayedAfter,
+		arg.PlayedBefore,
+		arg.Codec,
+		arg.Lossless,
+		arg.BitrateMin,
+		arg.BitrateMax,
+		arg.SampleRateMin,
+		arg.SampleRateMax,
+		arg.BitDepthMin,
+		arg.BitDepthMax,
+		arg.ChannelsMin,
+		arg.ChannelsMax,
+		arg.Sort,
+		arg.Order,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []ListPlayableTracksWithJoinsRow
+	var items []QueryTracksRow
 	for rows.Next() {
-		var i ListPlayableTracksWithJoinsRow
+		var i QueryTracksRow
 		if err := rows.Scan(
 			&i.Track.ID,
 			&i.Track.FolderID,
@@ -895,23 +1138,41 @@ func (q *Queries) ListPlayableTracksWithJoins(ctx context.Context, arg ListPlaya
 			&i.Track.DeletedAt,
 			&i.Track.CreatedAt,
 			&i.Track.UpdatedAt,
-			&i.Artist.ID,
-			&i.Artist.Name,
-			&i.Artist.DeletedAt,
-			&i.Artist.CreatedAt,
-			&i.Artist.UpdatedAt,
-			&i.Album.ID,
-			&i.Album.ArtistID,
-			&i.Album.Title,
-			&i.Album.ImagePath,
-			&i.Album.DeletedAt,
-			&i.Album.CreatedAt,
-			&i.Album.UpdatedAt,
-			&i.Artist_2.ID,
-			&i.Artist_2.Name,
-			&i.Artist_2.DeletedAt,
-			&i.Artist_2.CreatedAt,
-			&i.Artist_2.UpdatedAt,
+			&i.Track.TrackNumber,
+			&i.Track.TrackTotal,
+			&i.Track.DiscNumber,
+			&i.Track.DiscTotal,
+			&i.Track.AlbumArtist,
+			&i.Track.Composer,
+			&i.Track.Comment,
+			&i.Track.Lyrics,
+			&i.Track.Bpm,
+			&i.Track.Compilation,
+			&i.Track.AlbumPinned,
+			&i.Track.ReleaseDate,
+			&i.Track.PlayCount,
+			&i.Track.LastPlayedAt,
+			&i.Track.LoudnessLufs,
+			&i.Track.PeakDbtp,
+			&i.Track.ReplayGainDb,
+			&i.Track.LoudnessAnalyzedAt,
+			&i.Track.AlbumGainDb,
+			&i.Track.AlbumPeakDbtp,
+			&i.Track.DurationMs,
+			&i.Track.SampleRate,
+			&i.Track.BitDepth,
+			&i.Track.Channels,
+			&i.Track.Codec,
+			&i.Track.Bitrate,
+			&i.Track.EncoderDelay,
+			&i.Track.EncoderPadding,
+			&i.Track.SampleCount,
+			&i.Track.Lossless,
+			&i.ArtistName,
+			&i.AlbumArtistID,
+			&i.AlbumTitle,
+			&i.AlbumImagePath,
+			&i.TotalCount,
 		); err != nil {
 			return nil, err
 		}
@@ -926,47 +1187,57 @@ func (q *Queries) ListPlayableTracksWithJoins(ctx context.Context, arg ListPlaya
 	return items, nil
 }
 
-const listTracksForFolder = `-- name: ListTracksForFolder :many
-SELECT id, folder_id, artist_id, album_id, rel_path, title, filename, ext, genre, year, rating, image_path, size_bytes, last_modified, duration_seconds, last_seen_at, deleted_at, created_at, updated_at
-FROM tracks
-WHERE folder_id = ? AND deleted_at IS NULL
-ORDER BY rel_path
+const setMissingDiscNumbers = `-- name: SetMissingDiscNumbers :exec
+UPDATE tracks
+SET disc_number = ?,
+    disc_total = NULLIF(disc_total, 1)
+WHERE album_id = ?
+  AND (disc_number IS NULL OR COALESCE(disc_total, 1) <= 1)
 `
 
-// List tracks for a folder (excluding deleted)
-func (q *Queries) ListTracksForFolder(ctx context.Context, folderID int64) ([]Track, error) {
-	rows, err := q.db.QueryContext(ctx, listTracksForFolder, folderID)
+type SetMissingDiscNumbersParams struct {
+	DiscNumber dbtypes.NullInt64
+	AlbumID    dbtypes.NullInt64
+}
+
+// Set the disc number for an album's tracks that have none or are tagged as a
+// single-disc set ("1/1", or no disc total)
+func (q *Queries) SetMissingDiscNumbers(ctx context.Context, arg SetMissingDiscNumbersParams) error {
+	_, err := q.db.ExecContext(ctx, setMissingDiscNumbers, arg.DiscNumber, arg.AlbumID)
+	return err
+}
+
+const softDeleteTracksUnderPath = `-- name: SoftDeleteTracksUnderPath :many
+UPDATE tracks
+SET deleted_at = CURRENT_TIMESTAMP
+WHERE folder_id = ?1
+  AND deleted_at IS NULL
+  AND (rel_path = ?2
+    OR substr(rel_path, 1, length(?2) + 1) = ?2 || '/')
+RETURNING album_id
+`
+
+type SoftDeleteTracksUnderPathParams struct {
+	FolderID int64
+	RelPath  string
+}
+
+// Soft delete a removed file, or every track under a removed directory. The prefix is
+// compared exactly (LIKE would ignore case and treat _ and % as wildcards). Returns the
+// album of each track removed.
+func (q *Queries) SoftDeleteTracksUnderPath(ctx context.Context, arg SoftDeleteTracksUnderPathParams) ([]dbtypes.NullInt64, error) {
+	rows, err := q.db.QueryContext(ctx, softDeleteTracksUnderPath, arg.FolderID, arg.RelPath)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Track
+	var items []dbtypes.NullInt64
 	for rows.Next() {
-		var i Track
-		if err := rows.Scan(
-			&i.ID,
-			&i.FolderID,
-			&i.ArtistID,
-			&i.AlbumID,
-			&i.RelPath,
-			&i.Title,
-			&i.Filename,
-			&i.Ext,
-			&i.Genre,
-			&i.Year,
-			&i.Rating,
-			&i.ImagePath,
-			&i.SizeBytes,
-			&i.LastModified,
-			&i.DurationSeconds,
-			&i.LastSeenAt,
-			&i.DeletedAt,
-			&i.CreatedAt,
-			&i.UpdatedAt,
-		); err != nil {
+		var album_id dbtypes.NullInt64
+		if err := rows.Scan(&album_id); err != nil {
 			return nil, err
 		}
-		items = append(items, i)
+		items = append(items, album_id)
 	}
 	if err := rows.Close(); err != nil {
 		return nil, err
@@ -977,23 +1248,74 @@ func (q *Queries) ListTracksForFolder(ctx context.Context, folderID int64) ([]Tr
 	return items, nil
 }
 
-const markMissingTracksForFolder = `-- name: MarkMissingTracksForFolder :exec
+const touchTrackSeen = `-- name: TouchTrackSeen :exec
 UPDATE tracks
-SET deleted_at = CURRENT_TIMESTAMP
-WHERE folder_id = ?
-  AND deleted_at IS NULL
-  AND last_seen_at < ?
+SET last_seen_at = CURRENT_TIMESTAMP
+WHERE id = ?
 `
 
-type MarkMissingTracksForFolderParams struct {
-	FolderID   int64
-	LastSeenAt time.Time
+// Bump last_seen_at for a file that is unchanged since the last scan
+func (q *Queries) TouchTrackSeen(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, touchTrackSeen, id)
+	return err
 }
 
-// Mark tracks missing if not seen during this scan pass.
-// Pass scan_start_time from StartFolderScan (folders.last_scan_at returned value).
-func (q *Queries) MarkMissingTracksForFolder(ctx context.Context, arg MarkMissingTracksForFolderParams) error {
-	_, err := q.db.ExecContext(ctx, markMissingTracksForFolder, arg.FolderID, arg.LastSeenAt)
+const unpinTrackAlbum = `-- name: UnpinTrackAlbum :exec
+UPDATE tracks
+SET album_pinned = 0
+WHERE id = ?
+`
+
+// Release a track from a manual merge or split so scans place it by its tags again
+func (q *Queries) UnpinTrackAlbum(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, unpinTrackAlbum, id)
+	return err
+}
+
+const updateTrackAudio = `-- name: UpdateTrackAudio :exec
+UPDATE tracks
+SET duration_ms = ?,
+  sample_rate = ?,
+  bit_depth = ?,
+  channels = ?,
+  codec = ?,
+  lossless = ?,
+  bitrate = ?,
+  encoder_delay = ?,
+  encoder_padding = ?,
+  sample_count = ?
+WHERE id = ?
+`
+
+type UpdateTrackAudioParams struct {
+	DurationMs     dbtypes.NullInt64
+	SampleRate     dbtypes.NullInt64
+	BitDepth       dbtypes.NullInt64
+	Channels       dbtypes.NullInt64
+	Codec          dbtypes.NullString
+	Lossless       dbtypes.NullInt64
+	Bitrate        dbtypes.NullInt64
+	EncoderDelay   dbtypes.NullInt64
+	EncoderPadding dbtypes.NullInt64
+	SampleCount    dbtypes.NullInt64
+	ID             int64
+}
+
+// Store the audio properties and gapless info read by ffprobe
+func (q *Queries) UpdateTrackAudio(ctx context.Context, arg UpdateTrackAudioParams) error {
+	_, err := q.db.ExecContext(ctx, updateTrackAudio,
+		arg.DurationMs,
+		arg.SampleRate,
+		arg.BitDepth,
+		arg.Channels,
+		arg.Codec,
+		arg.Lossless,
+		arg.Bitrate,
+		arg.EncoderDelay,
+		arg.EncoderPadding,
+		arg.SampleCount,
+		arg.ID,
+	)
 	return err
 }
 
@@ -1002,7 +1324,7 @@ UPDATE tracks
 SET image_path = ?
 WHERE id = ?
   AND deleted_at IS NULL
-RETURNING id, folder_id, artist_id, album_id, rel_path, title, filename, ext, genre, year, rating, image_path, size_bytes, last_modified, duration_seconds, last_seen_at, deleted_at, created_at, updated_at
+RETURNING id, folder_id, artist_id, album_id, rel_path, title, filename, ext, genre, year, rating, image_path, size_bytes, last_modified, duration_seconds, last_seen_at, deleted_at, created_at, updated_at, track_number, track_total, disc_number, disc_total, album_artist, composer, comment, lyrics, bpm, compilation, album_pinned, release_date, play_count, last_played_at, loudness_lufs, peak_dbtp, replay_gain_db, loudness_analyzed_at, album_gain_db, album_peak_dbtp, duration_ms, sample_rate, bit_depth, channels, codec, bitrate, encoder_delay, encoder_padding, sample_count, lossless
 `
 
 type UpdateTrackImagePathParams struct {
@@ -1034,16 +1356,76 @@ func (q *Queries) UpdateTrackImagePath(ctx context.Context, arg UpdateTrackImage
 		&i.DeletedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.TrackNumber,
+		&i.TrackTotal,
+		&i.DiscNumber,
+		&i.DiscTotal,
+		&i.AlbumArtist,
+		&i.Composer,
+		&i.Comment,
+		&i.Lyrics,
+		&i.Bpm,
+		&i.Compilation,
+		&i.AlbumPinned,
+		&i.ReleaseDate,
+		&i.PlayCount,
+		&i.LastPlayedAt,
+		&i.LoudnessLufs,
+		&i.PeakDbtp,
+		&i.ReplayGainDb,
+		&i.LoudnessAnalyzedAt,
+		&i.AlbumGainDb,
+		&i.AlbumPeakDbtp,
+		&i.DurationMs,
+		&i.SampleRate,
+		&i.BitDepth,
+		&i.Channels,
+		&i.Codec,
+		&i.Bitrate,
+		&i.EncoderDelay,
+		&i.EncoderPadding,
+		&i.SampleCount,
+		&i.Lossless,
 	)
 	return i, err
 }
 
+const updateTrackLoudness = `-- name: UpdateTrackLoudness :exec
+UPDATE tracks
+SET loudness_lufs = ?,
+  peak_dbtp = ?,
+  replay_gain_db = ?,
+  loudness_analyzed_at = CURRENT_TIMESTAMP
+WHERE id = ?
+`
+
+type UpdateTrackLoudnessParams struct {
+	LoudnessLufs sql.NullFloat64
+	PeakDbtp     sql.NullFloat64
+	ReplayGainDb sql.NullFloat64
+	ID           int64
+}
+
+// Store a loudness analysis; NULL values record a file that could not be analyzed
+func (q *Queries) UpdateTrackLoudness(ctx context.Context, arg UpdateTrackLoudnessParams) error {
+	_, err := q.db.ExecContext(ctx, updateTrackLoudness,
+		arg.LoudnessLufs,
+		arg.PeakDbtp,
+		arg.ReplayGainDb,
+		arg.ID,
+	)
+	return err
+}
+
 const updateTrackMetadata = `-- name: UpdateTrackMetadata :one
 UPDATE tracks
-SET artist_id = ?, album_id = ?, title = ?, genre = ?, year = ?, image_path = COALESCE(?, image_path), duration_seconds = COALESCE(?, duration_seconds)
+SET artist_id = ?, album_id = ?, title = ?, genre = ?, year = ?, image_path = COALESCE(?, image_path), duration_seconds = COALESCE(?, duration_seconds),
+  track_number = ?, track_total = ?, disc_number = ?, disc_total = ?,
+  album_artist = ?, composer = ?, comment = ?, lyrics = ?, bpm = ?, compilation = ?,
+  release_date = ?, rating = COALESCE(?, rating)
 WHERE id = ?
   AND deleted_at IS NULL
-RETURNING id, folder_id, artist_id, album_id, rel_path, title, filename, ext, genre, year, rating, image_path, size_bytes, last_modified, duration_seconds, last_seen_at, deleted_at, created_at, updated_at
+RETURNING id, folder_id, artist_id, album_id, rel_path, title, filename, ext, genre, year, rating, image_path, size_bytes, last_modified, duration_seconds, last_seen_at, deleted_at, created_at, updated_at, track_number, track_total, disc_number, disc_total, album_artist, composer, comment, lyrics, bpm, compilation, album_pinned, release_date, play_count, last_played_at, loudness_lufs, peak_dbtp, replay_gain_db, loudness_analyzed_at, album_gain_db, album_peak_dbtp, duration_ms, sample_rate, bit_depth, channels, codec, bitrate, encoder_delay, encoder_padding, sample_count, lossless
 `
 
 type UpdateTrackMetadataParams struct {
@@ -1054,6 +1436,18 @@ type UpdateTrackMetadataParams struct {
 	Year            dbtypes.NullInt64
 	ImagePath       dbtypes.NullString
 	DurationSeconds dbtypes.NullInt64
+	TrackNumber     dbtypes.NullInt64
+	TrackTotal      dbtypes.NullInt64
+	DiscNumber      dbtypes.NullInt64
+	DiscTotal       dbtypes.NullInt64
+	AlbumArtist     dbtypes.NullString
+	Composer        dbtypes.NullString
+	Comment         dbtypes.NullString
+	Lyrics          dbtypes.NullString
+	Bpm             dbtypes.NullInt64
+	Compilation     int64
+	ReleaseDate     dbtypes.NullString
+	Rating          dbtypes.NullInt64
 	ID              int64
 }
 
@@ -1067,6 +1461,18 @@ func (q *Queries) UpdateTrackMetadata(ctx context.Context, arg UpdateTrackMetada
 		arg.Year,
 		arg.ImagePath,
 		arg.DurationSeconds,
+		arg.TrackNumber,
+		arg.TrackTotal,
+		arg.DiscNumber,
+		arg.DiscTotal,
+		arg.AlbumArtist,
+		arg.Composer,
+		arg.Comment,
+		arg.Lyrics,
+		arg.Bpm,
+		arg.Compilation,
+		arg.ReleaseDate,
+		arg.Rating,
 		arg.ID,
 	)
 	var i Track
@@ -1090,6 +1496,36 @@ func (q *Queries) UpdateTrackMetadata(ctx context.Context, arg UpdateTrackMetada
 		&i.DeletedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.TrackNumber,
+		&i.TrackTotal,
+		&i.DiscNumber,
+		&i.DiscTotal,
+		&i.AlbumArtist,
+		&i.Composer,
+		&i.Comment,
+		&i.Lyrics,
+		&i.Bpm,
+		&i.Compilation,
+		&i.AlbumPinned,
+		&i.ReleaseDate,
+		&i.PlayCount,
+		&i.LastPlayedAt,
+		&i.LoudnessLufs,
+		&i.PeakDbtp,
+		&i.ReplayGainDb,
+		&i.LoudnessAnalyzedAt,
+		&i.AlbumGainDb,
+		&i.AlbumPeakDbtp,
+		&i.DurationMs,
+		&i.SampleRate,
+		&i.BitDepth,
+		&i.Channels,
+		&i.Codec,
+		&i.Bitrate,
+		&i.EncoderDelay,
+		&i.EncoderPadding,
+		&i.SampleCount,
+		&i.Lossless,
 	)
 	return i, err
 }
@@ -1099,7 +1535,7 @@ UPDATE tracks
 SET rating = ?
 WHERE id = ?
   AND deleted_at IS NULL
-RETURNING id, folder_id, artist_id, album_id, rel_path, title, filename, ext, genre, year, rating, image_path, size_bytes, last_modified, duration_seconds, last_seen_at, deleted_at, created_at, updated_at
+RETURNING id, folder_id, artist_id, album_id, rel_path, title, filename, ext, genre, year, rating, image_path, size_bytes, last_modified, duration_seconds, last_seen_at, deleted_at, created_at, updated_at, track_number, track_total, disc_number, disc_total, album_artist, composer, comment, lyrics, bpm, compilation, album_pinned, release_date, play_count, last_played_at, loudness_lufs, peak_dbtp, replay_gain_db, loudness_analyzed_at, album_gain_db, album_peak_dbtp, duration_ms, sample_rate, bit_depth, channels, codec, bitrate, encoder_delay, encoder_padding, sample_count, lossless
 `
 
 type UpdateTrackRatingParams struct {
@@ -1131,6 +1567,36 @@ func (q *Queries) UpdateTrackRating(ctx context.Context, arg UpdateTrackRatingPa
 		&i.DeletedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.TrackNumber,
+		&i.TrackTotal,
+		&i.DiscNumber,
+		&i.DiscTotal,
+		&i.AlbumArtist,
+		&i.Composer,
+		&i.Comment,
+		&i.Lyrics,
+		&i.Bpm,
+		&i.Compilation,
+		&i.AlbumPinned,
+		&i.ReleaseDate,
+		&i.PlayCount,
+		&i.LastPlayedAt,
+		&i.LoudnessLufs,
+		&i.PeakDbtp,
+		&i.ReplayGainDb,
+		&i.LoudnessAnalyzedAt,
+		&i.AlbumGainDb,
+		&i.AlbumPeakDbtp,
+		&i.DurationMs,
+		&i.SampleRate,
+		&i.BitDepth,
+		&i.Channels,
+		&i.Codec,
+		&i.Bitrate,
+		&i.EncoderDelay,
+		&i.EncoderPadding,
+		&i.SampleCount,
+		&i.Lossless,
 	)
 	return i, err
 }
@@ -1148,8 +1614,13 @@ ON CONFLICT(folder_id, rel_path) DO UPDATE SET
   size_bytes    = excluded.size_bytes,
   last_modified = excluded.last_modified,
   last_seen_at  = CURRENT_TIMESTAMP,
-  deleted_at    = NULL
-RETURNING id, folder_id, artist_id, album_id, rel_path, title, filename, ext, genre, year, rating, image_path, size_bytes, last_modified, duration_seconds, last_seen_at, deleted_at, created_at, updated_at
+  deleted_at    = NULL,
+  -- A changed file needs analyzing again.
+  loudness_lufs        = CASE WHEN tracks.size_bytes = excluded.size_bytes AND tracks.last_modified = excluded.last_modified THEN tracks.loudness_lufs END,
+  peak_dbtp            = CASE WHEN tracks.size_bytes = excluded.size_bytes AND tracks.last_modified = excluded.last_modified THEN tracks.peak_dbtp END,
+  replay_gain_db       = CASE WHEN tracks.size_bytes = excluded.size_bytes AND tracks.last_modified = excluded.last_modified THEN tracks.replay_gain_db END,
+  loudness_analyzed_at = CASE WHEN tracks.size_bytes = excluded.size_bytes AND tracks.last_modified = excluded.last_modified THEN tracks.loudness_analyzed_at END
+RETURNING id, folder_id, artist_id, album_id, rel_path, title, filename, ext, genre, year, rating, image_path, size_bytes, last_modified, duration_seconds, last_seen_at, deleted_at, created_at, updated_at, track_number, track_total, disc_number, disc_total, album_artist, composer, comment, lyrics, bpm, compilation, album_pinned, release_date, play_count, last_played_at, loudness_lufs, peak_dbtp, replay_gain_db, loudness_analyzed_at, album_gain_db, album_peak_dbtp, duration_ms, sample_rate, bit_depth, channels, codec, bitrate, encoder_delay, encoder_padding, sample_count, lossless
 `
 
 type UpsertTrackParams struct {
@@ -1195,6 +1666,36 @@ func (q *Queries) UpsertTrack(ctx context.Context, arg UpsertTrackParams) (Track
 		&i.DeletedAt,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.TrackNumber,
+		&i.TrackTotal,
+		&i.DiscNumber,
+		&i.DiscTotal,
+		&i.AlbumArtist,
+		&i.Composer,
+		&i.Comment,
+		&i.Lyrics,
+		&i.Bpm,
+		&i.Compilation,
+		&i.AlbumPinned,
+		&i.ReleaseDate,
+		&i.PlayCount,
+		&i.LastPlayedAt,
+		&i.LoudnessLufs,
+		&i.PeakDbtp,
+		&i.ReplayGainDb,
+		&i.LoudnessAnalyzedAt,
+		&i.AlbumGainDb,
+		&i.AlbumPeakDbtp,
+		&i.DurationMs,
+		&i.SampleRate,
+		&i.BitDepth,
+		&i.Channels,
+		&i.Codec,
+		&i.Bitrate,
+		&i.EncoderDelay,
+		&i.EncoderPadding,
+		&i.SampleCount,
+		&i.Lossless,
 	)
 	return i, err
 }
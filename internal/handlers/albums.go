@@ -4,11 +4,14 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"bottomley.ian/musicserver/internal/db"
+	"bottomley.ian/musicserver/internal/dbtypes"
+	"bottomley.ian/musicserver/internal/services/scanner"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -18,13 +21,30 @@ type updateAlbumRequest struct {
 	Title    string `json:"title"`
 }
 
+type mergeAlbumsRequest struct {
+	AlbumIDs []int64 `json:"album_ids"`
+}
+
+type splitAlbumRequest struct {
+	TrackIDs []int64 `json:"track_ids"`
+	Title    string  `json:"title"`
+}
+
+var albumSorts = []string{"title", "year", "artist", "added", "duration"}
+
 // ListAlbums godoc
 // @Summary List albums
 // @Tags albums
 // @Produce json
 // @Param startswith query string false "Prefix filter on title"
 // @Param include_unavailable query bool false "Include albums whose tracks are in unavailable folders (default: false)"
+// @Param decade query string false "Only albums from this decade, e.g. 1990 or 1990s"
+// @Param sort query string false "Sort field (default: title)" Enums(title,year,artist,added,duration)
+// @Param order query string false "Sort order (default: asc)" Enums(asc,desc)
+// @Param limit query int false "Maximum number of albums (1-1000; default: all)"
+// @Param offset query int false "Number of albums to skip (default: 0)"
 // @Success 200 {array} AlbumDTO
+// @Header 200 {int} X-Total-Count "Number of matching albums before limit/offset"
 // @Router /albums [get]
 func (h *Handlers) ListAlbums(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -51,14 +71,45 @@ func (h *Handlers) ListAlbums(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	rows, err := h.App.Queries.ListAlbumsWithArtist(r.Context(), db.ListAlbumsWithArtistParams{
+	var decade sql.NullInt64
+	if raw := strings.TrimSuffix(strings.TrimSpace(r.URL.Query().Get("decade")), "s"); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed%10 != 0 || parsed < 1000 {
+			http.Error(w, "invalid decade", http.StatusBadRequest)
+			return
+		}
+		decade = sql.NullInt64{Int64: parsed, Valid: true}
+	}
+
+	page, err := parsePageParams(r, albumSorts, "title", "asc")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	params := db.ListAlbumsWithArtistParams{
 		Startswith:         startsWith,
 		IncludeUnavailable: includeUnavailable,
+		Decade:             decade,
+		Sort:               page.sort,
+		Order:              page.order,
+		Limit:              page.limit,
+		Offset:             page.offset,
+	}
+	rows, err := h.App.Queries.ListAlbumsWithArtist(r.Context(), params)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	total, err := pageTotal(rows, page, func(row db.ListAlbumsWithArtistRow) int64 { return row.TotalCount }, func() ([]db.ListAlbumsWithArtistRow, error) {
+		params.Limit, params.Offset = 1, 0
+		return h.App.Queries.ListAlbumsWithArtist(r.Context(), params)
 	})
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	writeTotalCount(w, total)
 	writeJSON(w, albumsDTOFromRows(rows))
 }
 
@@ -100,10 +151,16 @@ func (h *Handlers) GetAlbum(w http.ResponseWriter, r *http.Request) {
 // @Tags albums
 // @Produce json
 // @Param id path int true "Album ID"
+// @Param q query string false "Filter expression, as on GET /tracks"
+// @Param sort query string false "Sort field (default: track)" Enums(path,title,artist,album,track,year,rating,duration,added,plays,played)
+// @Param order query string false "Sort order (default: asc)" Enums(asc,desc)
+// @Param limit query int false "Maximum number of tracks (1-1000; default: all)"
+// @Param offset query int false "Number of tracks to skip (default: 0)"
 // @Param expand query string false "Comma-separated expansions (album,artist); defaults to none" Enums(album,artist) example(album,artist)
 // @Param startswith query string false "Prefix filter on filename"
 // @Param include_unavailable query bool false "Include tracks from unavailable folders (default: false)"
 // @Success 200 {array} TrackDTO
+// @Header 200 {int} X-Total-Count "Number of matching tracks before limit/offset"
 // @Router /albums/{id}/tracks [get]
 func (h *Handlers) ListAlbumTracks(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -134,17 +191,19 @@ func (h *Handlers) ListAlbumTracks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tracks, err := h.listTracksShared(r.Context(), &id, nil, opts)
+	tracks, total, err := h.queryTracks(r.Context(), &id, nil, opts)
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 
+	writeTotalCount(w, total)
 	writeJSON(w, filterTracks(tracks, opts))
 }
 
 // UpdateAlbum godoc
 // @Summary Update album
+// @Description Rename an album or change its album artist. The change is written into the tags of every track file on the album. Every file is rewritten into a temporary copy before the album row changes; if any copy fails, the copies are removed and neither the files nor the album change. The row is then renamed and the copies moved over the originals.
 // @Tags albums
 // @Accept json
 // @Produce json
@@ -175,12 +234,61 @@ func (h *Handlers) UpdateAlbum(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	album, err := h.App.Queries.GetAlbumByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "album not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	artist, err := h.App.Queries.GetArtistByID(r.Context(), body.ArtistID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "artist not found", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	// Files are the source of truth: refuse the edit unless every track can be rewritten.
+	files, err := h.App.Queries.ListAlbumTrackFiles(r.Context(), dbtypes.NullInt64{Int64: id, Valid: true})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	for _, f := range files {
+		if f.Available != 1 {
+			http.Error(w, "album has tracks in unavailable folders", http.StatusConflict)
+			return
+		}
+	}
+
+	edit := scanner.TagEdit{Album: &body.Title}
+	if body.ArtistID != album.ArtistID {
+		edit.AlbumArtist = &artist.Name
+	}
+	edits := make(map[int64]scanner.TagEdit, len(files))
+	for _, f := range files {
+		edits[f.ID] = edit
+	}
+	// Rewrite every file before touching the row, so a failure leaves both as they were.
+	tags, err := h.Scanner.PrepareTrackTags(r.Context(), edits)
+	if err != nil {
+		writeTagError(w, err)
+		return
+	}
+
+	// Update the row before the rewritten files are re-read so they land on this album id.
 	_, err = h.App.Queries.UpdateAlbum(r.Context(), db.UpdateAlbumParams{
 		ArtistID: body.ArtistID,
 		Title:    body.Title,
 		ID:       id,
 	})
 	if err != nil {
+		tags.Discard()
 		if errors.Is(err, sql.ErrNoRows) {
 			http.Error(w, "album not found", http.StatusNotFound)
 			return
@@ -189,6 +297,11 @@ func (h *Handlers) UpdateAlbum(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := tags.Commit(r.Context()); err != nil {
+		writeTagError(w, err)
+		return
+	}
+
 	updated, err := h.App.Queries.GetAlbumWithArtist(r.Context(), id)
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
@@ -198,6 +311,216 @@ func (h *Handlers) UpdateAlbum(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, albumDTOFromParts(updated.Album, updated.Artist))
 }
 
+// MergeAlbums godoc
+// @Summary Merge albums
+// @Description Move every track of the listed albums into this one and soft-delete them. Tracks without a disc number, or tagged as a single-disc set (1/1 or no total), are numbered in request order (this album is disc 1). Moved tracks are pinned so rescans keep them here.
+// @Tags albums
+// @Accept json
+// @Produce json
+// @Param id path int true "Target album ID"
+// @Param request body mergeAlbumsRequest true "Albums to merge into the target"
+// @Success 200 {object} AlbumDTO
+// @Router /albums/{id}/merge [post]
+func (h *Handlers) MergeAlbums(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := parseIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var body mergeAlbumsRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if len(body.AlbumIDs) == 0 {
+		http.Error(w, "album_ids required", http.StatusBadRequest)
+		return
+	}
+	seen := map[int64]struct{}{id: {}}
+	for _, src := range body.AlbumIDs {
+		if _, dup := seen[src]; dup {
+			http.Error(w, "album_ids must be distinct and exclude the target", http.StatusBadRequest)
+			return
+		}
+		seen[src] = struct{}{}
+	}
+
+	tx, err := h.App.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	queries := h.App.Queries.WithTx(tx)
+
+	albumIDs := append([]int64{id}, body.AlbumIDs...)
+	for _, albumID := range albumIDs {
+		if _, err := queries.GetAlbumByID(r.Context(), albumID); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				_ = tx.Rollback()
+				http.Error(w, "album not found", http.StatusNotFound)
+				return
+			}
+			_ = tx.Rollback()
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	target := dbtypes.NullInt64{Int64: id, Valid: true}
+	// The target itself goes through the same steps so its tracks are pinned too.
+	for disc, albumID := range albumIDs {
+		source := dbtypes.NullInt64{Int64: albumID, Valid: true}
+		if err := queries.SetMissingDiscNumbers(r.Context(), db.SetMissingDiscNumbersParams{
+			DiscNumber: dbtypes.NullInt64{Int64: int64(disc + 1), Valid: true},
+			AlbumID:    source,
+		}); err != nil {
+			_ = tx.Rollback()
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if _, err := queries.MoveAlbumTracks(r.Context(), db.MoveAlbumTracksParams{
+			TargetID: target,
+			SourceID: source,
+		}); err != nil {
+			_ = tx.Rollback()
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if albumID == id {
+			continue
+		}
+		if _, err := queries.SoftDeleteAlbum(r.Context(), albumID); err != nil {
+			_ = tx.Rollback()
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := queries.RefreshAlbumRollups(r.Context(), sql.NullInt64{Int64: id, Valid: true}); err != nil {
+		_ = tx.Rollback()
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	merged, err := h.App.Queries.GetAlbumWithArtist(r.Context(), id)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, albumDTOFromParts(merged.Album, merged.Artist))
+}
+
+// SplitAlbum godoc
+// @Summary Split album
+// @Description Move the listed tracks into an album with the given title by the same album artist, creating it if needed. Moved tracks are pinned so rescans keep them there.
+// @Tags albums
+// @Accept json
+// @Produce json
+// @Param id path int true "Album ID"
+// @Param request body splitAlbumRequest true "Tracks to move and the new album title"
+// @Success 200 {object} AlbumDTO
+// @Router /albums/{id}/split [post]
+func (h *Handlers) SplitAlbum(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := parseIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var body splitAlbumRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	title := strings.TrimSpace(body.Title)
+	if len(body.TrackIDs) == 0 || title == "" {
+		http.Error(w, "track_ids and title are required", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.App.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	queries := h.App.Queries.WithTx(tx)
+
+	album, err := queries.GetAlbumByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			_ = tx.Rollback()
+			http.Error(w, "album not found", http.StatusNotFound)
+			return
+		}
+		_ = tx.Rollback()
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	created, err := queries.UpsertAlbum(r.Context(), db.UpsertAlbumParams{
+		ArtistID: album.ArtistID,
+		Title:    title,
+	})
+	if err != nil {
+		_ = tx.Rollback()
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if created.ID == id {
+		_ = tx.Rollback()
+		http.Error(w, "title must differ from the album title", http.StatusBadRequest)
+		return
+	}
+
+	for _, trackID := range body.TrackIDs {
+		n, err := queries.MoveTrackToAlbum(r.Context(), db.MoveTrackToAlbumParams{
+			TargetID: dbtypes.NullInt64{Int64: created.ID, Valid: true},
+			ID:       trackID,
+			SourceID: dbtypes.NullInt64{Int64: id, Valid: true},
+		})
+		if err != nil {
+			_ = tx.Rollback()
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if n == 0 {
+			_ = tx.Rollback()
+			http.Error(w, fmt.Sprintf("track %d is not on album %d", trackID, id), http.StatusBadRequest)
+			return
+		}
+	}
+	for _, albumID := range []int64{id, created.ID} {
+		if err := queries.RefreshAlbumRollups(r.Context(), sql.NullInt64{Int64: albumID, Valid: true}); err != nil {
+			_ = tx.Rollback()
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	split, err := h.App.Queries.GetAlbumWithArtist(r.Context(), created.ID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, albumDTOFromParts(split.Album, split.Artist))
+}
+
 // DeleteAlbum godoc
 // @Summary Delete album
 // @Description Soft-delete an album
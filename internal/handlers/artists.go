@@ -9,6 +9,8 @@ import (
 	"strings"
 
 	"bottomley.ian/musicserver/internal/db"
+	"bottomley.ian/musicserver/internal/dbtypes"
+	"bottomley.ian/musicserver/internal/services/scanner"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -17,12 +19,20 @@ type updateArtistRequest struct {
 	Name string `json:"name"`
 }
 
+var artistSorts = []string{"name", "added"}
+
 // ListArtists godoc
 // @Summary List artists
 // @Tags artists
 // @Produce json
 // @Param startswith query string false "Prefix filter on name"
+// @Param include_compilation_only query bool false "Include artists who appear only on compilations (default: true)"
+// @Param sort query string false "Sort field (default: name)" Enums(name,added)
+// @Param order query string false "Sort order (default: asc)" Enums(asc,desc)
+// @Param limit query int false "Maximum number of artists (1-1000; default: all)"
+// @Param offset query int false "Number of artists to skip (default: 0)"
 // @Success 200 {array} ArtistDTO
+// @Header 200 {int} X-Total-Count "Number of matching artists before limit/offset"
 // @Router /artists [get]
 func (h *Handlers) ListArtists(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -36,12 +46,47 @@ func (h *Handlers) ListArtists(w http.ResponseWriter, r *http.Request) {
 		startsWith = sql.NullString{String: prefix, Valid: true}
 	}
 
-	rows, err := h.App.Queries.ListArtists(r.Context(), startsWith)
+	includeCompilationOnly := int64(1)
+	if raw := strings.TrimSpace(r.URL.Query().Get("include_compilation_only")); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			http.Error(w, "invalid include_compilation_only", http.StatusBadRequest)
+			return
+		}
+		if !parsed {
+			includeCompilationOnly = 0
+		}
+	}
+
+	page, err := parsePageParams(r, artistSorts, "name", "asc")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	params := db.ListArtistsParams{
+		Startswith:             startsWith,
+		IncludeCompilationOnly: includeCompilationOnly,
+		Sort:                   page.sort,
+		Order:                  page.order,
+		Limit:                  page.limit,
+		Offset:                 page.offset,
+	}
+	rows, err := h.App.Queries.ListArtists(r.Context(), params)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	total, err := pageTotal(rows, page, func(row db.ListArtistsRow) int64 { return row.TotalCount }, func() ([]db.ListArtistsRow, error) {
+		params.Limit, params.Offset = 1, 0
+		return h.App.Queries.ListArtists(r.Context(), params)
+	})
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
-	writeJSON(w, artistsDTOFromDB(rows))
+	writeTotalCount(w, total)
+	writeJSON(w, artistsDTOFromRows(rows))
 }
 
 // GetArtist godoc
@@ -79,6 +124,7 @@ func (h *Handlers) GetArtist(w http.ResponseWriter, r *http.Request) {
 
 // UpdateArtist godoc
 // @Summary Update artist
+// @Description Rename an artist. The new name is written into the artist and album artist tags of the artist's track files. As with album edits, every file is rewritten into a temporary copy before the artist row changes, so a failed copy leaves everything as it was.
 // @Tags artists
 // @Accept json
 // @Produce json
@@ -109,11 +155,44 @@ func (h *Handlers) UpdateArtist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Files are the source of truth: refuse the edit unless every track can be rewritten.
+	files, err := h.App.Queries.ListArtistTrackFiles(r.Context(), dbtypes.NullInt64{Int64: id, Valid: true})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	for _, f := range files {
+		if f.Available != 1 {
+			http.Error(w, "artist has tracks in unavailable folders", http.StatusConflict)
+			return
+		}
+	}
+
+	edits := make(map[int64]scanner.TagEdit, len(files))
+	for _, f := range files {
+		var edit scanner.TagEdit
+		if f.Performer == 1 {
+			edit.Artist = &body.Name
+		}
+		if f.AlbumArtist == 1 {
+			edit.AlbumArtist = &body.Name
+		}
+		edits[f.ID] = edit
+	}
+	// Rewrite every file before touching the row, so a failure leaves both as they were.
+	tags, err := h.Scanner.PrepareTrackTags(r.Context(), edits)
+	if err != nil {
+		writeTagError(w, err)
+		return
+	}
+
+	// Rename the row before the rewritten files are re-read so they keep this artist id.
 	row, err := h.App.Queries.UpdateArtist(r.Context(), db.UpdateArtistParams{
 		Name: body.Name,
 		ID:   id,
 	})
 	if err != nil {
+		tags.Discard()
 		if errors.Is(err, sql.ErrNoRows) {
 			http.Error(w, "artist not found", http.StatusNotFound)
 			return
@@ -122,6 +201,11 @@ func (h *Handlers) UpdateArtist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := tags.Commit(r.Context()); err != nil {
+		writeTagError(w, err)
+		return
+	}
+
 	writeJSON(w, artistDTOFromDB(row))
 }
 
@@ -50,10 +50,10 @@ func (h *Handlers) GetCalendarDay(w http.ResponseWriter, r *http.Request) {
 		dateParam = *dateFilter
 	}
 	rows, err := h.App.Queries.ListJournalEntries(r.Context(), db.ListJournalEntriesParams{
-		Column1: dateParam,
-		Column2: nil,
-		Column3: nil,
-		Column4: nil,
+		Date:  dateParam,
+		Sort:  "date",
+		Order: "asc",
+		Limit: -1,
 	})
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
@@ -64,6 +64,6 @@ func (h *Handlers) GetCalendarDay(w http.ResponseWriter, r *http.Request) {
 		Year:    year,
 		Month:   month,
 		Day:     day,
-		Entries: journalEntriesDTOFromDB(rows),
+		Entries: journalEntriesDTOFromRows(rows),
 	})
 }
@@ -7,6 +7,8 @@ type FolderDTO struct {
 	Path           string     `json:"path"`
 	DeletedAt      *time.Time `json:"deleted_at,omitempty"`
 	Available      bool       `json:"available"`
+	Watch          bool       `json:"watch"`
+	ScanSchedule   *string    `json:"scan_schedule,omitempty"`
 	LastSeenAt     *time.Time `json:"last_seen_at,omitempty"`
 	LastScanAt     *time.Time `json:"last_scan_at,omitempty"`
 	LastScanStatus *string    `json:"last_scan_status,omitempty"`
@@ -28,8 +30,37 @@ type TrackDTO struct {
 	Ext          string            `json:"ext"`
 	Genre        *string           `json:"genre,omitempty"`
 	Year         *int64            `json:"year,omitempty"`
+	ReleaseDate  *string           `json:"release_date,omitempty"`
+	TrackNumber  *int64            `json:"track_number,omitempty"`
+	TrackTotal   *int64            `json:"track_total,omitempty"`
+	DiscNumber   *int64            `json:"disc_number,omitempty"`
+	DiscTotal    *int64            `json:"disc_total,omitempty"`
+	AlbumArtist  *string           `json:"album_artist,omitempty"` // as tagged; may differ from album.artist
+	Composer     *string           `json:"composer,omitempty"`
+	Comment      *string           `json:"comment,omitempty"`
+	Lyrics       *string           `json:"lyrics,omitempty"`
+	BPM          *int64            `json:"bpm,omitempty"`
+	Compilation  bool              `json:"compilation"`
+	AlbumPinned  bool              `json:"album_pinned"` // album set by merge/split; rescans keep it
 	Rating       *int64            `json:"rating,omitempty"`
+	PlayCount    int64             `json:"play_count"`
+	LastPlayedAt *time.Time        `json:"last_played_at,omitempty"`
 	DurationSec  *int64            `json:"duration_seconds,omitempty"`
+	DurationMs   *int64            `json:"duration_ms,omitempty"` // exact when sample_count is known
+	SampleRate   *int64            `json:"sample_rate,omitempty"` // Hz
+	BitDepth     *int64            `json:"bit_depth,omitempty"`   // lossless and PCM only
+	Channels     *int64            `json:"channels,omitempty"`
+	Codec        *string           `json:"codec,omitempty"`           // ffprobe codec name, e.g. "flac"
+	Lossless     *bool             `json:"lossless,omitempty"`        // from the codec; absent until probed
+	Bitrate      *int64            `json:"bitrate,omitempty"`         // kbit/s
+	EncoderDelay *int64            `json:"encoder_delay,omitempty"`   // samples to skip at the start
+	EncoderPad   *int64            `json:"encoder_padding,omitempty"` // samples to drop at the end
+	SampleCount  *int64            `json:"sample_count,omitempty"`    // playable samples per channel
+	LoudnessLUFS *float64          `json:"loudness_lufs,omitempty"`   // integrated (EBU R128), from the loudness pass
+	PeakDBTP     *float64          `json:"peak_dbtp,omitempty"`       // true peak
+	TrackGainDB  *float64          `json:"track_gain_db,omitempty"`   // ReplayGain 2.0, relative to -18 LUFS
+	AlbumGainDB  *float64          `json:"album_gain_db,omitempty"`   // set once every album track is analyzed
+	AlbumPeakDB  *float64          `json:"album_peak_dbtp,omitempty"` // album true peak
 	ImagePath    *string           `json:"image_path,omitempty"`
 	SizeBytes    int64             `json:"size_bytes"`
 	LastModified int64             `json:"last_modified"`
@@ -40,11 +71,35 @@ type TrackDTO struct {
 }
 
 type ScanDTO struct {
-	FolderID   int64      `json:"folder_id"`
-	Status     string     `json:"status"` // "running" | "ok" | "error" | "skipped_unavailable"
-	StartedAt  time.Time  `json:"started_at"`
-	FinishedAt *time.Time `json:"finished_at,omitempty"`
-	Error      *string    `json:"error,omitempty"`
+	JobID        int64      `json:"job_id,omitempty"`
+	FolderID     int64      `json:"folder_id"`
+	Trigger      string     `json:"trigger,omitempty"` // "manual" | "watch" | "schedule" | "recovery"
+	Full         bool       `json:"full"`
+	Status       string     `json:"status"` // "running" | "ok" | "error" | "skipped_unavailable" | "interrupted"
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	DurationMS   *int64     `json:"duration_ms,omitempty"`
+	Error        *string    `json:"error,omitempty"`
+	FilesTotal   int64      `json:"files_total"`
+	FilesSeen    int64      `json:"files_seen"`
+	FilesChanged int64      `json:"files_changed"`
+	CurrentPath  *string    `json:"current_path,omitempty"`
+	ETASeconds   *int64     `json:"eta_seconds,omitempty"`
+
+	TracksAdded   int64 `json:"tracks_added"`
+	TracksUpdated int64 `json:"tracks_updated"`
+	TracksRemoved int64 `json:"tracks_removed"`
+	FilesFailed   int64 `json:"files_failed"`
+	// Loudness pass results; only reported for scans still in memory.
+	TracksAnalyzed int64 `json:"tracks_analyzed,omitempty"`
+}
+
+type ScanErrorDTO struct {
+	ID        int64     `json:"id"`
+	ScanRunID int64     `json:"scan_run_id"`
+	RelPath   string    `json:"rel_path"`
+	Error     string    `json:"error"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type ArtistDTO struct {
@@ -56,14 +111,24 @@ type ArtistDTO struct {
 }
 
 type AlbumDTO struct {
-	ID        int64             `json:"id"`
-	ArtistID  int64             `json:"artist_id"`
-	Artist    *ArtistSummaryDTO `json:"artist,omitempty"`
-	Title     string            `json:"title"`
-	ImagePath *string           `json:"image_path,omitempty"`
-	DeletedAt *time.Time        `json:"deleted_at,omitempty"`
-	CreatedAt time.Time         `json:"created_at"`
-	UpdatedAt time.Time         `json:"updated_at"`
+	ID          int64             `json:"id"`
+	ArtistID    int64             `json:"artist_id"`
+	Artist      *ArtistSummaryDTO `json:"artist,omitempty"`
+	Title       string            `json:"title"`
+	Compilation bool              `json:"compilation"`
+	Year        *int64            `json:"year,omitempty"`
+	ReleaseDate *string           `json:"release_date,omitempty"` // earliest track release date
+	Genre       *string           `json:"genre,omitempty"`        // most common track genre
+	TrackCount  int64             `json:"track_count"`
+	DiscCount   int64             `json:"disc_count"`
+	DurationSec int64             `json:"duration_seconds"`
+	Loudness    *float64          `json:"loudness_lufs,omitempty"` // set once every track is analyzed
+	PeakDBTP    *float64          `json:"peak_dbtp,omitempty"`
+	GainDB      *float64          `json:"gain_db,omitempty"` // ReplayGain 2.0 album gain
+	ImagePath   *string           `json:"image_path,omitempty"`
+	DeletedAt   *time.Time        `json:"deleted_at,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
 }
 
 type ArtistSummaryDTO struct {
@@ -79,11 +144,30 @@ type AlbumSummaryDTO struct {
 }
 
 type PlaylistDTO struct {
-	ID        int64      `json:"id"`
-	Name      string     `json:"name"`
-	DeletedAt *time.Time `json:"deleted_at,omitempty"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
+	ID        int64                  `json:"id"`
+	Name      string                 `json:"name"`
+	Type      string                 `json:"type"` // "static" | "smart"
+	Rules     *SmartPlaylistRulesDTO `json:"rules,omitempty"`
+	DeletedAt *time.Time             `json:"deleted_at,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+// SmartPlaylistRulesDTO selects a smart playlist's tracks. Rules are ANDed, and omitted
+// rules match everything. It is also the JSON stored in playlists.rules.
+type SmartPlaylistRulesDTO struct {
+	Genre           *string `json:"genre,omitempty"`
+	YearMin         *int64  `json:"year_min,omitempty"`
+	YearMax         *int64  `json:"year_max,omitempty"`
+	RatingMin       *int64  `json:"rating_min,omitempty"`
+	AddedWithinDays *int64  `json:"added_within_days,omitempty"`
+	PlayCountMin    *int64  `json:"play_count_min,omitempty"`
+	PlayCountMax    *int64  `json:"play_count_max,omitempty"`
+	NeverPlayed     bool    `json:"never_played,omitempty"`
+	Query           string  `json:"query,omitempty"` // further terms in the GET /tracks q grammar
+	Sort            string  `json:"sort,omitempty"`  // a GET /tracks sort; default "added"
+	Order           string  `json:"order,omitempty"` // default "desc"
+	Limit           int64   `json:"limit,omitempty"` // 0 for no limit
 }
 
 type PlaylistTrackDTO struct {
@@ -97,6 +181,90 @@ type PlaylistTrackDTO struct {
 	Track      *TrackDTO  `json:"track,omitempty"`
 }
 
+type PlayDTO struct {
+	ID              int64     `json:"id"`
+	TrackID         int64     `json:"track_id"`
+	PlayedAt        time.Time `json:"played_at"`
+	PositionSeconds *int64    `json:"position_seconds,omitempty"`
+	Completed       bool      `json:"completed"`
+	Counted         bool      `json:"counted"` // reached the scrobble threshold; counts towards play_count
+	Source          string    `json:"source"`  // "client" | "stream"
+	CreatedAt       time.Time `json:"created_at"`
+	Track           *TrackDTO `json:"track,omitempty"`
+}
+
+type MostPlayedTrackDTO struct {
+	Plays int64    `json:"plays"` // counted plays within the requested range
+	Track TrackDTO `json:"track"`
+}
+
+type DailyPlayStatsDTO struct {
+	Date    string `json:"date"` // YYYY-MM-DD (UTC)
+	Plays   int64  `json:"plays"`
+	Skipped int64  `json:"skipped"`
+	Tracks  int64  `json:"tracks"` // distinct tracks
+	Seconds int64  `json:"seconds"`
+}
+
+type TranscodePrewarmDTO struct {
+	Profile     string `json:"profile"`              // e.g. "mp3-192"
+	ReplayGain  string `json:"replaygain,omitempty"` // "track" or "album"
+	Tracks      int    `json:"tracks"`               // distinct tracks in the playlist
+	Cached      int    `json:"cached"`
+	Queued      int    `json:"queued"`
+	Unavailable int    `json:"unavailable"`
+}
+
+type CacheStatsDTO struct {
+	Dir       string `json:"dir"`
+	MaxBytes  int64  `json:"max_bytes"`
+	Bytes     int64  `json:"bytes"`
+	Files     int    `json:"files"`
+	Hits      int64  `json:"hits"`
+	Misses    int64  `json:"misses"`
+	Evictions int64  `json:"evictions"`
+}
+
+type TranscodeCacheStatsDTO struct {
+	Streams        CacheStatsDTO `json:"streams"` // whole-track transcodes
+	HLS            CacheStatsDTO `json:"hls"`     // HLS segments
+	PrewarmPending int64         `json:"prewarm_pending"`
+}
+
+type LibraryFormatsDTO struct {
+	Tracks           int64                `json:"tracks"`
+	Lossless         int64                `json:"lossless"`
+	HiRes            int64                `json:"hi_res"`      // above 48 kHz or 16 bits
+	Unprobed         int64                `json:"unprobed"`    // codec unknown until a full rescan
+	LowBitrate       int64                `json:"low_bitrate"` // threshold in kbit/s
+	LowBitrateTracks int64                `json:"low_bitrate_tracks"`
+	Formats          []FormatStatsDTO     `json:"formats"`
+	LowBitrateAlbums []LowBitrateAlbumDTO `json:"low_bitrate_albums"`
+}
+
+type FormatStatsDTO struct {
+	Codec            *string `json:"codec"` // null for tracks not probed yet
+	Lossless         *bool   `json:"lossless,omitempty"`
+	Tracks           int64   `json:"tracks"`
+	SizeBytes        int64   `json:"size_bytes"`
+	DurationSeconds  int64   `json:"duration_seconds"`
+	MinBitrate       int64   `json:"min_bitrate,omitempty"` // kbit/s
+	AvgBitrate       int64   `json:"avg_bitrate,omitempty"`
+	MaxBitrate       int64   `json:"max_bitrate,omitempty"`
+	LowBitrateTracks int64   `json:"low_bitrate_tracks"`
+	HiRes            int64   `json:"hi_res"`
+}
+
+type LowBitrateAlbumDTO struct {
+	ID               int64  `json:"id"`
+	ArtistID         int64  `json:"artist_id"`
+	Artist           string `json:"artist"`
+	Title            string `json:"title"`
+	TrackCount       int64  `json:"track_count"`
+	LowBitrateTracks int64  `json:"low_bitrate_tracks"`
+	MinBitrate       int64  `json:"min_bitrate"` // kbit/s
+}
+
 type DayViewDTO struct {
 	Year    int64             `json:"year"`
 	Month   int64             `json:"month"`
@@ -186,3 +354,16 @@ type JournalEntryDTO struct {
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
+
+// SearchResultDTO is one GET /search hit. Score is higher for better matches, relative
+// to the best hit of the same type, which scores 1.
+type SearchResultDTO struct {
+	Type        string  `json:"type"` // track, album, artist or journal_entry
+	ID          int64   `json:"id"`
+	Title       string  `json:"title"`
+	Snippet     *string `json:"snippet,omitempty"`
+	Artist      *string `json:"artist,omitempty"`
+	Album       *string `json:"album,omitempty"`
+	JournalDate *string `json:"journal_date,omitempty"`
+	Score       float64 `json:"score"`
+}
@@ -1,13 +1,12 @@
 package handlers
 
 import (
-	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
-	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 
@@ -113,6 +112,8 @@ func (h *Handlers) DeleteFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A running scan holds a write transaction; canceling it first releases the lock.
+	h.Scanner.CancelScan(id)
 	_, err = h.App.Queries.SoftDeleteFolder(r.Context(), id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -122,16 +123,136 @@ func (h *Handlers) DeleteFolder(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	h.Scanner.StopWatching(id)
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+type setFolderWatchRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// SetFolderWatch godoc
+// @Summary Enable or disable folder watching
+// @Description Watch a folder root for created, modified, renamed and deleted audio files and index them as they change
+// @Tags folders
+// @Accept json
+// @Produce json
+// @Param id path int true "Folder ID"
+// @Param request body handlers.setFolderWatchRequest true "Watch setting"
+// @Success 200 {object} handlers.FolderDTO
+// @Router /folders/{id}/watch [put]
+func (h *Handlers) SetFolderWatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := parseIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var body setFolderWatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	var watch int64
+	if body.Enabled {
+		watch = 1
+	}
+	row, err := h.App.Queries.SetFolderWatch(r.Context(), db.SetFolderWatchParams{
+		Watch: watch,
+		ID:    id,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "folder not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	if body.Enabled {
+		if err := h.Scanner.StartWatching(id); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		h.Scanner.StopWatching(id)
+	}
+
+	writeJSON(w, folderDTOFromDB(row))
+}
+
+type setFolderScheduleRequest struct {
+	Schedule string `json:"schedule"`
+}
+
+// SetFolderSchedule godoc
+// @Summary Set folder rescan schedule
+// @Description Schedule periodic rescans of a folder: "nightly" or an interval in hours such as "6h". An empty schedule disables them.
+// @Tags folders
+// @Accept json
+// @Produce json
+// @Param id path int true "Folder ID"
+// @Param request body handlers.setFolderScheduleRequest true "Schedule"
+// @Success 200 {object} handlers.FolderDTO
+// @Router /folders/{id}/schedule [put]
+func (h *Handlers) SetFolderSchedule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := parseIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var body setFolderScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+
+	var schedule dbtypes.NullString
+	if raw := strings.TrimSpace(body.Schedule); raw != "" {
+		if _, err := scanner.ParseSchedule(raw); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		schedule = dbtypes.NullString{String: strings.ToLower(raw), Valid: true}
+	}
+
+	row, err := h.App.Queries.SetFolderScanSchedule(r.Context(), db.SetFolderScanScheduleParams{
+		ScanSchedule: schedule,
+		ID:           id,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "folder not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, folderDTOFromDB(row))
+}
+
 // ScanFolder godoc
 // @Summary Trigger folder scan
-// @Description Start a scan of a folder root and update indexed tracks
+// @Description Queue a background scan of a folder root; poll GET /folders/{id}/scan for progress
 // @Tags folders
+// @Produce json
 // @Param id path int true "Folder ID"
+// @Param full query bool false "Re-read tags and duration for every file, even if size and mtime are unchanged (default: false)"
+// @Param loudness query bool false "After the walk, measure loudness (EBU R128) of tracks not analyzed yet; always on with SCAN_LOUDNESS=true (default: false)"
 // @Success 202 {object} ScanDTO
+// @Failure 409 {string} string "scan already running"
 // @Router /folders/{id}/scan [post]
 func (h *Handlers) ScanFolder(w http.ResponseWriter, r *http.Request) {
 	idStr := chi.URLParam(r, "id")
@@ -153,63 +274,155 @@ func (h *Handlers) ScanFolder(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "folder not found", http.StatusNotFound)
 		return
 	}
-	startedAt, err := h.App.Queries.StartFolderScan(r.Context(), id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
 
-	ctx := r.Context()
-	err = h.Scanner.ScanFolder(ctx, id)
-	if err != nil {
-		var finishErr error
-		switch {
-		case errors.Is(err, scanner.ErrFolderUnavailable):
-			finishErr = h.App.Queries.FinishFolderScanUnavailable(ctx, db.FinishFolderScanUnavailableParams{
-				LastScanError: dbtypes.NullString{String: err.Error(), Valid: true},
-				ID:            id,
-			})
-		case errors.Is(err, context.Canceled):
-			finishErr = h.App.Queries.FinishFolderScanError(ctx, db.FinishFolderScanErrorParams{
-				LastScanError: dbtypes.NullString{String: "scan canceled", Valid: true},
-				ID:            id,
-			})
-		default:
-			finishErr = h.App.Queries.FinishFolderScanError(ctx, db.FinishFolderScanErrorParams{
-				LastScanError: dbtypes.NullString{String: err.Error(), Valid: true},
-				ID:            id,
-			})
+	opts := scanner.ScanOptions{}
+	if raw := strings.TrimSpace(r.URL.Query().Get("full")); raw != "" {
+		full, err := strconv.ParseBool(raw)
+		if err != nil {
+			http.Error(w, "invalid full", http.StatusBadRequest)
+			return
 		}
-		if finishErr != nil {
-			log.Printf("failed to record scan failure for folder %d: %v", id, finishErr)
+		opts.Full = full
+	}
+	if raw := strings.TrimSpace(r.URL.Query().Get("loudness")); raw != "" {
+		loudness, err := strconv.ParseBool(raw)
+		if err != nil {
+			http.Error(w, "invalid loudness", http.StatusBadRequest)
+			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		opts.Loudness = loudness
 	}
 
-	if err := h.App.Queries.FinishFolderScanOK(ctx, id); err != nil {
-		log.Printf("failed to record scan success for folder %d: %v", id, err)
-		http.Error(w, "failed to record scan result", http.StatusInternalServerError)
+	job, err := h.Scanner.StartScan(r.Context(), id, opts)
+	if err != nil {
+		if errors.Is(err, scanner.ErrScanRunning) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	dto := ScanDTO{
-		FolderID:  id,
-		Status:    "ok",
-		StartedAt: startedAt.Time,
-	}
-
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusAccepted)
-	writeJSON(w, dto)
+	_ = json.NewEncoder(w).Encode(scanDTOFromJob(job.State()))
 }
 
-// GetScanStatus godoc
+// ScanStatus godoc
 // @Summary Get scan status
-// @Description Get the most recent scan status for a folder
+// @Description Get the running or most recent scan for a folder, including progress counters and ETA
 // @Tags folders
+// @Produce json
 // @Param id path int true "Folder ID"
 // @Success 200 {object} ScanDTO
 // @Router /folders/{id}/scan [get]
 func (h *Handlers) ScanStatus(w http.ResponseWriter, r *http.Request) {
-	// implementation later
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := parseIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if job, ok := h.Scanner.LatestJob(id); ok {
+		writeJSON(w, scanDTOFromJob(job.State()))
+		return
+	}
+
+	// No job since startup; fall back to the persisted run, then the status cached on the folder row.
+	f, err := h.App.Queries.GetFolderByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "folder not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if f.DeletedAt.Valid {
+		http.Error(w, "folder not found", http.StatusNotFound)
+		return
+	}
+	run, err := h.App.Queries.GetLatestScanRunForFolder(r.Context(), id)
+	if err == nil {
+		writeJSON(w, scanDTOFromRun(run))
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if !f.LastScanAt.Valid || !f.LastScanStatus.Valid {
+		http.Error(w, "no scan recorded", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, scanDTOFromFolder(f))
+}
+
+// ListFolderScans godoc
+// @Summary List scan history
+// @Description List recorded scan runs for a folder, newest first, including trigger, duration and file counts
+// @Tags folders
+// @Produce json
+// @Param id path int true "Folder ID"
+// @Param limit query int false "Max runs to return (default: 50, max: 500)"
+// @Success 200 {array} ScanDTO
+// @Router /folders/{id}/scans [get]
+func (h *Handlers) ListFolderScans(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := parseIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	limit := int64(50)
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, 500)
+	}
+
+	f, err := h.App.Queries.GetFolderByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "folder not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if f.DeletedAt.Valid {
+		http.Error(w, "folder not found", http.StatusNotFound)
+		return
+	}
+
+	runs, err := h.App.Queries.ListScanRunsForFolder(r.Context(), db.ListScanRunsForFolderParams{
+		FolderID: id,
+		Limit:    limit,
+	})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	// The in-memory job has live counters for a run still in progress.
+	dtos := scanDTOsFromRuns(runs)
+	if job, ok := h.Scanner.LatestJob(id); ok {
+		for i := range dtos {
+			if dtos[i].JobID == job.ID && dtos[i].Status == scanner.JobStatusRunning {
+				dtos[i] = scanDTOFromJob(job.State())
+			}
+		}
+	}
+	writeJSON(w, dtos)
 }
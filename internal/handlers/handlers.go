@@ -4,22 +4,35 @@ import (
 	"encoding/json"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"bottomley.ian/musicserver/internal/app"
 	"bottomley.ian/musicserver/internal/services/scanner"
+	"bottomley.ian/musicserver/internal/services/transcode"
 )
 
 type Handlers struct {
-	App           *app.App
-	Scanner       *scanner.Scanner
-	journalSyncMu sync.Mutex
+	App     *app.App
+	Scanner *scanner.Scanner
+	// InferPlays records a play when a track is streamed from the start, for clients
+	// that never call POST /tracks/{id}/played.
+	InferPlays bool
+	// Transcoder runs the ffmpeg transcodes behind GET /tracks/{id}/stream.
+	Transcoder *transcode.Transcoder
+	// HLSCache holds transcoded HLS segments; main opens it in HLS_CACHE_DIR.
+	HLSCache *transcode.Cache
+	// TranscodeCache holds whole-track transcodes; main opens it in TRANSCODE_CACHE_DIR.
+	TranscodeCache *transcode.Cache
+	journalSyncMu  sync.Mutex
+	prewarmPending atomic.Int64
 }
 
 func New(a *app.App, s *scanner.Scanner) *Handlers {
 	return &Handlers{
-		App:     a,
-		Scanner: s,
+		App:        a,
+		Scanner:    s,
+		Transcoder: transcode.New(0),
 	}
 }
 
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"bottomley.ian/musicserver/internal/services/transcode"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// hlsSegmentDuration is the length of every HLS segment but the last.
+const hlsSegmentDuration = 6 * time.Second
+
+// hlsBitrates are the variants in the master playlist, in kbit/s.
+var hlsBitrates = []int{64, 128, 256}
+
+// hlsCodecs is the CODECS attribute for AAC-LC audio.
+const hlsCodecs = "mp4a.40.2"
+
+// hlsSource is a track file as HLS serves it. key names the file's segment directory in
+// the cache and changes whenever the file does, so stale segments are never served.
+type hlsSource struct {
+	id       int64
+	path     string
+	duration time.Duration
+	key      string
+	modTime  time.Time
+}
+
+// segments is the number of segments the source splits into.
+func (src hlsSource) segments() int {
+	return int((src.duration + hlsSegmentDuration - 1) / hlsSegmentDuration)
+}
+
+// segmentLength is the length of segment i; the last one is shorter.
+func (src hlsSource) segmentLength(i int) time.Duration {
+	return min(hlsSegmentDuration, src.duration-time.Duration(i)*hlsSegmentDuration)
+}
+
+// hlsSource resolves the track in the URL, writing an error when it cannot be played.
+// The duration comes from the scan, since playlists must list every segment up front:
+// duration_ms when the file was probed, else the whole seconds of duration_seconds.
+func (h *Handlers) hlsSource(w http.ResponseWriter, r *http.Request) (hlsSource, bool) {
+	id, ok := parseIDParam(w, r, "id")
+	if !ok {
+		return hlsSource{}, false
+	}
+	absPath, ok := h.playableTrackPath(w, r, id)
+	if !ok {
+		return hlsSource{}, false
+	}
+	info, err := os.Stat(absPath)
+	if err != nil || info.IsDir() {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return hlsSource{}, false
+	}
+	track, err := h.App.Queries.GetTrackByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return hlsSource{}, false
+	}
+	var duration time.Duration
+	switch {
+	case track.DurationMs.Valid && track.DurationMs.Int64 > 0:
+		duration = time.Duration(track.DurationMs.Int64) * time.Millisecond
+	case track.DurationSeconds.Valid && track.DurationSeconds.Int64 > 0:
+		duration = time.Duration(track.DurationSeconds.Int64) * time.Second
+	default:
+		http.Error(w, "track duration unknown; rescan the folder", http.StatusConflict)
+		return hlsSource{}, false
+	}
+	return hlsSource{
+		id:       id,
+		path:     absPath,
+		duration: duration,
+		key:      sourceKey(id, info),
+		modTime:  info.ModTime(),
+	}, true
+}
+
+// hlsProfile parses a variant name such as "aac-128" from the URL.
+func hlsProfile(w http.ResponseWriter, r *http.Request) (transcode.Profile, bool) {
+	variant := chi.URLParam(r, "variant")
+	bitrate, err := strconv.Atoi(strings.TrimPrefix(variant, transcode.HLSFormat.Name+"-"))
+	if err != nil || !slices.Contains(hlsBitrates, bitrate) {
+		http.Error(w, "variant not found", http.StatusNotFound)
+		return transcode.Profile{}, false
+	}
+	return transcode.Profile{Format: transcode.HLSFormat, Bitrate: bitrate}, true
+}
+
+func writePlaylist(w http.ResponseWriter, lines []string) {
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write([]byte(strings.Join(lines, "\n") + "\n"))
+}
+
+// HLSMasterPlaylist godoc
+// @Summary HLS master playlist
+// @Description Lists one AAC variant per bitrate (64, 128 and 256 kbit/s) so players can switch with the network. Each variant is transcoded on its first segment request and cached.
+// @Tags tracks
+// @Produce application/vnd.apple.mpegurl
+// @Param id path int true "Track ID"
+// @Success 200 {string} string "M3U8 playlist"
+// @Failure 409 {string} string "track duration unknown"
+// @Router /tracks/{id}/hls/master.m3u8 [get]
+func (h *Handlers) HLSMasterPlaylist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	src, ok := h.hlsSource(w, r)
+	if !ok {
+		return
+	}
+	h.inferPlay(r.Context(), r, src.id)
+
+	lines := []string{"#EXTM3U", "#EXT-X-VERSION:3", "#EXT-X-INDEPENDENT-SEGMENTS"}
+	for _, bitrate := range hlsBitrates {
+		profile := transcode.Profile{Format: transcode.HLSFormat, Bitrate: bitrate}
+		// Allow about 10% for MPEG-TS framing on top of the audio bitrate.
+		lines = append(lines,
+			fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=%q", bitrate*1100, hlsCodecs),
+			profile.String()+"/index.m3u8",
+		)
+	}
+	writePlaylist(w, lines)
+}
+
+// HLSVariantPlaylist godoc
+// @Summary HLS variant playlist
+// @Description VOD media playlist of 6-second segments for one bitrate.
+// @Tags tracks
+// @Produce application/vnd.apple.mpegurl
+// @Param id path int true "Track ID"
+// @Param variant path string true "Variant" Enums(aac-64,aac-128,aac-256)
+// @Success 200 {string} string "M3U8 playlist"
+// @Router /tracks/{id}/hls/{variant}/index.m3u8 [get]
+func (h *Handlers) HLSVariantPlaylist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := hlsProfile(w, r); !ok {
+		return
+	}
+	src, ok := h.hlsSource(w, r)
+	if !ok {
+		return
+	}
+
+	lines := []string{
+		"#EXTM3U",
+		"#EXT-X-VERSION:3",
+		"#EXT-X-PLAYLIST-TYPE:VOD",
+		fmt.Sprintf("#EXT-X-TARGETDURATION:%d", int(hlsSegmentDuration.Seconds())),
+		"#EXT-X-MEDIA-SEQUENCE:0",
+	}
+	for i := range src.segments() {
+		lines = append(lines,
+			fmt.Sprintf("#EXTINF:%.3f,", src.segmentLength(i).Seconds()),
+			fmt.Sprintf("%d.ts", i),
+		)
+	}
+	lines = append(lines, "#EXT-X-ENDLIST")
+	writePlaylist(w, lines)
+}
+
+// HLSSegment godoc
+// @Summary HLS segment
+// @Description One MPEG-TS segment, served from the segment cache. The first request for a variant encodes the whole track once and splits it into segments, so they join without gaps. Each segment is served as soon as the encode finishes it, and the encode runs to the end even if the request is cancelled. Supports range requests.
+// @Tags tracks
+// @Produce video/mp2t
+// @Param id path int true "Track ID"
+// @Param variant path string true "Variant" Enums(aac-64,aac-128,aac-256)
+// @Param segment path string true "Segment file, e.g. 0.ts"
+// @Success 200 {file} file
+// @Failure 503 {string} string "too many transcodes running"
+// @Router /tracks/{id}/hls/{variant}/{segment} [get]
+func (h *Handlers) HLSSegment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	profile, ok := hlsProfile(w, r)
+	if !ok {
+		return
+	}
+	src, ok := h.hlsSource(w, r)
+	if !ok {
+		return
+	}
+	name := chi.URLParam(r, "segment")
+	index, err := strconv.Atoi(strings.TrimSuffix(name, ".ts"))
+	if err != nil || !strings.HasSuffix(name, ".ts") || index < 0 || index >= src.segments() {
+		http.Error(w, "segment not found", http.StatusNotFound)
+		return
+	}
+
+	// Segments are cut from one encode of the whole track, never encoded one by one:
+	// each encode starts with priming samples and ends with padding, which would play as
+	// a gap at every boundary.
+	prefix := fmt.Sprintf("%s/%s", src.key, profile)
+	f, err := h.HLSCache.OpenSet(r.Context(), prefix, fmt.Sprintf("%d.ts", index), func(ctx context.Context, dir string, ready func(name string)) error {
+		return h.Transcoder.TranscodeSegments(ctx, src.path, profile, hlsSegmentDuration, dir, ready)
+	})
+	switch {
+	case err == nil:
+	case errors.Is(err, context.Canceled):
+		return
+	case errors.Is(err, os.ErrNotExist):
+		// The encode came out shorter than the scanned duration.
+		http.Error(w, "segment not found", http.StatusNotFound)
+		return
+	case errors.Is(err, transcode.ErrBusy):
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	default:
+		log.Printf("hls segment track id=%d %s/%s: %v", src.id, prefix, name, err)
+		http.Error(w, "transcode failed", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", profile.Format.ContentType)
+	http.ServeContent(w, r, name, src.modTime, f)
+}
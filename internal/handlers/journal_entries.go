@@ -33,6 +33,8 @@ type createJournalEntryRawRequest struct {
 	Raw string `json:"raw"`
 }
 
+var journalEntrySorts = []string{"date", "title", "status", "scheduled", "deadline"}
+
 type journalEntryListFilters struct {
 	Year     *int64
 	Month    *int64
@@ -54,7 +56,12 @@ type journalEntryListFilters struct {
 // @Param status query []string false "Status filter (comma-separated or repeated)"
 // @Param tags query []string false "Tags filter (comma-separated or repeated)"
 // @Param tag query []string false "Tags filter (comma-separated or repeated)"
+// @Param sort query string false "Sort field (default: date)" Enums(date,title,status,scheduled,deadline)
+// @Param order query string false "Sort order (default: desc); entries on the same day stay in file order" Enums(asc,desc)
+// @Param limit query int false "Maximum number of entries (1-1000; default: all)"
+// @Param offset query int false "Number of entries to skip (default: 0)"
 // @Success 200 {array} JournalEntryDTO
+// @Header 200 {int} X-Total-Count "Number of matching entries before limit/offset"
 // @Router /journals/entries [get]
 func (h *Handlers) ListJournalEntries(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -75,6 +82,11 @@ func (h *Handlers) ListJournalEntries(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
+	page, err := parsePageParams(r, journalEntrySorts, "date", "desc")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	var yearParam interface{}
 	dateFilter := buildDateFilter(filters.Year, filters.Month, filters.Day)
@@ -104,18 +116,32 @@ func (h *Handlers) ListJournalEntries(w http.ResponseWriter, r *http.Request) {
 		tagsParam = string(data)
 	}
 
-	rows, err := h.App.Queries.ListJournalEntries(r.Context(), db.ListJournalEntriesParams{
-		Column1: yearParam,
-		Column2: typeParam,
-		Column3: statusesParam,
-		Column4: tagsParam,
+	params := db.ListJournalEntriesParams{
+		Date:     yearParam,
+		Type:     typeParam,
+		Statuses: statusesParam,
+		Tags:     tagsParam,
+		Sort:     page.sort,
+		Order:    page.order,
+		Limit:    page.limit,
+		Offset:   page.offset,
+	}
+	rows, err := h.App.Queries.ListJournalEntries(r.Context(), params)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	total, err := pageTotal(rows, page, func(row db.ListJournalEntriesRow) int64 { return row.TotalCount }, func() ([]db.ListJournalEntriesRow, error) {
+		params.Limit, params.Offset = 1, 0
+		return h.App.Queries.ListJournalEntries(r.Context(), params)
 	})
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 
-	writeJSON(w, journalEntriesDTOFromDB(rows))
+	writeTotalCount(w, total)
+	writeJSON(w, journalEntriesDTOFromRows(rows))
 }
 
 // CreateJournalEntry godoc
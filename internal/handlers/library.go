@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"bottomley.ian/musicserver/internal/db"
+)
+
+// defaultLowBitrate is the GET /library/formats threshold, in kbit/s, below which lossy
+// tracks are worth replacing.
+const defaultLowBitrate = 192
+
+// defaultLowBitrateAlbums is how many albums GET /library/formats lists without limit.
+const defaultLowBitrateAlbums = 50
+
+// GetLibraryFormats godoc
+// @Summary Library format report
+// @Description Live tracks grouped by codec, with size, duration and bitrate range per codec, plus the albums with the most lossy tracks under low_bitrate. List the tracks themselves with GET /tracks?lossless=false&max_bitrate=191. Codecs come from the scan; tracks scanned before they were recorded count as unprobed until a full rescan.
+// @Tags library
+// @Produce json
+// @Param low_bitrate query int false "Bitrate in kbit/s that lossy tracks count as low below (default: 192)"
+// @Param limit query int false "Maximum number of albums in low_bitrate_albums (1-1000; default: 50)"
+// @Success 200 {object} LibraryFormatsDTO
+// @Router /library/formats [get]
+func (h *Handlers) GetLibraryFormats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	lowBitrate := int64(defaultLowBitrate)
+	if raw := strings.TrimSpace(r.URL.Query().Get("low_bitrate")); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid low_bitrate: must be a positive number of kbit/s", http.StatusBadRequest)
+			return
+		}
+		lowBitrate = n
+	}
+	limit := int64(defaultLowBitrateAlbums)
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || n < 1 || n > maxPageLimit {
+			http.Error(w, fmt.Sprintf("invalid limit: must be between 1 and %d", maxPageLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	rows, err := h.App.Queries.ListTrackFormatStats(r.Context(), lowBitrate)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	albums, err := h.App.Queries.ListLowBitrateAlbums(r.Context(), db.ListLowBitrateAlbumsParams{
+		LowBitrate: lowBitrate,
+		Limit:      limit,
+	})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := LibraryFormatsDTO{
+		LowBitrate:       lowBitrate,
+		Formats:          make([]FormatStatsDTO, 0, len(rows)),
+		LowBitrateAlbums: lowBitrateAlbumsDTOFromRows(albums),
+	}
+	for _, row := range rows {
+		resp.Tracks += row.Tracks
+		resp.HiRes += row.HiRes
+		resp.LowBitrateTracks += row.LowBitrate
+		if !row.Codec.Valid {
+			resp.Unprobed += row.Tracks
+		}
+		if row.Lossless.Valid && row.Lossless.Int64 == 1 {
+			resp.Lossless += row.Tracks
+		}
+		resp.Formats = append(resp.Formats, formatStatsDTOFromRow(row))
+	}
+	writeJSON(w, resp)
+}
@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"math"
 	"strings"
 	"time"
 
 	"bottomley.ian/musicserver/internal/db"
 	"bottomley.ian/musicserver/internal/dbtypes"
+	"bottomley.ian/musicserver/internal/services/scanner"
+	"bottomley.ian/musicserver/internal/services/transcode"
 )
 
 func folderDTOFromDB(f db.Folder) FolderDTO {
@@ -45,6 +49,8 @@ func folderDTOFromDB(f db.Folder) FolderDTO {
 		Path:           f.Path,
 		DeletedAt:      deletedAt,
 		Available:      f.Available == 1,
+		Watch:          f.Watch == 1,
+		ScanSchedule:   stringPtrFromNullString(f.ScanSchedule),
 		LastSeenAt:     lastSeenAt,
 		LastScanAt:     lastScanAt,
 		LastScanStatus: lastScanStatus,
@@ -62,6 +68,100 @@ func foldersDTOFromDB(rows []db.Folder) []FolderDTO {
 	return out
 }
 
+func scanDTOFromJob(st scanner.JobState) ScanDTO {
+	dto := ScanDTO{
+		JobID:        st.ID,
+		FolderID:     st.FolderID,
+		Trigger:      st.Trigger,
+		Full:         st.Full,
+		Status:       st.Status,
+		StartedAt:    st.StartedAt,
+		FinishedAt:   st.FinishedAt,
+		FilesTotal:   st.FilesTotal,
+		FilesSeen:    st.FilesSeen,
+		FilesChanged: st.FilesChanged,
+
+		TracksAdded:    st.TracksAdded,
+		TracksUpdated:  st.TracksUpdated,
+		TracksRemoved:  st.TracksRemoved,
+		FilesFailed:    st.FilesFailed,
+		TracksAnalyzed: st.TracksAnalyzed,
+	}
+	if st.FinishedAt != nil {
+		ms := st.FinishedAt.Sub(st.StartedAt).Milliseconds()
+		dto.DurationMS = &ms
+	}
+	if st.Error != "" {
+		e := st.Error
+		dto.Error = &e
+	}
+	if st.CurrentPath != "" && st.Status == scanner.JobStatusRunning {
+		p := st.CurrentPath
+		dto.CurrentPath = &p
+	}
+	if st.ETA != nil {
+		secs := int64(math.Ceil(st.ETA.Seconds()))
+		dto.ETASeconds = &secs
+	}
+	return dto
+}
+
+func scanDTOFromRun(run db.ScanRun) ScanDTO {
+	dto := ScanDTO{
+		JobID:      run.ID,
+		FolderID:   run.FolderID,
+		Trigger:    run.TriggeredBy,
+		Full:       run.FullRescan == 1,
+		Status:     run.Status,
+		StartedAt:  run.StartedAt,
+		FinishedAt: timePtrFromNullTime(run.FinishedAt),
+		Error:      stringPtrFromNullString(run.Error),
+		FilesSeen:  run.FilesSeen,
+
+		FilesChanged:  run.TracksAdded + run.TracksUpdated,
+		TracksAdded:   run.TracksAdded,
+		TracksUpdated: run.TracksUpdated,
+		TracksRemoved: run.TracksRemoved,
+		FilesFailed:   run.FilesFailed,
+	}
+	if run.FinishedAt.Valid {
+		ms := run.FinishedAt.Time.Sub(run.StartedAt).Milliseconds()
+		dto.DurationMS = &ms
+	}
+	return dto
+}
+
+func scanDTOsFromRuns(runs []db.ScanRun) []ScanDTO {
+	out := make([]ScanDTO, 0, len(runs))
+	for _, run := range runs {
+		out = append(out, scanDTOFromRun(run))
+	}
+	return out
+}
+
+func scanErrorDTOsFromDB(rows []db.ScanRunError) []ScanErrorDTO {
+	out := make([]ScanErrorDTO, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, ScanErrorDTO{
+			ID:        row.ID,
+			ScanRunID: row.ScanRunID,
+			RelPath:   row.RelPath,
+			Error:     row.Error,
+			CreatedAt: row.CreatedAt,
+		})
+	}
+	return out
+}
+
+func scanDTOFromFolder(f db.Folder) ScanDTO {
+	return ScanDTO{
+		FolderID:  f.ID,
+		Status:    f.LastScanStatus.String,
+		StartedAt: f.LastScanAt.Time,
+		Error:     stringPtrFromNullString(f.LastScanError),
+	}
+}
+
 func trackDTOFromDB(tk db.Track) TrackDTO {
 	return trackDTOFromParts(tk, db.Artist{}, db.Album{}, db.Artist{})
 }
@@ -84,8 +184,37 @@ func trackDTOFromParts(tk db.Track, artist db.Artist, album db.Album, albumArtis
 		Ext:          tk.Ext,
 		Genre:        stringPtrFromNullString(tk.Genre),
 		Year:         int64PtrFromNullInt64(tk.Year),
+		ReleaseDate:  stringPtrFromNullString(tk.ReleaseDate),
+		TrackNumber:  int64PtrFromNullInt64(tk.TrackNumber),
+		TrackTotal:   int64PtrFromNullInt64(tk.TrackTotal),
+		DiscNumber:   int64PtrFromNullInt64(tk.DiscNumber),
+		DiscTotal:    int64PtrFromNullInt64(tk.DiscTotal),
+		AlbumArtist:  stringPtrFromNullString(tk.AlbumArtist),
+		Composer:     stringPtrFromNullString(tk.Composer),
+		Comment:      stringPtrFromNullString(tk.Comment),
+		Lyrics:       stringPtrFromNullString(tk.Lyrics),
+		BPM:          int64PtrFromNullInt64(tk.Bpm),
+		Compilation:  tk.Compilation == 1,
+		AlbumPinned:  tk.AlbumPinned == 1,
 		Rating:       int64PtrFromNullInt64(tk.Rating),
+		PlayCount:    tk.PlayCount,
+		LastPlayedAt: timePtrFromNullTime(tk.LastPlayedAt),
 		DurationSec:  int64PtrFromNullInt64(tk.DurationSeconds),
+		DurationMs:   int64PtrFromNullInt64(tk.DurationMs),
+		SampleRate:   int64PtrFromNullInt64(tk.SampleRate),
+		BitDepth:     int64PtrFromNullInt64(tk.BitDepth),
+		Channels:     int64PtrFromNullInt64(tk.Channels),
+		Codec:        stringPtrFromNullString(tk.Codec),
+		Lossless:     boolPtrFromNullInt64(tk.Lossless),
+		Bitrate:      int64PtrFromNullInt64(tk.Bitrate),
+		EncoderDelay: int64PtrFromNullInt64(tk.EncoderDelay),
+		EncoderPad:   int64PtrFromNullInt64(tk.EncoderPadding),
+		SampleCount:  int64PtrFromNullInt64(tk.SampleCount),
+		LoudnessLUFS: float64PtrFromNullFloat64(tk.LoudnessLufs),
+		PeakDBTP:     float64PtrFromNullFloat64(tk.PeakDbtp),
+		TrackGainDB:  float64PtrFromNullFloat64(tk.ReplayGainDb),
+		AlbumGainDB:  float64PtrFromNullFloat64(tk.AlbumGainDb),
+		AlbumPeakDB:  float64PtrFromNullFloat64(tk.AlbumPeakDbtp),
 		ImagePath:    stringPtrFromNullString(tk.ImagePath),
 		SizeBytes:    tk.SizeBytes,
 		LastModified: tk.LastModified,
@@ -104,42 +233,35 @@ func tracksDTOFromDB(rows []db.Track) []TrackDTO {
 	return out
 }
 
-func tracksDTOFromPlayableRows(rows []db.ListPlayableTracksWithJoinsRow) []TrackDTO {
-	out := make([]TrackDTO, 0, len(rows))
-	for _, row := range rows {
-		out = append(out, trackDTOFromParts(row.Track, row.Artist, row.Album, row.Artist_2))
-	}
-	return out
+func trackDTOFromQueryRow(row db.QueryTracksRow) TrackDTO {
+	return trackDTOFromJoinedColumns(row.Track, row.ArtistName, row.AlbumArtistID, row.AlbumTitle, row.AlbumImagePath)
 }
 
-func tracksDTOFromAlbumRows(rows []db.ListPlayableTracksForAlbumRow) []TrackDTO {
-	out := make([]TrackDTO, 0, len(rows))
-	for _, row := range rows {
-		out = append(out, trackDTOFromParts(row.Track, row.Artist, row.Album, row.Artist_2))
+// trackDTOFromJoinedColumns builds the summaries from LEFT JOINed artist and album
+// columns, which stay NULL for tracks without an artist or album.
+func trackDTOFromJoinedColumns(tk db.Track, artistName sql.NullString, albumArtistID sql.NullInt64, albumTitle sql.NullString, albumImagePath dbtypes.NullString) TrackDTO {
+	dto := trackDTOFromDB(tk)
+	if tk.ArtistID.Valid && artistName.Valid {
+		dto.Artist = &ArtistSummaryDTO{
+			ID:   tk.ArtistID.Int64,
+			Name: artistName.String,
+		}
 	}
-	return out
-}
-
-func tracksDTOFromArtistRows(rows []db.ListPlayableTracksForArtistRow) []TrackDTO {
-	out := make([]TrackDTO, 0, len(rows))
-	for _, row := range rows {
-		out = append(out, trackDTOFromParts(row.Track, row.Artist, row.Album, row.Artist_2))
+	if tk.AlbumID.Valid && albumTitle.Valid {
+		dto.Album = &AlbumSummaryDTO{
+			ID:        tk.AlbumID.Int64,
+			ArtistID:  albumArtistID.Int64,
+			Title:     albumTitle.String,
+			ImagePath: stringPtrFromNullString(albumImagePath),
+		}
 	}
-	return out
+	return dto
 }
 
-func tracksDTOFromAlbumArtistRows(rows []db.ListPlayableTracksForAlbumArtistRow) []TrackDTO {
+func tracksDTOFromQueryRows(rows []db.QueryTracksRow) []TrackDTO {
 	out := make([]TrackDTO, 0, len(rows))
 	for _, row := range rows {
-		out = append(out, trackDTOFromParts(row.Track, row.Artist, row.Album, row.Artist_2))
-	}
-	return out
-}
-
-func tracksDTOFromBase(rows []db.Track) []TrackDTO {
-	out := make([]TrackDTO, 0, len(rows))
-	for _, row := range rows {
-		out = append(out, trackDTOFromDB(row))
+		out = append(out, trackDTOFromQueryRow(row))
 	}
 	return out
 }
@@ -154,10 +276,10 @@ func artistDTOFromDB(a db.Artist) ArtistDTO {
 	}
 }
 
-func artistsDTOFromDB(rows []db.Artist) []ArtistDTO {
+func artistsDTOFromRows(rows []db.ListArtistsRow) []ArtistDTO {
 	out := make([]ArtistDTO, 0, len(rows))
-	for _, a := range rows {
-		out = append(out, artistDTOFromDB(a))
+	for _, row := range rows {
+		out = append(out, artistDTOFromDB(row.Artist))
 	}
 	return out
 }
@@ -168,14 +290,24 @@ func albumDTOFromDB(al db.Album) AlbumDTO {
 
 func albumDTOFromParts(al db.Album, artist db.Artist) AlbumDTO {
 	return AlbumDTO{
-		ID:        al.ID,
-		ArtistID:  al.ArtistID,
-		Artist:    artistSummaryFromArtist(artist),
-		Title:     al.Title,
-		ImagePath: stringPtrFromNullString(al.ImagePath),
-		DeletedAt: timePtrFromNullTime(al.DeletedAt),
-		CreatedAt: al.CreatedAt,
-		UpdatedAt: al.UpdatedAt,
+		ID:          al.ID,
+		ArtistID:    al.ArtistID,
+		Artist:      artistSummaryFromArtist(artist),
+		Title:       al.Title,
+		Compilation: al.Compilation == 1,
+		Year:        int64PtrFromNullInt64(al.Year),
+		ReleaseDate: stringPtrFromNullString(al.ReleaseDate),
+		Genre:       stringPtrFromNullString(al.Genre),
+		TrackCount:  al.TrackCount,
+		DiscCount:   al.DiscCount,
+		DurationSec: al.DurationSeconds,
+		Loudness:    float64PtrFromNullFloat64(al.LoudnessLufs),
+		PeakDBTP:    float64PtrFromNullFloat64(al.PeakDbtp),
+		GainDB:      float64PtrFromNullFloat64(al.ReplayGainDb),
+		ImagePath:   stringPtrFromNullString(al.ImagePath),
+		DeletedAt:   timePtrFromNullTime(al.DeletedAt),
+		CreatedAt:   al.CreatedAt,
+		UpdatedAt:   al.UpdatedAt,
 	}
 }
 
@@ -196,9 +328,19 @@ func albumsDTOFromRows(rows []db.ListAlbumsWithArtistRow) []AlbumDTO {
 }
 
 func playlistDTOFromDB(p db.Playlist) PlaylistDTO {
+	var rules *SmartPlaylistRulesDTO
+	if p.Rules.Valid {
+		var decoded SmartPlaylistRulesDTO
+		// Rules are validated before they are stored, so a decode failure leaves them out.
+		if err := json.Unmarshal([]byte(p.Rules.String), &decoded); err == nil {
+			rules = &decoded
+		}
+	}
 	return PlaylistDTO{
 		ID:        p.ID,
 		Name:      p.Name,
+		Type:      p.Type,
+		Rules:     rules,
 		DeletedAt: timePtrFromNullTime(p.DeletedAt),
 		CreatedAt: p.CreatedAt,
 		UpdatedAt: p.UpdatedAt,
@@ -253,6 +395,98 @@ func playlistTrackDTOFromPT(pt db.PlaylistTrack, track *TrackDTO) PlaylistTrackD
 	}
 }
 
+func playDTOFromDB(p db.Play) PlayDTO {
+	return PlayDTO{
+		ID:              p.ID,
+		TrackID:         p.TrackID,
+		PlayedAt:        p.PlayedAt,
+		PositionSeconds: int64PtrFromNullInt64(p.PositionSeconds),
+		Completed:       p.Completed == 1,
+		Counted:         p.Counted == 1,
+		Source:          p.Source,
+		CreatedAt:       p.CreatedAt,
+	}
+}
+
+func playsDTOFromRows(rows []db.ListPlaysRow) []PlayDTO {
+	out := make([]PlayDTO, 0, len(rows))
+	for _, row := range rows {
+		dto := playDTOFromDB(row.Play)
+		track := trackDTOFromJoinedColumns(row.Track, row.ArtistName, row.AlbumArtistID, row.AlbumTitle, row.AlbumImagePath)
+		dto.Track = &track
+		out = append(out, dto)
+	}
+	return out
+}
+
+func mostPlayedTracksDTOFromRows(rows []db.ListMostPlayedTracksRow) []MostPlayedTrackDTO {
+	out := make([]MostPlayedTrackDTO, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, MostPlayedTrackDTO{
+			Plays: row.Plays,
+			Track: trackDTOFromJoinedColumns(row.Track, row.ArtistName, row.AlbumArtistID, row.AlbumTitle, row.AlbumImagePath),
+		})
+	}
+	return out
+}
+
+func dailyPlayStatsDTOFromRows(rows []db.ListDailyPlayStatsRow) []DailyPlayStatsDTO {
+	out := make([]DailyPlayStatsDTO, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, DailyPlayStatsDTO{
+			Date:    row.Day,
+			Plays:   row.Plays,
+			Skipped: row.Skipped,
+			Tracks:  row.Tracks,
+			Seconds: row.Seconds,
+		})
+	}
+	return out
+}
+
+func cacheStatsDTO(s transcode.CacheStats) CacheStatsDTO {
+	return CacheStatsDTO{
+		Dir:       s.Dir,
+		MaxBytes:  s.MaxBytes,
+		Bytes:     s.Bytes,
+		Files:     s.Files,
+		Hits:      s.Hits,
+		Misses:    s.Misses,
+		Evictions: s.Evictions,
+	}
+}
+
+func formatStatsDTOFromRow(row db.ListTrackFormatStatsRow) FormatStatsDTO {
+	return FormatStatsDTO{
+		Codec:            stringPtrFromNullString(row.Codec),
+		Lossless:         boolPtrFromNullInt64(row.Lossless),
+		Tracks:           row.Tracks,
+		SizeBytes:        row.SizeBytes,
+		DurationSeconds:  row.DurationSeconds,
+		MinBitrate:       row.MinBitrate,
+		AvgBitrate:       row.AvgBitrate,
+		MaxBitrate:       row.MaxBitrate,
+		LowBitrateTracks: row.LowBitrate,
+		HiRes:            row.HiRes,
+	}
+}
+
+func lowBitrateAlbumsDTOFromRows(rows []db.ListLowBitrateAlbumsRow) []LowBitrateAlbumDTO {
+	out := make([]LowBitrateAlbumDTO, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, LowBitrateAlbumDTO{
+			ID:               row.ID,
+			ArtistID:         row.ArtistID,
+			Artist:           row.ArtistName,
+			Title:            row.Title,
+			TrackCount:       row.TrackCount,
+			LowBitrateTracks: row.LowBitrateTracks,
+			MinBitrate:       row.MinBitrate,
+		})
+	}
+	return out
+}
+
 func journalEntryDTOFromDB(t db.JournalEntry) JournalEntryDTO {
 	var status *string
 	if t.Status.Valid {
@@ -280,10 +514,10 @@ func journalEntryDTOFromDB(t db.JournalEntry) JournalEntryDTO {
 	}
 }
 
-func journalEntriesDTOFromDB(rows []db.JournalEntry) []JournalEntryDTO {
+func journalEntriesDTOFromRows(rows []db.ListJournalEntriesRow) []JournalEntryDTO {
 	out := make([]JournalEntryDTO, 0, len(rows))
 	for _, row := range rows {
-		out = append(out, journalEntryDTOFromDB(row))
+		out = append(out, journalEntryDTOFromDB(row.JournalEntry))
 	}
 	return out
 }
@@ -425,3 +659,72 @@ func albumSummaryFromAlbum(al db.Album, artist db.Artist) *AlbumSummaryDTO {
 		ImagePath: stringPtrFromNullString(al.ImagePath),
 	}
 }
+
+// Search scores are negated bm25 values, so higher is better.
+
+func searchResultDTOsFromTracks(rows []db.SearchTracksRow) []SearchResultDTO {
+	out := make([]SearchResultDTO, 0, len(rows))
+	for _, row := range rows {
+		snippet := markHighlights(row.Snippet)
+		res := SearchResultDTO{
+			Type:    searchTypeTrack,
+			ID:      row.ID,
+			Title:   markHighlights(row.Title),
+			Snippet: &snippet,
+			Score:   -row.Score,
+		}
+		if row.ArtistName.Valid {
+			res.Artist = &row.ArtistName.String
+		}
+		if row.AlbumTitle.Valid {
+			res.Album = &row.AlbumTitle.String
+		}
+		out = append(out, res)
+	}
+	return out
+}
+
+func searchResultDTOsFromAlbums(rows []db.SearchAlbumsRow) []SearchResultDTO {
+	out := make([]SearchResultDTO, 0, len(rows))
+	for _, row := range rows {
+		artist := row.ArtistName
+		out = append(out, SearchResultDTO{
+			Type:   searchTypeAlbum,
+			ID:     row.ID,
+			Title:  markHighlights(row.Title),
+			Artist: &artist,
+			Score:  -row.Score,
+		})
+	}
+	return out
+}
+
+func searchResultDTOsFromArtists(rows []db.SearchArtistsRow) []SearchResultDTO {
+	out := make([]SearchResultDTO, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, SearchResultDTO{
+			Type:  searchTypeArtist,
+			ID:    row.ID,
+			Title: markHighlights(row.Name),
+			Score: -row.Score,
+		})
+	}
+	return out
+}
+
+func searchResultDTOsFromJournalEntries(rows []db.SearchJournalEntriesRow) []SearchResultDTO {
+	out := make([]SearchResultDTO, 0, len(rows))
+	for _, row := range rows {
+		snippet := markHighlights(row.Snippet)
+		date := row.JournalDate
+		out = append(out, SearchResultDTO{
+			Type:        searchTypeJournalEntry,
+			ID:          row.ID,
+			Title:       markHighlights(row.Title),
+			Snippet:     &snippet,
+			JournalDate: &date,
+			Score:       -row.Score,
+		})
+	}
+	return out
+}
@@ -28,3 +28,27 @@ func int64PtrFromNullInt64(ni sql.NullInt64) *int64 {
 	v := ni.Int64
 	return &v
 }
+
+// boolPtrFromNullInt64 reads a nullable 0/1 flag column.
+func boolPtrFromNullInt64(ni sql.NullInt64) *bool {
+	if !ni.Valid {
+		return nil
+	}
+	v := ni.Int64 == 1
+	return &v
+}
+
+func float64PtrFromNullFloat64(nf sql.NullFloat64) *float64 {
+	if !nf.Valid {
+		return nil
+	}
+	v := nf.Float64
+	return &v
+}
+
+func nullInt64FromPtr(v *int64) sql.NullInt64 {
+	if v == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *v, Valid: true}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxPageLimit caps the limit parameter on list endpoints.
+const maxPageLimit = 1000
+
+// totalCountHeader carries the number of matches before limit/offset on paged lists.
+const totalCountHeader = "X-Total-Count"
+
+// pageParams holds the sort, order, limit and offset parameters shared by list endpoints.
+// limit is -1 when absent, which the queries treat as no limit.
+type pageParams struct {
+	sort   string
+	order  string
+	limit  int64
+	offset int64
+}
+
+// parsePageParams reads sort, order, limit and offset. sort must be one of sorts and
+// defaults to defaultSort; order defaults to defaultOrder.
+func parsePageParams(r *http.Request, sorts []string, defaultSort, defaultOrder string) (pageParams, error) {
+	page := pageParams{
+		sort:  defaultSort,
+		order: defaultOrder,
+		limit: -1,
+	}
+
+	if sort := strings.TrimSpace(r.URL.Query().Get("sort")); sort != "" {
+		if !slices.Contains(sorts, sort) {
+			return pageParams{}, fmt.Errorf("invalid sort: %s; allowed: %s", sort, strings.Join(sorts, ", "))
+		}
+		page.sort = sort
+	}
+	if order := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("order"))); order != "" {
+		if order != "asc" && order != "desc" {
+			return pageParams{}, fmt.Errorf("invalid order: %s; allowed: asc, desc", order)
+		}
+		page.order = order
+	}
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		limit, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return pageParams{}, fmt.Errorf("invalid limit: must be between 1 and %d", maxPageLimit)
+		}
+		page.limit = limit
+	}
+	if raw := strings.TrimSpace(r.URL.Query().Get("offset")); raw != "" {
+		offset, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || offset < 0 {
+			return pageParams{}, fmt.Errorf("invalid offset: must be a non-negative integer")
+		}
+		page.offset = offset
+	}
+	return page, nil
+}
+
+// pageTotal returns the match count that COUNT(*) OVER () puts on every row. A page past
+// the end has no rows to carry it, so firstPage refetches the first row to read it from.
+func pageTotal[T any](rows []T, page pageParams, count func(T) int64, firstPage func() ([]T, error)) (int64, error) {
+	if len(rows) > 0 {
+		return count(rows[0]), nil
+	}
+	if page.offset == 0 {
+		return 0, nil
+	}
+	rows, err := firstPage()
+	if err != nil || len(rows) == 0 {
+		return 0, err
+	}
+	return count(rows[0]), nil
+}
+
+func writeTotalCount(w http.ResponseWriter, total int64) {
+	w.Header().Set(totalCountHeader, strconv.FormatInt(total, 10))
+}
@@ -13,6 +13,8 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
+var playlistTrackSorts = []string{"position", "title", "artist", "album", "duration", "added"}
+
 type addPlaylistTrackRequest struct {
 	TrackID  int64  `json:"track_id"`
 	Position *int64 `json:"position,omitempty"`
@@ -43,8 +45,7 @@ func (h *Handlers) ClearPlaylist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := h.App.Queries.GetPlaylistByID(r.Context(), playlistID); err != nil {
-		http.Error(w, "playlist not found", http.StatusNotFound)
+	if !h.requireStaticPlaylist(w, r, playlistID) {
 		return
 	}
 
@@ -58,10 +59,16 @@ func (h *Handlers) ClearPlaylist(w http.ResponseWriter, r *http.Request) {
 
 // ListPlaylistTracks godoc
 // @Summary List tracks in a playlist
+// @Description Smart playlists are evaluated from their rules on each call. Their entries have id 0, and sort/order cannot be changed.
 // @Tags playlists
 // @Produce json
 // @Param id path int true "Playlist ID"
+// @Param sort query string false "Sort field (default: position)" Enums(position,title,artist,album,duration,added)
+// @Param order query string false "Sort order (default: asc)" Enums(asc,desc)
+// @Param limit query int false "Maximum number of tracks (1-1000; default: all)"
+// @Param offset query int false "Number of tracks to skip (default: 0)"
 // @Success 200 {array} PlaylistTrackDTO
+// @Header 200 {int} X-Total-Count "Number of tracks in the playlist"
 // @Router /playlists/{id}/tracks [get]
 func (h *Handlers) ListPlaylistTracks(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -74,17 +81,54 @@ func (h *Handlers) ListPlaylistTracks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// ensure playlist exists
-	if _, err := h.App.Queries.GetPlaylistByID(r.Context(), playlistID); err != nil {
+	playlist, err := h.App.Queries.GetPlaylistByID(r.Context(), playlistID)
+	if err != nil {
 		http.Error(w, "playlist not found", http.StatusNotFound)
 		return
 	}
 
-	rows, err := h.App.Queries.ListPlaylistTracks(r.Context(), playlistID)
+	page, err := parsePageParams(r, playlistTrackSorts, "position", "asc")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if playlist.Type == playlistTypeSmart {
+		if page.sort != "position" || page.order != "asc" {
+			http.Error(w, "smart playlists are ordered by their rules", http.StatusBadRequest)
+			return
+		}
+		tracks, total, err := h.listSmartPlaylistTracks(r.Context(), playlist, page)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		writeTotalCount(w, total)
+		writeJSON(w, tracks)
+		return
+	}
+
+	params := db.ListPlaylistTracksParams{
+		PlaylistID: playlistID,
+		Sort:       page.sort,
+		Order:      page.order,
+		Limit:      page.limit,
+		Offset:     page.offset,
+	}
+	rows, err := h.App.Queries.ListPlaylistTracks(r.Context(), params)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	total, err := pageTotal(rows, page, func(row db.ListPlaylistTracksRow) int64 { return row.TotalCount }, func() ([]db.ListPlaylistTracksRow, error) {
+		params.Limit, params.Offset = 1, 0
+		return h.App.Queries.ListPlaylistTracks(r.Context(), params)
+	})
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	writeTotalCount(w, total)
 	writeJSON(w, playlistTracksDTOFromRows(rows))
 }
 
@@ -118,8 +162,7 @@ func (h *Handlers) AddPlaylistTrack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := h.App.Queries.GetPlaylistByID(r.Context(), playlistID); err != nil {
-		http.Error(w, "playlist not found", http.StatusNotFound)
+	if !h.requireStaticPlaylist(w, r, playlistID) {
 		return
 	}
 
@@ -294,8 +337,7 @@ func (h *Handlers) EnqueuePlaylistTrack(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if _, err := h.App.Queries.GetPlaylistByID(r.Context(), playlistID); err != nil {
-		http.Error(w, "playlist not found", http.StatusNotFound)
+	if !h.requireStaticPlaylist(w, r, playlistID) {
 		return
 	}
 
@@ -420,8 +462,7 @@ func (h *Handlers) DeletePlaylistTrack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := h.App.Queries.GetPlaylistByID(r.Context(), playlistID); err != nil {
-		http.Error(w, "playlist not found", http.StatusNotFound)
+	if !h.requireStaticPlaylist(w, r, playlistID) {
 		return
 	}
 
@@ -636,3 +677,18 @@ func normalizePlaylistPositions(ctx context.Context, queries *db.Queries, playli
 	}
 	return nil
 }
+
+// requireStaticPlaylist writes 404 for a missing playlist and 409 for a smart one, whose
+// tracks come from its rules and cannot be edited directly.
+func (h *Handlers) requireStaticPlaylist(w http.ResponseWriter, r *http.Request, id int64) bool {
+	playlist, err := h.App.Queries.GetPlaylistByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, "playlist not found", http.StatusNotFound)
+		return false
+	}
+	if playlist.Type == playlistTypeSmart {
+		http.Error(w, "smart playlist tracks come from its rules", http.StatusConflict)
+		return false
+	}
+	return true
+}
@@ -11,11 +11,14 @@ import (
 )
 
 type createPlaylistRequest struct {
-	Name string `json:"name"`
+	Name  string                 `json:"name"`
+	Type  string                 `json:"type,omitempty"`  // "static" (default) or "smart"
+	Rules *SmartPlaylistRulesDTO `json:"rules,omitempty"` // required for smart playlists
 }
 
 // ListPlaylists godoc
 // @Summary List playlists
+// @Description Each playlist has a type: "static", or "smart" with the rules that select its tracks.
 // @Tags playlists
 // @Produce json
 // @Success 200 {array} PlaylistDTO
@@ -36,6 +39,7 @@ func (h *Handlers) ListPlaylists(w http.ResponseWriter, r *http.Request) {
 
 // CreatePlaylist godoc
 // @Summary Create playlist
+// @Description Create a static playlist, or a smart playlist (type "smart") whose tracks are selected by rules each time they are listed.
 // @Tags playlists
 // @Accept json
 // @Produce json
@@ -58,7 +62,34 @@ func (h *Handlers) CreatePlaylist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	row, err := h.App.Queries.CreatePlaylist(r.Context(), body.Name)
+	params := db.CreatePlaylistParams{
+		Name: body.Name,
+		Type: body.Type,
+	}
+	switch body.Type {
+	case "", playlistTypeStatic:
+		if body.Rules != nil {
+			http.Error(w, "rules are only allowed on smart playlists", http.StatusBadRequest)
+			return
+		}
+		params.Type = playlistTypeStatic
+	case playlistTypeSmart:
+		if body.Rules == nil {
+			http.Error(w, "rules required for smart playlists", http.StatusBadRequest)
+			return
+		}
+		rules, err := encodeSmartPlaylistRules(*body.Rules)
+		if err != nil {
+			http.Error(w, "invalid rules: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		params.Rules = rules
+	default:
+		http.Error(w, "invalid type; allowed: static, smart", http.StatusBadRequest)
+		return
+	}
+
+	row, err := h.App.Queries.CreatePlaylist(r.Context(), params)
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
@@ -98,6 +129,7 @@ func (h *Handlers) GetPlaylist(w http.ResponseWriter, r *http.Request) {
 
 // UpdatePlaylist godoc
 // @Summary Update playlist
+// @Description Rename a playlist. Smart playlists also accept new rules; the type cannot be changed.
 // @Tags playlists
 // @Accept json
 // @Produce json
@@ -128,9 +160,32 @@ func (h *Handlers) UpdatePlaylist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	existing, err := h.App.Queries.GetPlaylistByID(r.Context(), id)
+	if err != nil {
+		http.Error(w, "playlist not found", http.StatusNotFound)
+		return
+	}
+	if body.Type != "" && body.Type != existing.Type {
+		http.Error(w, "playlist type cannot be changed", http.StatusBadRequest)
+		return
+	}
+	rules := existing.Rules
+	if body.Rules != nil {
+		if existing.Type != playlistTypeSmart {
+			http.Error(w, "rules are only allowed on smart playlists", http.StatusBadRequest)
+			return
+		}
+		rules, err = encodeSmartPlaylistRules(*body.Rules)
+		if err != nil {
+			http.Error(w, "invalid rules: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	row, err := h.App.Queries.UpdatePlaylist(r.Context(), db.UpdatePlaylistParams{
-		Name: body.Name,
-		ID:   id,
+		Name:  body.Name,
+		Rules: rules,
+		ID:    id,
 	})
 	if err != nil {
 		http.Error(w, "playlist not found", http.StatusNotFound)
@@ -0,0 +1,391 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"bottomley.ian/musicserver/internal/db"
+	"bottomley.ian/musicserver/internal/dbtypes"
+)
+
+const (
+	playSourceClient = "client"
+	playSourceStream = "stream"
+)
+
+// scrobbleMinSeconds is how far a play must get, unless it passes half the track or
+// completes, before it counts towards play_count.
+const scrobbleMinSeconds = 240
+
+// inferredPlayWindow is how long after a play a new stream of the same track is still
+// taken to be that play (reconnects, players re-requesting from the start), and how long
+// after an inferred play ends a client report of the track is taken to be that play.
+const inferredPlayWindow = 10 * time.Minute
+
+// defaultPlayStatsDays is the range GET /plays/stats covers without from/to.
+const defaultPlayStatsDays = 30
+
+type recordPlayRequest struct {
+	PositionSeconds *int64     `json:"position_seconds,omitempty"` // how far playback got
+	Completed       bool       `json:"completed"`
+	PlayedAt        *time.Time `json:"played_at,omitempty"` // for plays reported later; defaults to now
+}
+
+// playCounts applies the usual scrobble rule: a play counts when it completes, or gets
+// past half the track or scrobbleMinSeconds.
+func playCounts(completed bool, position *int64, duration dbtypes.NullInt64) bool {
+	if completed {
+		return true
+	}
+	if position == nil {
+		return false
+	}
+	if *position >= scrobbleMinSeconds {
+		return true
+	}
+	return duration.Valid && duration.Int64 > 0 && *position*2 >= duration.Int64
+}
+
+// RecordTrackPlay godoc
+// @Summary Record a play
+// @Description Log a play of a track. It counts towards play_count when completed, or when position_seconds passes half the track or 4 minutes; shorter plays are kept as skips. last_played_at moves forward either way. A play inferred from a stream of the track shortly before is updated with the report instead of logging a second play.
+// @Tags plays
+// @Accept json
+// @Produce json
+// @Param id path int true "Track ID"
+// @Param request body recordPlayRequest true "Play details"
+// @Success 200 {object} PlayDTO
+// @Router /tracks/{id}/played [post]
+func (h *Handlers) RecordTrackPlay(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := parseIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var body recordPlayRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	if body.PositionSeconds != nil && *body.PositionSeconds < 0 {
+		http.Error(w, "position_seconds must be >= 0", http.StatusBadRequest)
+		return
+	}
+	if body.PlayedAt != nil && body.PlayedAt.After(time.Now().Add(time.Minute)) {
+		http.Error(w, "played_at is in the future", http.StatusBadRequest)
+		return
+	}
+
+	track, err := h.App.Queries.GetTrackByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "track not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	params := db.CreatePlayParams{
+		TrackID:         track.ID,
+		PlayedAt:        queryTimeArg(body.PlayedAt),
+		PositionSeconds: nullInt64FromPtr(body.PositionSeconds),
+		Source:          playSourceClient,
+	}
+	if body.Completed {
+		params.Completed = 1
+	}
+	if playCounts(body.Completed, body.PositionSeconds, track.DurationSeconds) {
+		params.Counted = 1
+	}
+
+	// With INFER_PLAYS the stream behind this play may already be logged. The window
+	// allows for the whole track playing before the client reports it.
+	until := time.Now()
+	if body.PlayedAt != nil {
+		until = *body.PlayedAt
+	}
+	since := until.Add(-inferredPlayWindow)
+	if track.DurationSeconds.Valid {
+		since = since.Add(-time.Duration(track.DurationSeconds.Int64) * time.Second)
+	}
+	play, err := h.App.Queries.UpgradeStreamPlay(r.Context(), db.UpgradeStreamPlayParams{
+		PositionSeconds: params.PositionSeconds,
+		Completed:       params.Completed,
+		Counted:         params.Counted,
+		TrackID:         track.ID,
+		Since:           queryTimeArg(&since),
+		Until:           queryTimeArg(&until),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		play, err = h.App.Queries.CreatePlay(r.Context(), params)
+	}
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, playDTOFromDB(play))
+}
+
+// inferPlay records a stream from the start of a track as a play when InferPlays is set.
+// Range requests further in are seeks, and a stream soon after a play is the same play.
+// The play is stored uncounted: nothing has been heard yet, and a client that reports
+// the play through RecordTrackPlay updates the row instead of adding a second one.
+// Failures are logged and never interrupt the stream.
+func (h *Handlers) inferPlay(ctx context.Context, r *http.Request, trackID int64) {
+	if !h.InferPlays {
+		return
+	}
+	if rng := r.Header.Get("Range"); rng != "" && !strings.HasPrefix(rng, "bytes=0-") {
+		return
+	}
+
+	since := time.Now().Add(-inferredPlayWindow)
+	found, err := h.App.Queries.HasPlaySince(ctx, db.HasPlaySinceParams{
+		TrackID: trackID,
+		Since:   queryTimeArg(&since),
+	})
+	if err != nil {
+		log.Printf("infer play track id=%d: %v", trackID, err)
+		return
+	}
+	if found == 1 {
+		return
+	}
+	if _, err := h.App.Queries.CreatePlay(ctx, db.CreatePlayParams{
+		TrackID: trackID,
+		Source:  playSourceStream,
+	}); err != nil {
+		log.Printf("infer play track id=%d: %v", trackID, err)
+	}
+}
+
+// ListPlays godoc
+// @Summary List the play log
+// @Description Every recorded play with its track, newest first. from/to are UTC dates (inclusive).
+// @Tags plays
+// @Produce json
+// @Param track_id query int false "Only plays of this track"
+// @Param from query string false "First day (YYYY-MM-DD)"
+// @Param to query string false "Last day (YYYY-MM-DD)"
+// @Param order query string false "Sort order (default: desc)" Enums(asc,desc)
+// @Param limit query int false "Maximum number of plays (1-1000; default: all)"
+// @Param offset query int false "Number of plays to skip (default: 0)"
+// @Success 200 {array} PlayDTO
+// @Header 200 {int} X-Total-Count "Number of matching plays"
+// @Router /plays [get]
+func (h *Handlers) ListPlays(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	page, err := parsePageParams(r, []string{"played"}, "played", "desc")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, to, err := parseDayRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	params := db.ListPlaysParams{
+		PlayedFrom: queryTimeArg(from),
+		PlayedTo:   queryTimeArg(to),
+		Order:      page.order,
+		Limit:      page.limit,
+		Offset:     page.offset,
+	}
+	if raw := strings.TrimSpace(r.URL.Query().Get("track_id")); raw != "" {
+		trackID, err := parseQueryInt(raw)
+		if err != nil {
+			http.Error(w, "invalid track_id", http.StatusBadRequest)
+			return
+		}
+		params.TrackID = trackID
+	}
+
+	rows, err := h.App.Queries.ListPlays(r.Context(), params)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	total, err := pageTotal(rows, page, func(row db.ListPlaysRow) int64 { return row.TotalCount }, func() ([]db.ListPlaysRow, error) {
+		params.Limit, params.Offset = 1, 0
+		return h.App.Queries.ListPlays(r.Context(), params)
+	})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeTotalCount(w, total)
+	writeJSON(w, playsDTOFromRows(rows))
+}
+
+// ListRecentlyPlayedTracks godoc
+// @Summary List recently played tracks
+// @Description Tracks that have been played, most recently played first. Each track appears once.
+// @Tags plays
+// @Produce json
+// @Param order query string false "Sort order (default: desc)" Enums(asc,desc)
+// @Param limit query int false "Maximum number of tracks (1-1000; default: all)"
+// @Param offset query int false "Number of tracks to skip (default: 0)"
+// @Success 200 {array} TrackDTO
+// @Header 200 {int} X-Total-Count "Number of played tracks"
+// @Router /plays/recent [get]
+func (h *Handlers) ListRecentlyPlayedTracks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	page, err := parsePageParams(r, []string{"played"}, "played", "desc")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Any last_played_at at all, which is every track with a play.
+	var query trackQuery
+	query.played.notBefore(time.Time{})
+	tracks, total, err := h.queryTracks(r.Context(), nil, nil, trackListOptions{query: query, page: page})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeTotalCount(w, total)
+	writeJSON(w, tracks)
+}
+
+// ListMostPlayedTracks godoc
+// @Summary List most played tracks
+// @Description Tracks by counted plays between from and to (UTC dates, inclusive; open when omitted), most played first.
+// @Tags plays
+// @Produce json
+// @Param from query string false "First day (YYYY-MM-DD)"
+// @Param to query string false "Last day (YYYY-MM-DD)"
+// @Param order query string false "Sort order (default: desc)" Enums(asc,desc)
+// @Param limit query int false "Maximum number of tracks (1-1000; default: all)"
+// @Param offset query int false "Number of tracks to skip (default: 0)"
+// @Success 200 {array} MostPlayedTrackDTO
+// @Header 200 {int} X-Total-Count "Number of tracks played in the range"
+// @Router /plays/top [get]
+func (h *Handlers) ListMostPlayedTracks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	page, err := parsePageParams(r, []string{"plays"}, "plays", "desc")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, to, err := parseDayRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	params := db.ListMostPlayedTracksParams{
+		PlayedFrom: queryTimeArg(from),
+		PlayedTo:   queryTimeArg(to),
+		Order:      page.order,
+		Limit:      page.limit,
+		Offset:     page.offset,
+	}
+	rows, err := h.App.Queries.ListMostPlayedTracks(r.Context(), params)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	total, err := pageTotal(rows, page, func(row db.ListMostPlayedTracksRow) int64 { return row.TotalCount }, func() ([]db.ListMostPlayedTracksRow, error) {
+		params.Limit, params.Offset = 1, 0
+		return h.App.Queries.ListMostPlayedTracks(r.Context(), params)
+	})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeTotalCount(w, total)
+	writeJSON(w, mostPlayedTracksDTOFromRows(rows))
+}
+
+// GetPlayStats godoc
+// @Summary Daily listening stats
+// @Description Plays, skips, distinct tracks and seconds listened per UTC day between from and to (inclusive; default: the last 30 days). Days without plays are omitted.
+// @Tags plays
+// @Produce json
+// @Param from query string false "First day (YYYY-MM-DD)"
+// @Param to query string false "Last day (YYYY-MM-DD; default: today)"
+// @Success 200 {array} DailyPlayStatsDTO
+// @Router /plays/stats [get]
+func (h *Handlers) GetPlayStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	from, to, err := parseDayRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if to == nil {
+		end := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+		to = &end
+	}
+	if from == nil {
+		start := to.AddDate(0, 0, -defaultPlayStatsDays)
+		from = &start
+	}
+
+	rows, err := h.App.Queries.ListDailyPlayStats(r.Context(), db.ListDailyPlayStatsParams{
+		PlayedFrom: queryTimeArg(from),
+		PlayedTo:   queryTimeArg(to),
+	})
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, dailyPlayStatsDTOFromRows(rows))
+}
+
+// parseDayRange reads the from and to query dates (YYYY-MM-DD, UTC) as [from, to+1 day).
+// Either bound is nil when absent.
+func parseDayRange(r *http.Request) (*time.Time, *time.Time, error) {
+	var bounds [2]*time.Time
+	for i, name := range []string{"from", "to"} {
+		raw := strings.TrimSpace(r.URL.Query().Get(name))
+		if raw == "" {
+			continue
+		}
+		day, err := time.Parse(time.DateOnly, raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid %s: use YYYY-MM-DD", name)
+		}
+		if name == "to" {
+			day = day.AddDate(0, 0, 1)
+		}
+		bounds[i] = &day
+	}
+	if bounds[0] != nil && bounds[1] != nil && !bounds[0].Before(*bounds[1]) {
+		return nil, nil, fmt.Errorf("from must not be after to")
+	}
+	return bounds[0], bounds[1], nil
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+)
+
+// ListScanErrors godoc
+// @Summary List scan errors
+// @Description List per-file errors and warnings recorded during a scan run
+// @Tags scans
+// @Produce json
+// @Param runId path int true "Scan run ID (job_id)"
+// @Success 200 {array} ScanErrorDTO
+// @Router /scans/{runId}/errors [get]
+func (h *Handlers) ListScanErrors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	runID, ok := parseIDParam(w, r, "runId")
+	if !ok {
+		return
+	}
+
+	if _, err := h.App.Queries.GetScanRun(r.Context(), runID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "scan run not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := h.App.Queries.ListScanRunErrors(r.Context(), runID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, scanErrorDTOsFromDB(rows))
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"html"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"bottomley.ian/musicserver/internal/db"
+)
+
+const (
+	searchTypeTrack        = "track"
+	searchTypeAlbum        = "album"
+	searchTypeArtist       = "artist"
+	searchTypeJournalEntry = "journal_entry"
+
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+var searchTypes = []string{searchTypeTrack, searchTypeAlbum, searchTypeArtist, searchTypeJournalEntry}
+
+// highlightMarks turns the char(2)/char(3) match markers from the search queries into tags.
+var highlightMarks = strings.NewReplacer("\x02", "<mark>", "\x03", "</mark>")
+
+// Search godoc
+// @Summary Search
+// @Description Full-text search across tracks (title, artist, album, genre), albums, artists and journal entries (title, body). Every term must match: bare words match whole words, word* matches a prefix and "quoted words" match a phrase ("quoted wo"* makes the last word a prefix). Results are ranked best first by score, which is relative to the best hit of the same type (1); title and snippet are HTML-escaped with matches wrapped in <mark>.
+// @Tags search
+// @Produce json
+// @Param q query string true "Search query"
+// @Param type query string false "Comma-separated result types; defaults to all" Enums(track,album,artist,journal_entry)
+// @Param limit query int false "Maximum number of results (default: 20, max: 100)"
+// @Param include_unavailable query bool false "Include tracks from unavailable folders (default: false)"
+// @Success 200 {array} SearchResultDTO
+// @Router /search [get]
+func (h *Handlers) Search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := ftsQuery(r.URL.Query().Get("q"))
+	if query == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+
+	types := searchTypes
+	if raw := strings.TrimSpace(r.URL.Query().Get("type")); raw != "" {
+		types = nil
+		for _, t := range strings.Split(raw, ",") {
+			t = strings.TrimSpace(t)
+			if !slices.Contains(searchTypes, t) {
+				http.Error(w, "invalid type: allowed values are track, album, artist, journal_entry", http.StatusBadRequest)
+				return
+			}
+			types = append(types, t)
+		}
+	}
+
+	limit := int64(defaultSearchLimit)
+	if raw := strings.TrimSpace(r.URL.Query().Get("limit")); raw != "" {
+		parsed, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || parsed < 1 || parsed > maxSearchLimit {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	includeUnavailableRaw := strings.TrimSpace(r.URL.Query().Get("include_unavailable"))
+	includeUnavailable := int64(0)
+	if includeUnavailableRaw != "" {
+		parsed, err := strconv.ParseBool(includeUnavailableRaw)
+		if err != nil {
+			http.Error(w, "invalid include_unavailable", http.StatusBadRequest)
+			return
+		}
+		if parsed {
+			includeUnavailable = 1
+		}
+	}
+
+	// Each type is fetched up to the limit and scored against its own best hit, then the
+	// merged list is ranked and cut.
+	results := []SearchResultDTO{}
+	if slices.Contains(types, searchTypeTrack) {
+		rows, err := h.App.Queries.SearchTracks(r.Context(), db.SearchTracksParams{
+			Query:              query,
+			IncludeUnavailable: includeUnavailable,
+			Limit:              limit,
+		})
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		results = append(results, normalizeScores(searchResultDTOsFromTracks(rows))...)
+	}
+	if slices.Contains(types, searchTypeAlbum) {
+		rows, err := h.App.Queries.SearchAlbums(r.Context(), db.SearchAlbumsParams{Query: query, Limit: limit})
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		results = append(results, normalizeScores(searchResultDTOsFromAlbums(rows))...)
+	}
+	if slices.Contains(types, searchTypeArtist) {
+		rows, err := h.App.Queries.SearchArtists(r.Context(), db.SearchArtistsParams{Query: query, Limit: limit})
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		results = append(results, normalizeScores(searchResultDTOsFromArtists(rows))...)
+	}
+	if slices.Contains(types, searchTypeJournalEntry) {
+		rows, err := h.App.Queries.SearchJournalEntries(r.Context(), db.SearchJournalEntriesParams{Query: query, Limit: limit})
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		results = append(results, normalizeScores(searchResultDTOsFromJournalEntries(rows))...)
+	}
+
+	slices.SortStableFunc(results, func(a, b SearchResultDTO) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+	if int64(len(results)) > limit {
+		results = results[:limit]
+	}
+
+	writeJSON(w, results)
+}
+
+// normalizeScores rescales one type's scores so its best hit scores 1. bm25 depends on
+// each table's columns and document lengths, so raw scores from different tables cannot
+// be compared, but scores relative to each table's best hit can.
+func normalizeScores(results []SearchResultDTO) []SearchResultDTO {
+	best := 0.0
+	for _, r := range results {
+		best = max(best, r.Score)
+	}
+	if best <= 0 {
+		return results
+	}
+	for i := range results {
+		results[i].Score /= best
+	}
+	return results
+}
+
+// ftsQuery turns user input into an FTS5 MATCH expression. Every term is quoted, so
+// FTS5 operators and punctuation in the input are searched as text. Bare words match
+// whole tokens, a trailing * makes a prefix match and double quotes make a phrase.
+// Terms are ANDed; an empty result means there was nothing to search for.
+func ftsQuery(raw string) string {
+	var terms []string
+	add := func(text string, prefix bool) {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return
+		}
+		term := `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+		if prefix {
+			term += "*"
+		}
+		terms = append(terms, term)
+	}
+
+	s := strings.TrimSpace(raw)
+	for s != "" {
+		if s[0] == '"' {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				// An unterminated phrase runs to the end of the input.
+				add(s[1:], false)
+				break
+			}
+			phrase := s[1 : 1+end]
+			s = s[end+2:]
+			prefix := strings.HasPrefix(s, "*")
+			s = strings.TrimLeft(s, "*")
+			add(phrase, prefix)
+		} else {
+			word := s
+			s = ""
+			if end := strings.IndexFunc(word, unicode.IsSpace); end >= 0 {
+				word, s = word[:end], word[end:]
+			}
+			add(strings.TrimRight(word, "*"), strings.HasSuffix(word, "*"))
+		}
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	}
+	return strings.Join(terms, " ")
+}
+
+// markHighlights escapes search output and turns the match markers into <mark> tags.
+func markHighlights(s string) string {
+	return highlightMarks.Replace(html.EscapeString(s))
+}
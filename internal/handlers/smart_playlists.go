@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"bottomley.ian/musicserver/internal/db"
+	"bottomley.ian/musicserver/internal/dbtypes"
+)
+
+const (
+	playlistTypeStatic = "static"
+	playlistTypeSmart  = "smart"
+)
+
+// normalize validates the rules and fills in the default sort and order.
+func (rules *SmartPlaylistRulesDTO) normalize() error {
+	if rules.Sort == "" {
+		rules.Sort = "added"
+	}
+	if !slices.Contains(trackSorts, rules.Sort) {
+		return fmt.Errorf("invalid sort: %s; allowed: %s", rules.Sort, strings.Join(trackSorts, ", "))
+	}
+	if rules.Order == "" {
+		rules.Order = "desc"
+	}
+	if rules.Order != "asc" && rules.Order != "desc" {
+		return fmt.Errorf("invalid order: %s; allowed: asc, desc", rules.Order)
+	}
+	if rules.Limit < 0 {
+		return fmt.Errorf("limit must be >= 0")
+	}
+	if rules.RatingMin != nil && (*rules.RatingMin < 1 || *rules.RatingMin > 5) {
+		return fmt.Errorf("rating_min must be between 1 and 5")
+	}
+	if rules.AddedWithinDays != nil && *rules.AddedWithinDays <= 0 {
+		return fmt.Errorf("added_within_days must be > 0")
+	}
+	if rules.NeverPlayed && rules.PlayCountMin != nil && *rules.PlayCountMin > 0 {
+		return fmt.Errorf("never_played conflicts with play_count_min")
+	}
+	q, err := rules.trackQuery(time.Now())
+	if err != nil {
+		return err
+	}
+	if err := q.checkText(); err != nil {
+		return fmt.Errorf("invalid query: %v", err)
+	}
+	return nil
+}
+
+// trackQuery compiles the rules into the filter GET /tracks uses. now anchors
+// added_within_days, so the playlist moves with time.
+func (rules SmartPlaylistRulesDTO) trackQuery(now time.Time) (trackQuery, error) {
+	q, err := parseTrackQuery(rules.Query, now)
+	if err != nil {
+		return trackQuery{}, fmt.Errorf("invalid query: %v", err)
+	}
+	if rules.Genre != nil {
+		if q.genre != nil {
+			return trackQuery{}, fmt.Errorf("genre given in both genre and query")
+		}
+		genre := strings.TrimSpace(*rules.Genre)
+		q.genre = &genre
+	}
+	if rules.YearMin != nil {
+		q.year.atLeast(*rules.YearMin)
+	}
+	if rules.YearMax != nil {
+		q.year.atMost(*rules.YearMax)
+	}
+	if rules.RatingMin != nil {
+		q.rating.atLeast(*rules.RatingMin)
+	}
+	if rules.AddedWithinDays != nil {
+		q.added.notBefore(now.Add(-time.Duration(*rules.AddedWithinDays) * 24 * time.Hour))
+	}
+	if rules.PlayCountMin != nil {
+		q.plays.atLeast(*rules.PlayCountMin)
+	}
+	if rules.PlayCountMax != nil {
+		q.plays.atMost(*rules.PlayCountMax)
+	}
+	if rules.NeverPlayed {
+		q.plays.atMost(0)
+	}
+	return q, nil
+}
+
+// encodeSmartPlaylistRules normalizes rules into the JSON stored in playlists.rules.
+func encodeSmartPlaylistRules(rules SmartPlaylistRulesDTO) (dbtypes.NullString, error) {
+	if err := rules.normalize(); err != nil {
+		return dbtypes.NullString{}, err
+	}
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return dbtypes.NullString{}, err
+	}
+	return dbtypes.NullString{String: string(data), Valid: true}, nil
+}
+
+// listSmartPlaylistTracks evaluates a smart playlist's rules against playable tracks. The
+// rule limit caps the playlist and page slices it; positions are ranks in rule order.
+// Entries have no playlist_tracks row, so their id is 0.
+func (h *Handlers) listSmartPlaylistTracks(ctx context.Context, p db.Playlist, page pageParams) ([]PlaylistTrackDTO, int64, error) {
+	var rules SmartPlaylistRulesDTO
+	if err := json.Unmarshal([]byte(p.Rules.String), &rules); err != nil {
+		return nil, 0, fmt.Errorf("playlist %d: invalid rules: %w", p.ID, err)
+	}
+	query, err := rules.trackQuery(time.Now())
+	if err != nil {
+		return nil, 0, fmt.Errorf("playlist %d: %w", p.ID, err)
+	}
+
+	opts := trackListOptions{
+		query: query,
+		page: pageParams{
+			sort:   rules.Sort,
+			order:  rules.Order,
+			limit:  page.limit,
+			offset: page.offset,
+		},
+	}
+	if rules.Limit > 0 {
+		left := max(rules.Limit-page.offset, 0)
+		if opts.page.limit < 0 || opts.page.limit > left {
+			opts.page.limit = left
+		}
+	}
+
+	tracks, total, err := h.queryTracks(ctx, nil, nil, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	if rules.Limit > 0 {
+		total = min(total, rules.Limit)
+	}
+
+	out := make([]PlaylistTrackDTO, 0, len(tracks))
+	for i := range tracks {
+		out = append(out, PlaylistTrackDTO{
+			PlaylistID: p.ID,
+			TrackID:    tracks[i].ID,
+			Position:   page.offset + int64(i),
+			CreatedAt:  p.UpdatedAt,
+			UpdatedAt:  p.UpdatedAt,
+			Track:      &tracks[i],
+		})
+	}
+	return out, total, nil
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"bottomley.ian/musicserver/internal/db"
+	"bottomley.ian/musicserver/internal/services/transcode"
+)
+
+// streamWriter passes transcoded bytes to the client, flushing each chunk so playback
+// can start straight away. It remembers whether anything was sent, since an error can
+// only be reported before the first byte.
+type streamWriter struct {
+	w       http.ResponseWriter
+	written bool
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	sw.written = true
+	n, err := sw.w.Write(p)
+	if f, ok := sw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
+
+// parseTranscodeProfile reads the format and bitrate query parameters.
+func parseTranscodeProfile(r *http.Request) (transcode.Profile, error) {
+	var bitrate int
+	if raw := strings.TrimSpace(r.URL.Query().Get("bitrate")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return transcode.Profile{}, errors.New("invalid bitrate")
+		}
+		bitrate = n
+	}
+	return transcode.ParseProfile(strings.TrimSpace(r.URL.Query().Get("format")), bitrate)
+}
+
+// ReplayGain modes for transcodes.
+const (
+	replayGainTrack = "track"
+	replayGainAlbum = "album"
+)
+
+// replayGainHeadroomDB is how far below 0 dBTP a boosted stream's true peak is kept.
+const replayGainHeadroomDB = 1
+
+// parseReplayGainMode reads the replaygain query parameter; "" and "off" mean none.
+func parseReplayGainMode(r *http.Request) (string, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("replaygain"))); mode {
+	case "", "off":
+		return "", nil
+	case replayGainTrack, replayGainAlbum:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid replaygain: %s; allowed: off, track, album", mode)
+	}
+}
+
+// replayGainDB picks the gain for mode from the track's loudness analysis. Album mode
+// falls back to the track gain until the whole album is analyzed. Boosts are capped so
+// the true peak stays replayGainHeadroomDB below full scale. An unanalyzed track gets 0.
+func replayGainDB(tk db.Track, mode string) float64 {
+	gain, peak := tk.ReplayGainDb, tk.PeakDbtp
+	if mode == replayGainAlbum && tk.AlbumGainDb.Valid {
+		gain, peak = tk.AlbumGainDb, tk.AlbumPeakDbtp
+	}
+	if mode == "" || !gain.Valid {
+		return 0
+	}
+	g := gain.Float64
+	if peak.Valid {
+		g = min(g, -replayGainHeadroomDB-peak.Float64)
+	}
+	return g
+}
+
+// applyReplayGain sets the profile's gain for track id when a mode is requested.
+func (h *Handlers) applyReplayGain(ctx context.Context, id int64, mode string, profile *transcode.Profile) error {
+	if mode == "" {
+		return nil
+	}
+	tk, err := h.App.Queries.GetTrackByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	profile.GainDB = replayGainDB(tk, mode)
+	return nil
+}
+
+// TranscodeTrack godoc
+// @Summary Stream a track transcoded
+// @Description Transcode a track with ffmpeg while streaming it, saving the result in the transcode cache. Once cached, the same format and bitrate are served from disk with Range support; until then responses are not seekable. At most TRANSCODE_MAX transcodes run at once; when all are busy for 5 seconds the request gets 503.
+// @Tags tracks
+// @Produce audio/mpeg
+// @Produce audio/ogg
+// @Produce audio/aac
+// @Param id path int true "Track ID"
+// @Param format query string false "Output format (default: mp3)" Enums(mp3,opus,aac)
+// @Param bitrate query int false "Bitrate in kbit/s, 32-320 (default: 192 for mp3/aac, 128 for opus)"
+// @Param replaygain query string false "Apply the analyzed ReplayGain as a volume change (default: off)" Enums(off,track,album)
+// @Success 200 {file} file
+// @Failure 503 {string} string "too many transcodes running"
+// @Router /tracks/{id}/stream [get]
+func (h *Handlers) TranscodeTrack(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := parseIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+
+	profile, err := parseTranscodeProfile(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	mode, err := parseReplayGainMode(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	absPath, ok := h.playableTrackPath(w, r, id)
+	if !ok {
+		return
+	}
+	if err := h.applyReplayGain(r.Context(), id, mode, &profile); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	info, err := os.Stat(absPath)
+	if err != nil || info.IsDir() {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+
+	h.inferPlay(r.Context(), r, id)
+
+	key := transcodeCacheKey(id, info, profile)
+	if f, ok := h.TranscodeCache.Lookup(key); ok {
+		defer f.Close()
+		w.Header().Set("Content-Type", profile.Format.ContentType)
+		w.Header().Set("X-Transcode-Cache", "hit")
+		http.ServeContent(w, r, path.Base(key), info.ModTime(), f)
+		return
+	}
+	log.Printf("transcode track id=%d profile=%s path=%s", id, profile, absPath)
+
+	w.Header().Set("Content-Type", profile.Format.ContentType)
+	w.Header().Set("Accept-Ranges", "none")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Transcode-Cache", "miss")
+	sw := &streamWriter{w: w}
+	var out io.Writer = sw
+	// Only one request fills a key; others for the same profile stream uncached meanwhile.
+	cw, caching := h.TranscodeCache.Create(key)
+	if caching {
+		defer cw.Abort()
+		out = io.MultiWriter(sw, cw)
+	}
+	err = h.Transcoder.Transcode(r.Context(), absPath, profile, out)
+	switch {
+	case err == nil:
+		if caching {
+			if err := cw.Commit(); err != nil {
+				log.Printf("transcode cache %s: %v", key, err)
+			}
+		}
+	case errors.Is(err, context.Canceled):
+		log.Printf("transcode track id=%d: client disconnected", id)
+	case errors.Is(err, transcode.ErrBusy):
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case sw.written:
+		// Too late for a status code; the client sees a truncated stream.
+		log.Printf("transcode track id=%d: %v", id, err)
+	default:
+		log.Printf("transcode track id=%d: %v", id, err)
+		http.Error(w, "transcode failed", http.StatusInternalServerError)
+	}
+}
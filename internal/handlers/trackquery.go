@@ -0,0 +1,435 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// trackQuery is a parsed `q` filter for GET /tracks, for example
+// `genre:jazz year:1960..1969 rating>=4 ext:flac duration>600 added:<30d`.
+// Terms are ANDed. Words without a field are matched against the search index.
+type trackQuery struct {
+	text       []string
+	genre      *string
+	artist     *string
+	album      *string
+	ext        *string
+	codec      *string
+	lossless   *bool
+	year       intRange
+	rating     intRange
+	duration   intRange // seconds
+	bpm        intRange
+	added      timeRange
+	plays      intRange
+	played     timeRange // last_played_at
+	bitrate    intRange  // kbit/s
+	sampleRate intRange  // Hz
+	bitDepth   intRange
+	channels   intRange
+}
+
+// intRange is an inclusive bound; nil ends are open.
+type intRange struct {
+	min *int64
+	max *int64
+}
+
+// timeRange is an inclusive bound on a track timestamp; nil ends are open.
+type timeRange struct {
+	after  *time.Time
+	before *time.Time
+}
+
+var (
+	// queryTerm splits `field<op>value`. The operator may also follow a colon (`added:<30d`).
+	queryTerm = regexp.MustCompile(`^([a-z]+)(:|>=|<=|>|<|=)(.*)$`)
+	// queryAge is a relative age for added: such as 12h, 30d, 2w or 1y.
+	queryAge = regexp.MustCompile(`^(\d+)([hdwy])$`)
+
+	queryOps      = []string{">=", "<=", ">", "<", "="}
+	queryAgeUnits = map[string]time.Duration{
+		"h": time.Hour,
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+		"y": 365 * 24 * time.Hour,
+	}
+)
+
+// parseTrackQuery parses the `q` grammar. now anchors relative ages in added:.
+func parseTrackQuery(raw string, now time.Time) (trackQuery, error) {
+	var q trackQuery
+	for _, term := range splitQueryTerms(raw) {
+		m := queryTerm.FindStringSubmatch(term)
+		if m == nil {
+			q.text = append(q.text, term)
+			continue
+		}
+		field, op, value := m[1], m[2], m[3]
+		if op == ":" {
+			// field:value is field=value unless an operator follows the colon.
+			op = "="
+			for _, o := range queryOps {
+				if strings.HasPrefix(value, o) {
+					op, value = o, value[len(o):]
+					break
+				}
+			}
+		}
+		value = strings.Trim(value, `"`)
+		if value == "" {
+			return trackQuery{}, fmt.Errorf("%s: missing value", field)
+		}
+
+		var err error
+		switch field {
+		case "genre":
+			err = setQueryString(&q.genre, field, op, value)
+		case "artist":
+			err = setQueryString(&q.artist, field, op, value)
+		case "album":
+			err = setQueryString(&q.album, field, op, value)
+		case "ext":
+			err = setQueryString(&q.ext, field, op, strings.TrimPrefix(value, "."))
+		case "codec":
+			err = setQueryString(&q.codec, field, op, value)
+		case "lossless":
+			err = setQueryBool(&q.lossless, op, value)
+		case "year":
+			err = q.year.parse(op, value, parseQueryInt)
+		case "rating":
+			err = q.rating.parse(op, value, parseQueryInt)
+		case "duration":
+			err = q.duration.parse(op, value, parseQuerySeconds)
+		case "bpm":
+			err = q.bpm.parse(op, value, parseQueryInt)
+		case "added":
+			err = q.added.parse(op, value, now)
+		case "plays":
+			err = q.plays.parse(op, value, parseQueryInt)
+		case "played":
+			err = q.played.parse(op, value, now)
+		case "bitrate":
+			err = q.bitrate.parse(op, value, parseQueryInt)
+		case "samplerate":
+			err = q.sampleRate.parse(op, value, parseQueryHz)
+		case "bitdepth":
+			err = q.bitDepth.parse(op, value, parseQueryInt)
+		case "channels":
+			err = q.channels.parse(op, value, parseQueryInt)
+		default:
+			return trackQuery{}, fmt.Errorf("unknown field %q", field)
+		}
+		if err != nil {
+			return trackQuery{}, fmt.Errorf("%s: %w", field, err)
+		}
+	}
+	return q, nil
+}
+
+// splitQueryTerms splits on whitespace outside double quotes; quotes are kept.
+func splitQueryTerms(raw string) []string {
+	var terms []string
+	var cur strings.Builder
+	quoted := false
+	for _, r := range raw {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if cur.Len() > 0 {
+				terms = append(terms, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		terms = append(terms, cur.String())
+	}
+	return terms
+}
+
+func setQueryString(dst **string, field, op, value string) error {
+	if op != "=" {
+		return fmt.Errorf("only field:value is supported")
+	}
+	if *dst != nil {
+		return fmt.Errorf("given more than once")
+	}
+	*dst = &value
+	return nil
+}
+
+// setQueryBool sets a true/false field. A second, conflicting value is an error.
+func setQueryBool(dst **bool, op, value string) error {
+	if op != "=" {
+		return fmt.Errorf("only field:value is supported")
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid value %q: want true or false", value)
+	}
+	if *dst != nil && **dst != b {
+		return fmt.Errorf("given both true and false")
+	}
+	*dst = &b
+	return nil
+}
+
+func parseQueryInt(s string) (int64, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+	return n, nil
+}
+
+// parseQuerySeconds accepts plain seconds or a Go duration such as 10m or 1h30m.
+func parseQuerySeconds(s string) (int64, error) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return int64(d.Round(time.Second) / time.Second), nil
+}
+
+// parseQueryHz accepts a sample rate in Hz, or in kHz with a k suffix (44.1k, 96k).
+func parseQueryHz(s string) (int64, error) {
+	if khz, ok := strings.CutSuffix(strings.ToLower(s), "k"); ok {
+		f, err := strconv.ParseFloat(khz, 64)
+		if err != nil || f < 0 {
+			return 0, fmt.Errorf("invalid sample rate %q", s)
+		}
+		return int64(math.Round(f * 1000)), nil
+	}
+	return parseQueryInt(s)
+}
+
+// applyAudioParams adds the GET /tracks audio property parameters to q. Each is ANDed
+// with the q terms, so `min_sample_rate=96000` is the same as `samplerate>=96000`.
+func (q *trackQuery) applyAudioParams(values url.Values) error {
+	if raw := strings.TrimSpace(values.Get("codec")); raw != "" {
+		if err := setQueryString(&q.codec, "codec", "=", raw); err != nil {
+			return fmt.Errorf("invalid codec: %v", err)
+		}
+	}
+	if raw := strings.TrimSpace(values.Get("lossless")); raw != "" {
+		if err := setQueryBool(&q.lossless, "=", raw); err != nil {
+			return fmt.Errorf("invalid lossless: %v", err)
+		}
+	}
+	bounds := []struct {
+		name  string
+		op    string
+		field *intRange
+		conv  func(string) (int64, error)
+	}{
+		{"min_bitrate", ">=", &q.bitrate, parseQueryInt},
+		{"max_bitrate", "<=", &q.bitrate, parseQueryInt},
+		{"min_sample_rate", ">=", &q.sampleRate, parseQueryHz},
+		{"max_sample_rate", "<=", &q.sampleRate, parseQueryHz},
+		{"min_bit_depth", ">=", &q.bitDepth, parseQueryInt},
+		{"max_bit_depth", "<=", &q.bitDepth, parseQueryInt},
+		{"channels", "=", &q.channels, parseQueryInt},
+	}
+	for _, b := range bounds {
+		raw := strings.TrimSpace(values.Get(b.name))
+		if raw == "" {
+			continue
+		}
+		if err := b.field.parse(b.op, raw, b.conv); err != nil {
+			return fmt.Errorf("invalid %s: %v", b.name, err)
+		}
+	}
+	return nil
+}
+
+// matchText is the search index MATCH expression for the free-text words, or "" when
+// there are none left to search for.
+func (q trackQuery) matchText() string {
+	return ftsQuery(strings.Join(q.text, " "))
+}
+
+// checkText rejects free text that reduces to nothing, such as `*` or `""`. FTS5 fails
+// on an empty MATCH, so such a query could never run.
+func (q trackQuery) checkText() error {
+	if len(q.text) > 0 && q.matchText() == "" {
+		return fmt.Errorf("no searchable words in %s", strings.Join(q.text, " "))
+	}
+	return nil
+}
+
+// parse applies `op value` where value is a number or an `a..b` range (either end optional).
+func (r *intRange) parse(op, value string, conv func(string) (int64, error)) error {
+	if lo, hi, ok := strings.Cut(value, ".."); ok {
+		if op != "=" {
+			return fmt.Errorf("ranges only support field:a..b")
+		}
+		if lo == "" && hi == "" {
+			return fmt.Errorf("empty range")
+		}
+		if lo != "" {
+			n, err := conv(lo)
+			if err != nil {
+				return err
+			}
+			r.atLeast(n)
+		}
+		if hi != "" {
+			n, err := conv(hi)
+			if err != nil {
+				return err
+			}
+			r.atMost(n)
+		}
+		return nil
+	}
+
+	n, err := conv(value)
+	if err != nil {
+		return err
+	}
+	switch op {
+	case ">":
+		r.atLeast(n + 1)
+	case ">=":
+		r.atLeast(n)
+	case "<":
+		r.atMost(n - 1)
+	case "<=":
+		r.atMost(n)
+	default:
+		r.atLeast(n)
+		r.atMost(n)
+	}
+	return nil
+}
+
+func (r *intRange) atLeast(n int64) {
+	if r.min == nil || n > *r.min {
+		r.min = &n
+	}
+}
+
+func (r *intRange) atMost(n int64) {
+	if r.max == nil || n < *r.max {
+		r.max = &n
+	}
+}
+
+// parse applies `op value` to added: or played:. A value is either an age (30d: `<` means newer
+// than that) or a YYYY-MM-DD date in UTC (`<` means before that day).
+func (r *timeRange) parse(op, value string, now time.Time) error {
+	if lo, hi, ok := strings.Cut(value, ".."); ok {
+		if op != "=" {
+			return fmt.Errorf("ranges only support field:a..b")
+		}
+		if lo == "" && hi == "" {
+			return fmt.Errorf("empty range")
+		}
+		if lo != "" {
+			if err := r.apply(">=", lo, now); err != nil {
+				return err
+			}
+		}
+		if hi != "" {
+			return r.apply("<=", hi, now)
+		}
+		return nil
+	}
+	return r.apply(op, value, now)
+}
+
+func (r *timeRange) apply(op, value string, now time.Time) error {
+	if m := queryAge.FindStringSubmatch(value); m != nil {
+		n, _ := strconv.ParseInt(m[1], 10, 64)
+		cutoff := now.Add(-time.Duration(n) * queryAgeUnits[m[2]])
+		// Ages run backwards: a smaller age is a later time.
+		switch op {
+		case ">", ">=":
+			r.notAfter(cutoff)
+		default:
+			r.notBefore(cutoff)
+		}
+		return nil
+	}
+
+	day, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return fmt.Errorf("invalid value %q: want an age like 30d or a date like 2024-01-31", value)
+	}
+	end := day.Add(24*time.Hour - time.Second)
+	switch op {
+	case ">":
+		r.notBefore(end.Add(time.Second))
+	case ">=":
+		r.notBefore(day)
+	case "<":
+		r.notAfter(day.Add(-time.Second))
+	case "<=":
+		r.notAfter(end)
+	default:
+		r.notBefore(day)
+		r.notAfter(end)
+	}
+	return nil
+}
+
+func (r *timeRange) notBefore(t time.Time) {
+	if r.after == nil || t.After(*r.after) {
+		r.after = &t
+	}
+}
+
+func (r *timeRange) notAfter(t time.Time) {
+	if r.before == nil || t.Before(*r.before) {
+		r.before = &t
+	}
+}
+
+// Helpers turning parsed bounds into QueryTracks arguments (nil means no filter).
+
+func queryStringArg(s *string) interface{} {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
+
+func queryBoolArg(b *bool) interface{} {
+	if b == nil {
+		return nil
+	}
+	if *b {
+		return int64(1)
+	}
+	return int64(0)
+}
+
+func queryIntArg(n *int64) interface{} {
+	if n == nil {
+		return nil
+	}
+	return *n
+}
+
+// queryTimeArg formats a bound like SQLite's CURRENT_TIMESTAMP, which created_at and
+// last_played_at hold.
+func queryTimeArg(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(time.DateTime)
+}
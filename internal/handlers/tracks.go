@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"mime"
 	"net/http"
@@ -13,16 +14,38 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"bottomley.ian/musicserver/internal/db"
 	dbtypes "bottomley.ian/musicserver/internal/dbtypes"
 	myfs "bottomley.ian/musicserver/internal/services/fs"
+	"bottomley.ian/musicserver/internal/services/scanner"
 
 	"github.com/go-chi/chi/v5"
 )
 
 type updateTrackRequest struct {
-	Rating *int64 `json:"rating,omitempty"`
+	Rating      *int64  `json:"rating,omitempty"`
+	Title       *string `json:"title,omitempty"`
+	Artist      *string `json:"artist,omitempty"`
+	Album       *string `json:"album,omitempty"`
+	Genre       *string `json:"genre,omitempty"`
+	Year        *int    `json:"year,omitempty"`
+	TrackNumber *int    `json:"track_number,omitempty"`
+}
+
+func (b updateTrackRequest) tagEdit() (scanner.TagEdit, bool) {
+	edit := scanner.TagEdit{
+		Title:  b.Title,
+		Artist: b.Artist,
+		Album:  b.Album,
+		Genre:  b.Genre,
+		Year:   b.Year,
+		Track:  b.TrackNumber,
+		// A new album tag moves the track, even if it was merged or split by hand.
+		Unpin: b.Album != nil,
+	}
+	return edit, edit != scanner.TagEdit{}
 }
 
 type updateTrackRatingRequest struct {
@@ -34,6 +57,8 @@ type trackListOptions struct {
 	includeArtist      bool
 	startsWith         *string
 	includeUnavailable bool
+	query              trackQuery
+	page               pageParams // an empty sort picks the default for the listing
 }
 
 var (
@@ -42,19 +67,44 @@ var (
 		"artist": {},
 	}
 	allowedExpandList = []string{"album", "artist"}
+
+	trackSorts = []string{"path", "title", "artist", "album", "track", "year", "rating", "duration", "added", "plays", "played"}
 )
 
 // ListTracks godoc
 // @Summary List tracks
-// @Description List all non-deleted tracks
+// @Description List non-deleted tracks. q takes space-separated terms that are ANDed: field:value, field>n,
+// @Description field>=n, field<n, field<=n and field:a..b (either end optional). Fields are genre, artist,
+// @Description album and ext (exact, case-insensitive), year, rating, bpm, duration (seconds or 10m),
+// @Description added (an age such as 30d, where added:<30d means the last 30 days, or a YYYY-MM-DD date),
+// @Description plays (play count; plays:0 is never played) and played (last played, like added).
+// @Description Audio fields from the scan: codec (exact), lossless (true/false), bitrate (kbit/s),
+// @Description samplerate (Hz, or 96k), bitdepth and channels.
+// @Description Other words are full-text matched like GET /search.
+// @Description The codec, lossless and min_/max_ parameters are shorthands ANDed with q.
 // @Tags tracks
 // @Produce json
 // @Param albumId query int false "Filter by album ID"
 // @Param artistId query int false "Filter by artist ID"
+// @Param q query string false "Filter expression" example(genre:jazz year:1960..1969 rating>=4)
+// @Param sort query string false "Sort field (default: path, or track with albumId)" Enums(path,title,artist,album,track,year,rating,duration,added,plays,played)
+// @Param order query string false "Sort order (default: asc)" Enums(asc,desc)
+// @Param limit query int false "Maximum number of tracks (1-1000; default: all)"
+// @Param offset query int false "Number of tracks to skip (default: 0)"
 // @Param expand query string false "Comma-separated expansions (album,artist); defaults to none" Enums(album,artist) example(album,artist)
 // @Param startswith query string false "Prefix filter on filename"
 // @Param include_unavailable query bool false "Include tracks from unavailable folders (default: false)"
+// @Param codec query string false "ffprobe codec name, e.g. flac or mp3"
+// @Param lossless query bool false "Only lossless (true) or lossy (false) tracks"
+// @Param min_bitrate query int false "Minimum bitrate in kbit/s"
+// @Param max_bitrate query int false "Maximum bitrate in kbit/s"
+// @Param min_sample_rate query int false "Minimum sample rate in Hz" example(96000)
+// @Param max_sample_rate query int false "Maximum sample rate in Hz"
+// @Param min_bit_depth query int false "Minimum bit depth (lossless tracks only have one)"
+// @Param max_bit_depth query int false "Maximum bit depth"
+// @Param channels query int false "Channel count"
 // @Success 200 {array} TrackDTO
+// @Header 200 {int} X-Total-Count "Number of matching tracks before limit/offset"
 // @Router /tracks [get]
 func (h *Handlers) ListTracks(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -90,12 +140,13 @@ func (h *Handlers) ListTracks(w http.ResponseWriter, r *http.Request) {
 		artistID = &id
 	}
 
-	tracks, err := h.listTracksShared(r.Context(), albumID, artistID, opts)
+	tracks, total, err := h.queryTracks(r.Context(), albumID, artistID, opts)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	writeTotalCount(w, total)
 	writeJSON(w, filterTracks(tracks, opts))
 }
 
@@ -134,7 +185,7 @@ func (h *Handlers) GetTrack(w http.ResponseWriter, r *http.Request) {
 
 // UpdateTrack godoc
 // @Summary Update track
-// @Description Update track metadata (rating 1-5 or clear)
+// @Description Update track rating (1-5 or clear) and/or tags. Tag edits (title, artist, album, genre, year, track_number) are written into the audio file and the track is re-read from it; an empty string or 0 removes the tag. An album edit releases a track pinned by merge or split, so it moves to the album named by its tags. Ratings are written as POPM (MP3) or FMPS_RATING (FLAC/OGG). When tags are sent, rating only changes if the field is present.
 // @Tags tracks
 // @Accept json
 // @Produce json
@@ -155,11 +206,27 @@ func (h *Handlers) UpdateTrack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
 	var body updateTrackRequest
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &body); err != nil {
 		http.Error(w, "invalid json", http.StatusBadRequest)
 		return
 	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		http.Error(w, "invalid json", http.StatusBadRequest)
+		return
+	}
+	_, hasRating := fields["rating"]
+	edit, hasTags := body.tagEdit()
+	// A rating-only body keeps the old meaning: omitting rating clears it.
+	if !hasTags {
+		hasRating = true
+	}
 
 	var rating dbtypes.NullInt64
 	if body.Rating != nil {
@@ -170,11 +237,7 @@ func (h *Handlers) UpdateTrack(w http.ResponseWriter, r *http.Request) {
 		rating = dbtypes.NullInt64{Int64: *body.Rating, Valid: true}
 	}
 
-	_, err = h.App.Queries.UpdateTrackRating(r.Context(), db.UpdateTrackRatingParams{
-		Rating: rating,
-		ID:     id,
-	})
-	if err != nil {
+	if _, err := h.App.Queries.GetTrackByID(r.Context(), id); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			http.Error(w, "track not found", http.StatusNotFound)
 			return
@@ -183,6 +246,29 @@ func (h *Handlers) UpdateTrack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if hasRating {
+		edit.Rating = starsFromRating(rating)
+	}
+	if err := h.Scanner.WriteTrackTags(r.Context(), map[int64]scanner.TagEdit{id: edit}); err != nil {
+		writeTagError(w, err)
+		return
+	}
+
+	if hasRating {
+		_, err = h.App.Queries.UpdateTrackRating(r.Context(), db.UpdateTrackRatingParams{
+			Rating: rating,
+			ID:     id,
+		})
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "track not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	updated, err := h.App.Queries.GetTrackWithJoins(r.Context(), id)
 	if err != nil {
 		http.Error(w, "internal error", http.StatusInternalServerError)
@@ -192,9 +278,28 @@ func (h *Handlers) UpdateTrack(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, trackDTOFromJoinedRow(updated))
 }
 
+// starsFromRating turns a stored rating into a tag edit value, where 0 clears the tag.
+func starsFromRating(rating dbtypes.NullInt64) *int {
+	stars := int(rating.Int64)
+	return &stars
+}
+
+// writeTagError reports a failed tag write-back.
+func writeTagError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "track not found", http.StatusNotFound)
+	case errors.Is(err, scanner.ErrTrackUnavailable):
+		http.Error(w, "track file unavailable", http.StatusConflict)
+	default:
+		log.Printf("tag write failed: %v", err)
+		http.Error(w, "failed to write tags", http.StatusInternalServerError)
+	}
+}
+
 // UpdateTrackRating godoc
 // @Summary Update track rating
-// @Description Update track rating (1-5) or clear it
+// @Description Update track rating (1-5) or clear it. The rating is also written to the file (POPM for MP3, FMPS_RATING for FLAC/OGG). Other formats, and files that cannot be reached, keep it in the database only.
 // @Tags tracks
 // @Accept json
 // @Produce json
@@ -230,6 +335,31 @@ func (h *Handlers) UpdateTrackRating(w http.ResponseWriter, r *http.Request) {
 		rating = dbtypes.NullInt64{Int64: *body.Rating, Valid: true}
 	}
 
+	track, err := h.App.Queries.GetTrackByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "track not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	// Formats without a rating tag keep the rating in the database only, and so does a
+	// file that cannot be reached right now.
+	if scanner.HasRatingTag(track.Ext) {
+		edit := scanner.TagEdit{Rating: starsFromRating(rating)}
+		err := h.Scanner.WriteTrackTags(r.Context(), map[int64]scanner.TagEdit{id: edit})
+		switch {
+		case err == nil:
+		case errors.Is(err, scanner.ErrTrackUnavailable), errors.Is(err, os.ErrNotExist):
+			log.Printf("track id=%d: file unavailable, rating stored in the database only", id)
+		default:
+			writeTagError(w, err)
+			return
+		}
+	}
+
 	_, err = h.App.Queries.UpdateTrackRating(r.Context(), db.UpdateTrackRatingParams{
 		Rating: rating,
 		ID:     id,
@@ -252,106 +382,78 @@ func (h *Handlers) UpdateTrackRating(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, trackDTOFromJoinedRow(updated))
 }
 
-func (h *Handlers) listTracksShared(ctx context.Context, albumID *int64, artistID *int64, opts trackListOptions) ([]TrackDTO, error) {
-	prefix := nullStringFromPrefix(opts.startsWith)
+// queryTracks runs QueryTracks for a listing and returns one page with the total match
+// count. albumID and artistID come from the route or the albumId/artistId parameters
+// and are ANDed with the q terms.
+func (h *Handlers) queryTracks(ctx context.Context, albumID *int64, artistID *int64, opts trackListOptions) ([]TrackDTO, int64, error) {
 	includeUnavailable := int64(0)
 	if opts.includeUnavailable {
 		includeUnavailable = 1
 	}
-
-	if albumID != nil && artistID != nil {
-		if opts.includeAlbum || opts.includeArtist {
-			rows, err := h.App.Queries.ListPlayableTracksForAlbumArtist(ctx, db.ListPlayableTracksForAlbumArtistParams{
-				Column1:  includeUnavailable,
-				AlbumID:  dbtypes.NullInt64{Int64: *albumID, Valid: true},
-				ArtistID: dbtypes.NullInt64{Int64: *artistID, Valid: true},
-				Column4:  prefix,
-			})
-			if err != nil {
-				return nil, err
-			}
-			return tracksDTOFromAlbumArtistRows(rows), nil
-		}
-
-		rows, err := h.App.Queries.ListPlayableTracksForAlbumArtistBase(ctx, db.ListPlayableTracksForAlbumArtistBaseParams{
-			Column1:  includeUnavailable,
-			AlbumID:  dbtypes.NullInt64{Int64: *albumID, Valid: true},
-			ArtistID: dbtypes.NullInt64{Int64: *artistID, Valid: true},
-			Column4:  prefix,
-		})
-		if err != nil {
-			return nil, err
-		}
-		return tracksDTOFromBase(rows), nil
-	}
-
-	if albumID != nil {
-		if opts.includeAlbum || opts.includeArtist {
-			rows, err := h.App.Queries.ListPlayableTracksForAlbum(ctx, db.ListPlayableTracksForAlbumParams{
-				Column1: includeUnavailable,
-				AlbumID: dbtypes.NullInt64{Int64: *albumID, Valid: true},
-				Column3: prefix,
-			})
-			if err != nil {
-				return nil, err
-			}
-			return tracksDTOFromAlbumRows(rows), nil
+	sort := opts.page.sort
+	if sort == "" {
+		sort = "path"
+		if albumID != nil {
+			sort = "track"
 		}
-
-		rows, err := h.App.Queries.ListPlayableTracksForAlbumBase(ctx, db.ListPlayableTracksForAlbumBaseParams{
-			Column1: includeUnavailable,
-			AlbumID: dbtypes.NullInt64{Int64: *albumID, Valid: true},
-			Column3: prefix,
-		})
-		if err != nil {
-			return nil, err
-		}
-		return tracksDTOFromBase(rows), nil
-	}
-
-	if artistID != nil {
-		if opts.includeAlbum || opts.includeArtist {
-			rows, err := h.App.Queries.ListPlayableTracksForArtist(ctx, db.ListPlayableTracksForArtistParams{
-				Column1:  includeUnavailable,
-				ArtistID: dbtypes.NullInt64{Int64: *artistID, Valid: true},
-				Column3:  prefix,
-			})
-			if err != nil {
-				return nil, err
-			}
-			return tracksDTOFromArtistRows(rows), nil
-		}
-
-		rows, err := h.App.Queries.ListPlayableTracksForArtistBase(ctx, db.ListPlayableTracksForArtistBaseParams{
-			Column1:  includeUnavailable,
-			ArtistID: dbtypes.NullInt64{Int64: *artistID, Valid: true},
-			Column3:  prefix,
-		})
-		if err != nil {
-			return nil, err
-		}
-		return tracksDTOFromBase(rows), nil
 	}
-
-	if opts.includeAlbum || opts.includeArtist {
-		rows, err := h.App.Queries.ListPlayableTracksWithJoins(ctx, db.ListPlayableTracksWithJoinsParams{
-			Prefix:             prefix,
-			IncludeUnavailable: includeUnavailable,
-		})
-		if err != nil {
-			return nil, err
-		}
-		return tracksDTOFromPlayableRows(rows), nil
+	var text interface{}
+	if match := opts.query.matchText(); match != "" {
+		text = match
 	}
 
-	rows, err := h.App.Queries.ListPlayableTracks(ctx, db.ListPlayableTracksParams{
-		Prefix:             prefix,
+	q := opts.query
+	params := db.QueryTracksParams{
 		IncludeUnavailable: includeUnavailable,
+		Prefix:             queryStringArg(opts.startsWith),
+		AlbumID:            queryIntArg(albumID),
+		ArtistID:           queryIntArg(artistID),
+		Text:               text,
+		Genre:              queryStringArg(q.genre),
+		Artist:             queryStringArg(q.artist),
+		Album:              queryStringArg(q.album),
+		Ext:                queryStringArg(q.ext),
+		YearMin:            queryIntArg(q.year.min),
+		YearMax:            queryIntArg(q.year.max),
+		RatingMin:          queryIntArg(q.rating.min),
+		RatingMax:          queryIntArg(q.rating.max),
+		DurationMin:        queryIntArg(q.duration.min),
+		DurationMax:        queryIntArg(q.duration.max),
+		BpmMin:             queryIntArg(q.bpm.min),
+		BpmMax:             queryIntArg(q.bpm.max),
+		AddedAfter:         queryTimeArg(q.added.after),
+		AddedBefore:        queryTimeArg(q.added.before),
+		PlaysMin:           queryIntArg(q.plays.min),
+		PlaysMax:           queryIntArg(q.plays.max),
+		PlayedAfter:        queryTimeArg(q.played.after),
+		PlayedBefore:       queryTimeArg(q.played.before),
+		Codec:              queryStringArg(q.codec),
+		Lossless:           queryBoolArg(q.lossless),
+		BitrateMin:         queryIntArg(q.bitrate.min),
+		BitrateMax:         queryIntArg(q.bitrate.max),
+		SampleRateMin:      queryIntArg(q.sampleRate.min),
+		SampleRateMax:      queryIntArg(q.sampleRate.max),
+		BitDepthMin:        queryIntArg(q.bitDepth.min),
+		BitDepthMax:        queryIntArg(q.bitDepth.max),
+		ChannelsMin:        queryIntArg(q.channels.min),
+		ChannelsMax:        queryIntArg(q.channels.max),
+		Sort:               sort,
+		Order:              opts.page.order,
+		Limit:              opts.page.limit,
+		Offset:             opts.page.offset,
+	}
+	rows, err := h.App.Queries.QueryTracks(ctx, params)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := pageTotal(rows, opts.page, func(row db.QueryTracksRow) int64 { return row.TotalCount }, func() ([]db.QueryTracksRow, error) {
+		params.Limit, params.Offset = 1, 0
+		return h.App.Queries.QueryTracks(ctx, params)
 	})
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	return tracksDTOFromBase(rows), nil
+	return tracksDTOFromQueryRows(rows), total, nil
 }
 
 func parseTrackListOptions(r *http.Request) (trackListOptions, error) {
@@ -389,6 +491,24 @@ func parseTrackListOptions(r *http.Request) (trackListOptions, error) {
 		opts.includeUnavailable = parsed
 	}
 
+	query, err := parseTrackQuery(r.URL.Query().Get("q"), time.Now())
+	if err == nil {
+		err = query.checkText()
+	}
+	if err != nil {
+		return trackListOptions{}, fmt.Errorf("invalid q: %v", err)
+	}
+	if err := query.applyAudioParams(r.URL.Query()); err != nil {
+		return trackListOptions{}, err
+	}
+	opts.query = query
+
+	page, err := parsePageParams(r, trackSorts, "", "asc")
+	if err != nil {
+		return trackListOptions{}, err
+	}
+	opts.page = page
+
 	return opts, nil
 }
 
@@ -404,13 +524,6 @@ func filterTracks(tracks []TrackDTO, opts trackListOptions) []TrackDTO {
 	return tracks
 }
 
-func nullStringFromPrefix(prefix *string) sql.NullString {
-	if prefix == nil {
-		return sql.NullString{}
-	}
-	return sql.NullString{String: *prefix, Valid: true}
-}
-
 func parseCSVSet(input string) map[string]struct{} {
 	out := make(map[string]struct{})
 	if input == "" {
@@ -475,23 +588,10 @@ func (h *Handlers) serveTrackFile(w http.ResponseWriter, r *http.Request, dispos
 		return
 	}
 
-	pathParts, err := h.App.Queries.GetPlayableTrackPathPartsByID(r.Context(), id)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			http.Error(w, "track not found or unavailable", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "internal error", http.StatusInternalServerError)
-		return
-	}
-
-	basePath, err := myfs.ExpandPath(pathParts.FolderPath)
-	if err != nil {
-		http.Error(w, "invalid folder path", http.StatusInternalServerError)
+	absPath, ok := h.playableTrackPath(w, r, id)
+	if !ok {
 		return
 	}
-
-	absPath := filepath.Clean(filepath.Join(basePath, pathParts.RelPath))
 	log.Printf("serve track id=%d path=%s", id, absPath)
 	f, err := os.Open(absPath)
 	if err != nil {
@@ -510,6 +610,10 @@ func (h *Handlers) serveTrackFile(w http.ResponseWriter, r *http.Request, dispos
 		return
 	}
 
+	if disposition == "inline" {
+		h.inferPlay(r.Context(), r, id)
+	}
+
 	ctype := mime.TypeByExtension(filepath.Ext(info.Name()))
 	if ctype == "" {
 		ctype = "application/octet-stream"
@@ -520,3 +624,37 @@ func (h *Handlers) serveTrackFile(w http.ResponseWriter, r *http.Request, dispos
 	}
 	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
 }
+
+// playableTrackPath resolves a track's absolute file path, writing 404 when the track is
+// missing or its folder is unavailable.
+func (h *Handlers) playableTrackPath(w http.ResponseWriter, r *http.Request, id int64) (string, bool) {
+	path, err := h.trackFilePath(r.Context(), id)
+	switch {
+	case err == nil:
+		return path, true
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "track not found or unavailable", http.StatusNotFound)
+	case errors.Is(err, errInvalidFolderPath):
+		http.Error(w, "invalid folder path", http.StatusInternalServerError)
+	default:
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+	return "", false
+}
+
+// errInvalidFolderPath is returned by trackFilePath when the folder path cannot be expanded.
+var errInvalidFolderPath = errors.New("invalid folder path")
+
+// trackFilePath resolves a track's absolute file path. It returns sql.ErrNoRows when the
+// track is missing or its folder is unavailable.
+func (h *Handlers) trackFilePath(ctx context.Context, id int64) (string, error) {
+	pathParts, err := h.App.Queries.GetPlayableTrackPathPartsByID(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	basePath, err := myfs.ExpandPath(pathParts.FolderPath)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errInvalidFolderPath, err)
+	}
+	return filepath.Clean(filepath.Join(basePath, pathParts.RelPath)), nil
+}
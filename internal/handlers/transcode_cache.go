@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"bottomley.ian/musicserver/internal/services/transcode"
+)
+
+// sourceKey names one version of a track file in the transcode caches. It changes
+// whenever the file does, so stale output is never served; old versions age out.
+func sourceKey(id int64, info os.FileInfo) string {
+	return fmt.Sprintf("%d-%d-%d", id, info.ModTime().Unix(), info.Size())
+}
+
+// transcodeCacheKey is where a whole-track transcode lives in the transcode cache,
+// e.g. "12-1760000000-61234567/mp3-192.mp3".
+func transcodeCacheKey(id int64, info os.FileInfo, p transcode.Profile) string {
+	return fmt.Sprintf("%s/%s.%s", sourceKey(id, info), p, p.Format.Ext)
+}
+
+// prewarmJob is one track transcode queued by PrewarmPlaylistTranscodes.
+type prewarmJob struct {
+	id      int64
+	path    string
+	key     string
+	profile transcode.Profile
+}
+
+// PrewarmPlaylistTranscodes godoc
+// @Summary Pre-warm the transcode cache for a playlist
+// @Description Queues background transcodes of every playlist track that is not cached yet in the given format and bitrate, so later GET /tracks/{id}/stream requests are served from disk. Jobs run one track at a time and share the TRANSCODE_MAX cap. Smart playlists use their current tracks.
+// @Tags playlists
+// @Produce json
+// @Param id path int true "Playlist ID"
+// @Param format query string false "Output format (default: mp3)" Enums(mp3,opus,aac)
+// @Param bitrate query int false "Bitrate in kbit/s, 32-320 (default: 192 for mp3/aac, 128 for opus)"
+// @Param replaygain query string false "Volume change, as for GET /tracks/{id}/stream (default: off)" Enums(off,track,album)
+// @Success 202 {object} TranscodePrewarmDTO
+// @Router /playlists/{id}/prewarm [post]
+func (h *Handlers) PrewarmPlaylistTranscodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	playlistID, ok := parseIDParam(w, r, "id")
+	if !ok {
+		return
+	}
+	profile, err := parseTranscodeProfile(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	mode, err := parseReplayGainMode(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	playlist, err := h.App.Queries.GetPlaylistByID(r.Context(), playlistID)
+	if err != nil {
+		http.Error(w, "playlist not found", http.StatusNotFound)
+		return
+	}
+
+	var ids []int64
+	if playlist.Type == playlistTypeSmart {
+		tracks, _, err := h.listSmartPlaylistTracks(r.Context(), playlist, pageParams{limit: -1})
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		for _, t := range tracks {
+			ids = append(ids, t.TrackID)
+		}
+	} else {
+		ids, err = h.App.Queries.ListPlaylistTrackIDs(r.Context(), playlistID)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	resp := TranscodePrewarmDTO{Profile: profile.String(), ReplayGain: mode}
+	seen := make(map[int64]bool)
+	var jobs []prewarmJob
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		resp.Tracks++
+
+		absPath, err := h.trackFilePath(r.Context(), id)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		var info os.FileInfo
+		if err == nil {
+			info, err = os.Stat(absPath)
+		}
+		if err != nil || info.IsDir() {
+			resp.Unavailable++
+			continue
+		}
+		trackProfile := profile
+		if err := h.applyReplayGain(r.Context(), id, mode, &trackProfile); err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		key := transcodeCacheKey(id, info, trackProfile)
+		if h.TranscodeCache.Contains(key) {
+			resp.Cached++
+			continue
+		}
+		jobs = append(jobs, prewarmJob{id: id, path: absPath, key: key, profile: trackProfile})
+	}
+	resp.Queued = len(jobs)
+
+	if len(jobs) > 0 {
+		h.prewarmPending.Add(int64(len(jobs)))
+		go h.prewarmTranscodes(jobs)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// prewarmTranscodes fills the transcode cache for jobs in order. A job waiting on a busy
+// transcoder retries rather than giving up, since nobody is waiting on the result.
+func (h *Handlers) prewarmTranscodes(jobs []prewarmJob) {
+	ctx := context.Background()
+	for _, job := range jobs {
+		for {
+			f, err := h.TranscodeCache.Open(ctx, job.key, func(ctx context.Context, path string) error {
+				return h.Transcoder.TranscodeFile(ctx, job.path, job.profile, path)
+			})
+			if errors.Is(err, transcode.ErrBusy) {
+				continue
+			}
+			if err != nil {
+				log.Printf("prewarm track id=%d %s: %v", job.id, job.key, err)
+			} else {
+				_ = f.Close()
+			}
+			break
+		}
+		h.prewarmPending.Add(-1)
+	}
+}
+
+// GetTranscodeCacheStats godoc
+// @Summary Transcode cache stats
+// @Description Size, budget and hit/miss/eviction counters (since startup) for the whole-track transcode cache and the HLS segment cache, plus the number of queued pre-warm transcodes.
+// @Tags cache
+// @Produce json
+// @Success 200 {object} TranscodeCacheStatsDTO
+// @Router /cache/transcodes [get]
+func (h *Handlers) GetTranscodeCacheStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, TranscodeCacheStatsDTO{
+		Streams:        cacheStatsDTO(h.TranscodeCache.Stats()),
+		HLS:            cacheStatsDTO(h.HLSCache.Stats()),
+		PrewarmPending: h.prewarmPending.Load(),
+	})
+}
@@ -17,6 +17,10 @@ type FS interface {
 	Create(name string) (io.WriteCloser, error)
 	WriteFile(name string, data []byte, perm fs.FileMode) error
 	ReadFile(name string) ([]byte, error)
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	Chmod(name string, mode fs.FileMode) error
+	Watch(root string) (Watcher, error)
 }
 
 type OSFS struct{}
@@ -53,6 +57,18 @@ func (OSFS) ReadFile(name string) ([]byte, error) {
 	return os.ReadFile(name)
 }
 
+func (OSFS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (OSFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (OSFS) Chmod(name string, mode fs.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
 func ExpandUserPath(path string) (string, error) {
 	if path == "" {
 		return path, nil
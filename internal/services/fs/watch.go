@@ -0,0 +1,144 @@
+package fs
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+type WatchOp uint32
+
+const (
+	WatchCreate WatchOp = 1 << iota
+	WatchWrite
+	WatchRemove
+	WatchRename
+)
+
+type WatchEvent struct {
+	Path string
+	Op   WatchOp
+}
+
+// Watcher delivers change events for every file and directory under a root.
+type Watcher interface {
+	Events() <-chan WatchEvent
+	Errors() <-chan error
+	Close() error
+}
+
+// Watch watches root recursively. New subdirectories are picked up automatically and
+// the files already inside them are reported as creates, so a copied-in album is not missed.
+func (OSFS) Watch(root string) (Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &osWatcher{
+		fw:     fw,
+		events: make(chan WatchEvent, 256),
+		errors: make(chan error, 16),
+		done:   make(chan struct{}),
+	}
+	if err := w.addTree(root, false); err != nil {
+		_ = fw.Close()
+		return nil, err
+	}
+	go w.loop()
+	return w, nil
+}
+
+type osWatcher struct {
+	fw     *fsnotify.Watcher
+	events chan WatchEvent
+	errors chan error
+	done   chan struct{}
+}
+
+func (w *osWatcher) Events() <-chan WatchEvent { return w.events }
+
+func (w *osWatcher) Errors() <-chan error { return w.errors }
+
+func (w *osWatcher) Close() error {
+	close(w.done)
+	return w.fw.Close()
+}
+
+func (w *osWatcher) loop() {
+	defer close(w.events)
+	defer close(w.errors)
+	for {
+		select {
+		case <-w.done:
+			return
+		case ev, ok := <-w.fw.Events:
+			if !ok {
+				return
+			}
+			w.handle(ev)
+		case err, ok := <-w.fw.Errors:
+			if !ok {
+				return
+			}
+			select {
+			case w.errors <- err:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
+
+func (w *osWatcher) handle(ev fsnotify.Event) {
+	var op WatchOp
+	switch {
+	case ev.Has(fsnotify.Create):
+		op = WatchCreate
+	case ev.Has(fsnotify.Write):
+		op = WatchWrite
+	case ev.Has(fsnotify.Remove):
+		op = WatchRemove
+	case ev.Has(fsnotify.Rename):
+		op = WatchRename
+	default:
+		return
+	}
+	w.emit(WatchEvent{Path: ev.Name, Op: op})
+
+	if op == WatchCreate {
+		if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+			if err := w.addTree(ev.Name, true); err != nil {
+				log.Printf("warn: failed to watch %s: %v", ev.Name, err)
+			}
+		}
+	}
+}
+
+// addTree registers root and its subdirectories. When report is set, entries found
+// below root are emitted as creates because they may predate the watch being added.
+func (w *osWatcher) addTree(root string, report bool) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() {
+			if err := w.fw.Add(path); err != nil {
+				return err
+			}
+		}
+		if report && path != root {
+			w.emit(WatchEvent{Path: path, Op: WatchCreate})
+		}
+		return nil
+	})
+}
+
+func (w *osWatcher) emit(ev WatchEvent) {
+	select {
+	case w.events <- ev:
+	case <-w.done:
+	}
+}
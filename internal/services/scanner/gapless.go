@@ -0,0 +1,151 @@
+package scanner
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Gapless is the encoder delay and padding a decoder trims from the start and end of a
+// lossy stream, in samples per channel.
+type Gapless struct {
+	Delay   int64
+	Padding int64
+	Samples int64 // playable samples once delay and padding are trimmed; 0 when unknown
+}
+
+// mp3HeaderScan is how far past the ID3v2 tag readLAMEGapless looks for the first frame.
+const mp3HeaderScan = 16 << 10
+
+// readLAMEGapless reads the encoder delay and padding from the LAME (or ffmpeg "Lavf"/
+// "Lavc") extension of the Xing/Info header in the first MPEG audio frame. ok is false
+// when the file has no such header, which is normal for CBR files from older encoders.
+func readLAMEGapless(r io.ReadSeeker) (g Gapless, ok bool, err error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return Gapless{}, false, err
+	}
+	var id3 [10]byte
+	if _, err := io.ReadFull(r, id3[:]); err != nil {
+		return Gapless{}, false, nil
+	}
+	offset := int64(0)
+	if string(id3[:3]) == "ID3" {
+		offset = 10 + int64(synchsafe(id3[6:10]))
+		if id3[5]&0x10 != 0 {
+			offset += 10 // footer
+		}
+	}
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return Gapless{}, false, err
+	}
+	buf := make([]byte, mp3HeaderScan)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		if errors.Is(err, io.EOF) {
+			return Gapless{}, false, nil
+		}
+		return Gapless{}, false, err
+	}
+	buf = buf[:n]
+
+	for i := 0; i+4 <= len(buf); i++ {
+		if buf[i] != 0xff || buf[i+1]&0xe0 != 0xe0 {
+			continue
+		}
+		g, ok := parseLAMEFrame(buf[i:])
+		return g, ok, nil
+	}
+	return Gapless{}, false, nil
+}
+
+// parseLAMEFrame reads the Xing/Info and LAME headers from frame, which starts at an
+// MPEG frame sync.
+func parseLAMEFrame(frame []byte) (Gapless, bool) {
+	version := frame[1] >> 3 & 3 // 3 = MPEG-1, 2 = MPEG-2, 0 = MPEG-2.5
+	layer := frame[1] >> 1 & 3   // 1 = Layer III
+	if version == 1 || layer != 1 || frame[2]>>2&3 == 3 {
+		return Gapless{}, false
+	}
+	mono := frame[3]>>6 == 3
+
+	// The Xing header follows the side information, whose size depends on the version
+	// and channel count.
+	samplesPerFrame := int64(576)
+	sideInfo := 17
+	if mono {
+		sideInfo = 9
+	}
+	if version == 3 {
+		samplesPerFrame = 1152
+		sideInfo = 32
+		if mono {
+			sideInfo = 17
+		}
+	}
+	x := 4 + sideInfo
+	if len(frame) < x+8 {
+		return Gapless{}, false
+	}
+	if id := string(frame[x : x+4]); id != "Xing" && id != "Info" {
+		return Gapless{}, false
+	}
+	flags := binary.BigEndian.Uint32(frame[x+4 : x+8])
+	off := x + 8
+	var frames int64
+	if flags&1 != 0 {
+		if len(frame) < off+4 {
+			return Gapless{}, false
+		}
+		frames = int64(binary.BigEndian.Uint32(frame[off : off+4]))
+		off += 4
+	}
+	if flags&2 != 0 {
+		off += 4 // byte count
+	}
+	if flags&4 != 0 {
+		off += 100 // seek table
+	}
+	if flags&8 != 0 {
+		off += 4 // quality
+	}
+
+	// Encoder version (9 bytes), then fixed fields up to the 12-bit delay and padding.
+	if len(frame) < off+24 {
+		return Gapless{}, false
+	}
+	switch string(frame[off : off+4]) {
+	case "LAME", "Lavf", "Lavc":
+	default:
+		return Gapless{}, false
+	}
+	b := frame[off+21 : off+24]
+	g := Gapless{
+		Delay:   int64(b[0])<<4 | int64(b[1]>>4),
+		Padding: int64(b[1]&0x0f)<<8 | int64(b[2]),
+	}
+	if samples := frames*samplesPerFrame - g.Delay - g.Padding; frames > 0 && samples > 0 {
+		g.Samples = samples
+	}
+	return g, true
+}
+
+// parseITunSMPB reads iTunes gapless info, which AAC files from iTunes (and some MP3s)
+// carry as a tag of hex fields: a reserved word, the delay, the padding and the original
+// sample count.
+func parseITunSMPB(v string) (Gapless, bool) {
+	fields := strings.Fields(v)
+	if len(fields) < 4 {
+		return Gapless{}, false
+	}
+	var nums [3]int64
+	for i := range nums {
+		n, err := strconv.ParseInt(fields[i+1], 16, 64)
+		if err != nil || n < 0 {
+			return Gapless{}, false
+		}
+		nums[i] = n
+	}
+	return Gapless{Delay: nums[0], Padding: nums[1], Samples: nums[2]}, true
+}
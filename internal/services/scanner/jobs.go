@@ -0,0 +1,377 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"sync"
+	"time"
+
+	"bottomley.ian/musicserver/internal/db"
+	dbtypes "bottomley.ian/musicserver/internal/dbtypes"
+)
+
+const (
+	JobStatusRunning            = "running"
+	JobStatusOK                 = "ok"
+	JobStatusError              = "error"
+	JobStatusSkippedUnavailable = "skipped_unavailable"
+	JobStatusInterrupted        = "interrupted"
+)
+
+// What started a scan run (scan_runs.triggered_by).
+const (
+	TriggerManual   = "manual"
+	TriggerWatch    = "watch"
+	TriggerSchedule = "schedule"
+	TriggerRecovery = "recovery"
+)
+
+var ErrScanRunning = errors.New("scan already running")
+
+// ErrScannerStopped is returned by StartScan once StopScans has been called.
+var ErrScannerStopped = errors.New("scanner stopped")
+
+// Progress tracks counters for a scan in flight. A nil *Progress is valid and ignores updates.
+type Progress struct {
+	mu             sync.Mutex
+	filesTotal     int64
+	filesSeen      int64
+	tracksAdded    int64
+	tracksUpdated  int64
+	filesFailed    int64
+	tracksAnalyzed int64
+	currentPath    string
+}
+
+func (p *Progress) setTotal(n int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.filesTotal = n
+	p.mu.Unlock()
+}
+
+func (p *Progress) visit(path string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.filesSeen++
+	p.currentPath = path
+	p.mu.Unlock()
+}
+
+func (p *Progress) added() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.tracksAdded++
+	p.mu.Unlock()
+}
+
+func (p *Progress) updated() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.tracksUpdated++
+	p.mu.Unlock()
+}
+
+func (p *Progress) analyzed() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.tracksAnalyzed++
+	p.mu.Unlock()
+}
+
+func (p *Progress) failed() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.filesFailed++
+	p.mu.Unlock()
+}
+
+// Job is a folder scan running in the background, detached from the request that started it.
+type Job struct {
+	ID        int64 // scan_runs.id
+	FolderID  int64
+	Trigger   string
+	Full      bool
+	StartedAt time.Time
+
+	progress Progress
+	cancel   context.CancelFunc
+	done     chan struct{} // closed once the outcome is recorded
+
+	mu         sync.Mutex
+	status     string
+	finishedAt time.Time
+	err        string
+	removed    int64
+}
+
+// JobState is a point-in-time copy of a job's status and progress.
+type JobState struct {
+	ID           int64
+	FolderID     int64
+	Trigger      string
+	Full         bool
+	Status       string
+	StartedAt    time.Time
+	FinishedAt   *time.Time
+	Error        string
+	FilesTotal   int64
+	FilesSeen    int64
+	FilesChanged int64
+	CurrentPath  string
+	ETA          *time.Duration
+
+	TracksAdded    int64
+	TracksUpdated  int64
+	TracksRemoved  int64
+	FilesFailed    int64
+	TracksAnalyzed int64
+}
+
+func (j *Job) State() JobState {
+	j.mu.Lock()
+	state := JobState{
+		ID:        j.ID,
+		FolderID:  j.FolderID,
+		Trigger:   j.Trigger,
+		Full:      j.Full,
+		Status:    j.status,
+		StartedAt: j.StartedAt,
+		Error:     j.err,
+
+		TracksRemoved: j.removed,
+	}
+	if !j.finishedAt.IsZero() {
+		t := j.finishedAt
+		state.FinishedAt = &t
+	}
+	j.mu.Unlock()
+
+	j.progress.mu.Lock()
+	state.FilesTotal = j.progress.filesTotal
+	state.FilesSeen = j.progress.filesSeen
+	state.TracksAdded = j.progress.tracksAdded
+	state.TracksUpdated = j.progress.tracksUpdated
+	state.FilesChanged = state.TracksAdded + state.TracksUpdated
+	state.FilesFailed = j.progress.filesFailed
+	state.TracksAnalyzed = j.progress.tracksAnalyzed
+	state.CurrentPath = j.progress.currentPath
+	j.progress.mu.Unlock()
+
+	if state.Status == JobStatusRunning && state.FilesSeen > 0 && state.FilesTotal >= state.FilesSeen {
+		elapsed := time.Since(state.StartedAt)
+		remaining := time.Duration(float64(elapsed) / float64(state.FilesSeen) * float64(state.FilesTotal-state.FilesSeen))
+		state.ETA = &remaining
+	}
+	return state
+}
+
+func (j *Job) finish(status, errMsg string, result ScanResult) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status = status
+	j.err = errMsg
+	j.removed = result.Removed
+	j.finishedAt = time.Now()
+}
+
+// StartScan queues a background scan of a folder and returns immediately.
+// Only one scan per folder runs at a time; ErrScanRunning is returned otherwise.
+func (s *Scanner) StartScan(ctx context.Context, folderID int64, opts ScanOptions) (*Job, error) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+
+	if s.stopping {
+		return nil, ErrScannerStopped
+	}
+	if prev, ok := s.jobs[folderID]; ok && prev.State().Status == JobStatusRunning {
+		return prev, ErrScanRunning
+	}
+
+	startedAt, err := s.Q.StartFolderScan(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+	var full int64
+	if opts.Full {
+		full = 1
+	}
+	if opts.Trigger == "" {
+		opts.Trigger = TriggerManual
+	}
+	run, err := s.Q.CreateScanRun(ctx, db.CreateScanRunParams{
+		FolderID:    folderID,
+		FullRescan:  full,
+		TriggeredBy: opts.Trigger,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The scan must outlive the HTTP request that queued it; CancelScan and StopScans
+	// stop it instead.
+	scanCtx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		ID:        run.ID,
+		FolderID:  folderID,
+		Trigger:   opts.Trigger,
+		Full:      opts.Full,
+		StartedAt: startedAt.Time,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		status:    JobStatusRunning,
+	}
+	if s.jobs == nil {
+		s.jobs = make(map[int64]*Job)
+	}
+	s.jobs[folderID] = job
+
+	go s.runJob(scanCtx, job, opts)
+	return job, nil
+}
+
+// CancelScan stops the folder's running scan, if there is one. The run is recorded as
+// interrupted once the scan notices.
+func (s *Scanner) CancelScan(folderID int64) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	if job, ok := s.jobs[folderID]; ok {
+		job.cancel()
+	}
+}
+
+// StopScans cancels every running scan for shutdown and waits until they have stopped
+// or ctx is done. Their runs stay marked running, so RecoverInterruptedScans treats
+// them on the next start like scans cut off by a crash, and resumes them if asked to.
+func (s *Scanner) StopScans(ctx context.Context) {
+	s.jobsMu.Lock()
+	s.stopping = true
+	jobs := make([]*Job, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		job.cancel()
+		jobs = append(jobs, job)
+	}
+	s.jobsMu.Unlock()
+
+	for _, job := range jobs {
+		select {
+		case <-job.done:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// LatestJob returns the most recent in-memory job for a folder, if any.
+func (s *Scanner) LatestJob(folderID int64) (*Job, bool) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	job, ok := s.jobs[folderID]
+	return job, ok
+}
+
+func (s *Scanner) runJob(scanCtx context.Context, job *Job, opts ScanOptions) {
+	defer close(job.done)
+	defer job.cancel()
+	// The outcome is recorded even when the scan was canceled.
+	ctx := context.Background()
+	id := job.FolderID
+
+	opts.Progress = &job.progress
+	opts.RunID = job.ID
+	result, err := s.ScanFolder(scanCtx, id, opts)
+	if err == nil {
+		if err := s.Q.FinishFolderScanOK(ctx, id); err != nil {
+			log.Printf("failed to record scan success for folder %d: %v", id, err)
+		}
+		s.finishRun(ctx, job.ID, JobStatusOK, "", result)
+		job.finish(JobStatusOK, "", result)
+		return
+	}
+
+	s.jobsMu.Lock()
+	stopping := s.stopping
+	s.jobsMu.Unlock()
+
+	var finishErr error
+	status := JobStatusError
+	switch {
+	case scanCtx.Err() != nil && stopping:
+		// Left running for RecoverInterruptedScans.
+		log.Printf("scan of folder %d stopped for shutdown", id)
+		job.finish(JobStatusInterrupted, interruptedMessage, result)
+		return
+	case errors.Is(err, ErrFolderUnavailable):
+		status = JobStatusSkippedUnavailable
+		finishErr = s.Q.FinishFolderScanUnavailable(ctx, db.FinishFolderScanUnavailableParams{
+			LastScanError: dbtypes.NullString{String: err.Error(), Valid: true},
+			ID:            id,
+		})
+	case scanCtx.Err() != nil:
+		status = JobStatusInterrupted
+		err = errors.New("scan canceled")
+		finishErr = s.Q.FinishFolderScanError(ctx, db.FinishFolderScanErrorParams{
+			LastScanError: dbtypes.NullString{String: "scan canceled", Valid: true},
+			ID:            id,
+		})
+	default:
+		finishErr = s.Q.FinishFolderScanError(ctx, db.FinishFolderScanErrorParams{
+			LastScanError: dbtypes.NullString{String: err.Error(), Valid: true},
+			ID:            id,
+		})
+	}
+	if finishErr != nil {
+		log.Printf("failed to record scan failure for folder %d: %v", id, finishErr)
+	}
+	log.Printf("scan of folder %d failed: %v", id, err)
+	s.finishRun(ctx, job.ID, status, err.Error(), result)
+	job.finish(status, err.Error(), result)
+}
+
+func (s *Scanner) finishRun(ctx context.Context, runID int64, status, errMsg string, result ScanResult) {
+	err := s.Q.FinishScanRun(ctx, db.FinishScanRunParams{
+		Status:        status,
+		Error:         dbtypes.NullString{String: errMsg, Valid: errMsg != ""},
+		FilesSeen:     result.Seen,
+		TracksAdded:   result.Added,
+		TracksUpdated: result.Updated,
+		TracksRemoved: result.Removed,
+		FilesFailed:   result.Failed,
+		ID:            runID,
+	})
+	if err != nil {
+		log.Printf("failed to record scan run %d: %v", runID, err)
+	}
+}
+
+// countAudioFiles walks root once without reading tags so progress can report an ETA.
+func (s *Scanner) countAudioFiles(ctx context.Context, root string) (int64, error) {
+	var n int64
+	err := s.FS.WalkDir(root, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, ok := isMusic(d); ok {
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
@@ -0,0 +1,195 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"bottomley.ian/musicserver/internal/db"
+	dbtypes "bottomley.ian/musicserver/internal/dbtypes"
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// ReplayGainReferenceLUFS is the ReplayGain 2.0 target loudness that gains are relative to.
+const ReplayGainReferenceLUFS = -18
+
+// Loudness is a file's EBU R128 measurement.
+type Loudness struct {
+	IntegratedLUFS float64
+	TruePeakDBTP   float64
+}
+
+// GainDB is the ReplayGain adjustment that brings the file to the reference loudness.
+func (l Loudness) GainDB() float64 {
+	return ReplayGainReferenceLUFS - l.IntegratedLUFS
+}
+
+// loudnormOutput is the summary ffmpeg's loudnorm filter prints with print_format=json.
+// Values are strings, and "-inf" for silence.
+type loudnormOutput struct {
+	InputI  string `json:"input_i"`
+	InputTP string `json:"input_tp"`
+}
+
+// AnalyzeLoudness decodes the first audio stream of path through ffmpeg's loudnorm
+// filter in measurement mode. It is far slower than a probe, since the whole file is
+// decoded.
+func AnalyzeLoudness(ctx context.Context, path string) (Loudness, error) {
+	var stderr bytes.Buffer
+	stream := ffmpeg.Input(path).
+		Output("-", ffmpeg.KwArgs{"map": "0:a:0", "af": "loudnorm=print_format=json", "f": "null"}).
+		GlobalArgs("-hide_banner", "-nostats")
+	stream.Context = ctx
+	err := stream.WithErrorOutput(&stderr).Run()
+	if ctx.Err() != nil {
+		return Loudness{}, ctx.Err()
+	}
+	if err != nil {
+		return Loudness{}, fmt.Errorf("ffmpeg: %v: %s", err, lastLine(stderr.String()))
+	}
+
+	// The JSON summary is the last thing loudnorm logs.
+	out := stderr.String()
+	start, end := strings.LastIndex(out, "{"), strings.LastIndex(out, "}")
+	if start < 0 || end < start {
+		return Loudness{}, errors.New("no loudnorm summary in ffmpeg output")
+	}
+	var parsed loudnormOutput
+	if err := json.Unmarshal([]byte(out[start:end+1]), &parsed); err != nil {
+		return Loudness{}, fmt.Errorf("loudnorm summary: %w", err)
+	}
+	lufs, err := strconv.ParseFloat(parsed.InputI, 64)
+	if err != nil {
+		return Loudness{}, fmt.Errorf("loudnorm input_i: %w", err)
+	}
+	peak, err := strconv.ParseFloat(parsed.InputTP, 64)
+	if err != nil {
+		return Loudness{}, fmt.Errorf("loudnorm input_tp: %w", err)
+	}
+	if math.IsInf(lufs, 0) || math.IsNaN(lufs) || math.IsInf(peak, 0) || math.IsNaN(peak) {
+		return Loudness{}, errors.New("no audible audio")
+	}
+	return Loudness{IntegratedLUFS: lufs, TruePeakDBTP: peak}, nil
+}
+
+func lastLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndex(s, "\n"); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
+
+// loudnessItem is one track for the loudness pass, with its result once analyzed.
+type loudnessItem struct {
+	id       int64
+	rel      string
+	albumID  dbtypes.NullInt64
+	loudness Loudness
+	err      error
+}
+
+// analyzeFolderLoudness measures every live track in the folder that has not been
+// analyzed since it last changed, then refreshes the album rollups it touched. Files
+// that fail are recorded against the scan run and not retried until they change.
+func (s *Scanner) analyzeFolderLoudness(ctx context.Context, folderID int64, root string, runID int64, progress *Progress) (int64, error) {
+	rows, err := s.Q.ListTracksNeedingLoudnessForFolder(ctx, folderID)
+	if err != nil {
+		return 0, err
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := s.workers()
+	todo := make(chan loudnessItem)
+	done := make(chan loudnessItem, workers)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for it := range todo {
+				it.loudness, it.err = AnalyzeLoudness(ctx, filepath.Join(root, filepath.FromSlash(it.rel)))
+				select {
+				case done <- it:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(todo)
+		for _, row := range rows {
+			select {
+			case todo <- loudnessItem{id: row.ID, rel: row.RelPath, albumID: row.AlbumID}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	// Results are written from this goroutine alone, like the scan writer.
+	var analyzed int64
+	albums := make(map[int64]bool)
+	var writeErr error
+	for it := range done {
+		if writeErr != nil {
+			continue
+		}
+		if errors.Is(it.err, context.Canceled) {
+			continue
+		}
+		params := db.UpdateTrackLoudnessParams{ID: it.id}
+		if it.err != nil {
+			if err := s.recordFileError(ctx, s.Q, runID, it.rel, fmt.Sprintf("loudness: %v", it.err)); err != nil {
+				writeErr = err
+				cancel()
+				continue
+			}
+		} else {
+			params.LoudnessLufs = sql.NullFloat64{Float64: it.loudness.IntegratedLUFS, Valid: true}
+			params.PeakDbtp = sql.NullFloat64{Float64: it.loudness.TruePeakDBTP, Valid: true}
+			params.ReplayGainDb = sql.NullFloat64{Float64: it.loudness.GainDB(), Valid: true}
+		}
+		if err := s.Q.UpdateTrackLoudness(ctx, params); err != nil {
+			writeErr = err
+			cancel()
+			continue
+		}
+		if it.err == nil {
+			analyzed++
+			progress.analyzed()
+		}
+		if it.albumID.Valid {
+			albums[it.albumID.Int64] = true
+		}
+	}
+	if writeErr != nil {
+		return analyzed, writeErr
+	}
+	if err := ctx.Err(); err != nil {
+		return analyzed, err
+	}
+
+	if err := refreshAlbums(ctx, s.Q, albums); err != nil {
+		return analyzed, err
+	}
+	return analyzed, nil
+}
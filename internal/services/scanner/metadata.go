@@ -1,12 +1,15 @@
 package scanner
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"math"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/dhowden/tag"
 	ffmpeg "github.com/u2takey/ffmpeg-go"
@@ -19,15 +22,30 @@ type Picture struct {
 }
 
 type Metadata struct {
-	Title  string
-	Artist string
-	Album  string
-	Genre  string
-	Year   int
-	Track  int
+	Title       string
+	Artist      string
+	AlbumArtist string
+	Album       string
+	Genre       string
+	Composer    string
+	Comment     string
+	Lyrics      string
+	Year        int
+	ReleaseDate string // "YYYY", "YYYY-MM" or "YYYY-MM-DD"
+	Track       int
+	TrackTotal  int
+	Disc        int
+	DiscTotal   int
+	BPM         int
+	Compilation bool
+	Rating      int // 1-5 stars, 0 when the file has no rating
 
 	DurationSeconds *int64
+	Audio           *Audio // nil when ffprobe fails
 	Picture         *Picture
+
+	// Warnings are non-fatal problems (e.g. ffprobe failing); the file is still indexed.
+	Warnings []string
 }
 
 func (s *Scanner) ReadMetadata(path string) (Metadata, error) {
@@ -52,7 +70,19 @@ func (s *Scanner) ReadMetadata(path string) (Metadata, error) {
 		out.Album = m.Album()
 		out.Genre = m.Genre()
 		out.Year = m.Year()
-		out.Track, _ = m.Track()
+		out.AlbumArtist = m.AlbumArtist()
+		out.Composer = m.Composer()
+		out.Comment = m.Comment()
+		out.Lyrics = m.Lyrics()
+		out.Track, out.TrackTotal = m.Track()
+		out.Disc, out.DiscTotal = m.Disc()
+		out.BPM = rawBPM(m.Raw())
+		out.Compilation = rawCompilation(m.Raw())
+		out.ReleaseDate = rawReleaseDate(m.Raw())
+		out.Rating = rawRating(m.Raw())
+		if out.Year == 0 && out.ReleaseDate != "" {
+			out.Year, _ = strconv.Atoi(out.ReleaseDate[:4])
+		}
 
 		// sometimes works for both MP3/FLAC depending on tags
 		if pic := m.Picture(); pic != nil && len(pic.Data) > 0 {
@@ -63,9 +93,24 @@ func (s *Scanner) ReadMetadata(path string) (Metadata, error) {
 			}
 		}
 	}
-	durationSeconds, err := probeDurationSeconds(path)
+	probed, err := probe(path)
+	if err != nil {
+		out.Warnings = append(out.Warnings, fmt.Sprintf("ffprobe: %v", err))
+		return out, nil
+	}
+	out.Audio = probed.audio()
+	if out.Audio != nil && out.Audio.Codec == "mp3" {
+		// ffprobe does not report the LAME header, so read it from the file.
+		g, ok, err := readLAMEGapless(rc)
+		if err != nil {
+			out.Warnings = append(out.Warnings, fmt.Sprintf("LAME header: %v", err))
+		} else if ok {
+			out.Audio.setGapless(g)
+		}
+	}
+	durationSeconds, err := probed.durationSeconds()
 	if err != nil {
-		log.Printf("warn: ffprobe failed for %s: %v", path, err)
+		out.Warnings = append(out.Warnings, fmt.Sprintf("ffprobe: %v", err))
 		return out, nil
 	}
 	out.DurationSeconds = durationSeconds
@@ -92,25 +137,163 @@ func (s *Scanner) ReadMetadata(path string) (Metadata, error) {
 	return out, nil
 }
 
+// Tag keys that the generic reader exposes only through Raw(), per container:
+// ID3v2.3/4, ID3v2.2, Vorbis comments and MP4 atoms.
+var (
+	bpmKeys         = []string{"TBPM", "TBP", "bpm", "tmpo"}
+	compilationKeys = []string{"TCMP", "TCP", "compilation", "cpil"}
+	releaseDateKeys = []string{"TDRL", "TDRC", "TYER", "TYE", "date", "\xa9day"}
+	popmKeys        = []string{"POPM", "POP"}
+)
+
+// releaseDate matches the date prefix of tags like "2001-05-03" or "2001-05-03T07:00:00Z".
+var releaseDate = regexp.MustCompile(`^\d{4}(-\d{2}(-\d{2})?)?`)
+
+func rawBPM(raw map[string]interface{}) int {
+	for _, k := range bpmKeys {
+		switch v := raw[k].(type) {
+		case int:
+			if v > 0 {
+				return v
+			}
+		case string:
+			// Some taggers write fractional tempos such as "120.5".
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && f > 0 {
+				return int(math.Round(f))
+			}
+		}
+	}
+	return 0
+}
+
+func rawCompilation(raw map[string]interface{}) bool {
+	for _, k := range compilationKeys {
+		switch v := raw[k].(type) {
+		case int:
+			return v == 1
+		case string:
+			v = strings.TrimSpace(v)
+			return v == "1" || strings.EqualFold(v, "true")
+		}
+	}
+	return false
+}
+
+func rawReleaseDate(raw map[string]interface{}) string {
+	for _, k := range releaseDateKeys {
+		if v, ok := raw[k].(string); ok {
+			if d := releaseDate.FindString(strings.TrimSpace(v)); d != "" {
+				return d
+			}
+		}
+	}
+	return ""
+}
+
+// rawRating reads a 1-5 star rating from an ID3 POPM frame or from the FMPS_RATING
+// (0.0-1.0) or RATING (1-5 or 0-100) Vorbis comments.
+func rawRating(raw map[string]interface{}) int {
+	for _, k := range popmKeys {
+		if b, ok := raw[k].([]byte); ok {
+			// Owner email, NUL, then the rating byte.
+			if i := bytes.IndexByte(b, 0); i >= 0 && i+1 < len(b) {
+				return popmStars(b[i+1])
+			}
+		}
+	}
+	if v, ok := raw["fmps_rating"].(string); ok {
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && f > 0 && f <= 1 {
+			return max(1, int(math.Round(f*5)))
+		}
+	}
+	if v, ok := raw["rating"].(string); ok {
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil && n > 0 {
+			if n <= 5 {
+				return n
+			}
+			if n <= 100 {
+				return max(1, int(math.Round(float64(n)/20)))
+			}
+		}
+	}
+	return 0
+}
+
+// Audio is the first audio stream's properties as ffprobe reports them, with gapless
+// info where the file carries it.
+type Audio struct {
+	DurationMs int64
+	SampleRate int64 // Hz
+	BitDepth   int64 // 0 for lossy codecs, which have none
+	Channels   int64
+	Codec      string // ffprobe codec_name
+	Lossless   bool
+	Bitrate    int64 // kbit/s
+	Gapless    *Gapless
+}
+
+// losslessCodecs are the ffprobe codec names of lossless encodings. PCM variants
+// ("pcm_s16le" and so on) are matched by prefix. Migration 020 backfills from the same list.
+var losslessCodecs = map[string]bool{
+	"flac":    true,
+	"alac":    true,
+	"ape":     true,
+	"wavpack": true,
+	"tta":     true,
+	"mlp":     true,
+	"truehd":  true,
+}
+
+func isLosslessCodec(codec string) bool {
+	return losslessCodecs[codec] || strings.HasPrefix(codec, "pcm_")
+}
+
+// setGapless records g and, when it knows the exact sample count, derives the duration
+// from it rather than from the container.
+func (a *Audio) setGapless(g Gapless) {
+	a.Gapless = &g
+	if g.Samples > 0 && a.SampleRate > 0 {
+		a.DurationMs = int64(math.Round(float64(g.Samples) * 1000 / float64(a.SampleRate)))
+	}
+}
+
+type ffprobeStream struct {
+	CodecType        string            `json:"codec_type"`
+	CodecName        string            `json:"codec_name"`
+	SampleRate       string            `json:"sample_rate"`
+	Channels         int64             `json:"channels"`
+	BitsPerSample    int64             `json:"bits_per_sample"`
+	BitsPerRawSample string            `json:"bits_per_raw_sample"`
+	BitRate          string            `json:"bit_rate"`
+	TimeBase         string            `json:"time_base"`
+	DurationTS       int64             `json:"duration_ts"`
+	Tags             map[string]string `json:"tags"`
+}
+
 type ffprobeOutput struct {
 	Format struct {
-		Duration string `json:"duration"`
+		Duration string            `json:"duration"`
+		BitRate  string            `json:"bit_rate"`
+		Tags     map[string]string `json:"tags"`
 	} `json:"format"`
+	Streams []ffprobeStream `json:"streams"`
 }
 
-func probeDurationSeconds(path string) (*int64, error) {
+func probe(path string) (ffprobeOutput, error) {
+	var parsed ffprobeOutput
 	raw, err := ffmpeg.Probe(path)
 	if err != nil {
-		return nil, err
-	}
-	var parsed ffprobeOutput
-	if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
-		return nil, err
+		return parsed, err
 	}
-	if parsed.Format.Duration == "" {
+	err = json.Unmarshal([]byte(raw), &parsed)
+	return parsed, err
+}
+
+func (p ffprobeOutput) durationSeconds() (*int64, error) {
+	if p.Format.Duration == "" {
 		return nil, nil
 	}
-	secondsFloat, err := strconv.ParseFloat(parsed.Format.Duration, 64)
+	secondsFloat, err := strconv.ParseFloat(p.Format.Duration, 64)
 	if err != nil {
 		return nil, err
 	}
@@ -124,6 +307,73 @@ func probeDurationSeconds(path string) (*int64, error) {
 	return &seconds, nil
 }
 
+// audio returns the properties of the first audio stream, or nil when there is none.
+// Fields ffprobe leaves out stay zero.
+func (p ffprobeOutput) audio() *Audio {
+	i := slices.IndexFunc(p.Streams, func(st ffprobeStream) bool { return st.CodecType == "audio" })
+	if i < 0 {
+		return nil
+	}
+	st := p.Streams[i]
+	a := &Audio{
+		Codec:    st.CodecName,
+		Lossless: isLosslessCodec(st.CodecName),
+		Channels: st.Channels,
+	}
+	a.SampleRate, _ = strconv.ParseInt(st.SampleRate, 10, 64)
+	if a.Lossless {
+		a.BitDepth, _ = strconv.ParseInt(st.BitsPerRawSample, 10, 64)
+		if a.BitDepth == 0 {
+			a.BitDepth = st.BitsPerSample
+		}
+	}
+	// FLAC and WAV streams have no bitrate of their own; the container's is close enough.
+	bitrate, _ := strconv.ParseFloat(st.BitRate, 64)
+	if bitrate <= 0 {
+		bitrate, _ = strconv.ParseFloat(p.Format.BitRate, 64)
+	}
+	a.Bitrate = int64(math.Round(bitrate / 1000))
+	if seconds, err := strconv.ParseFloat(p.Format.Duration, 64); err == nil && seconds > 0 {
+		a.DurationMs = int64(math.Round(seconds * 1000))
+	}
+
+	switch {
+	case a.Lossless:
+		// No encoder delay; the stream length in samples is exact.
+		if samples := st.samples(a.SampleRate); samples > 0 {
+			a.setGapless(Gapless{Samples: samples})
+		}
+	default:
+		if g, ok := parseITunSMPB(tagValue("iTunSMPB", p.Format.Tags, st.Tags)); ok {
+			a.setGapless(g)
+		}
+	}
+	return a
+}
+
+// samples converts the stream's duration_ts to samples at sampleRate.
+func (st ffprobeStream) samples(sampleRate int64) int64 {
+	num, den, ok := strings.Cut(st.TimeBase, "/")
+	n, err1 := strconv.ParseInt(num, 10, 64)
+	d, err2 := strconv.ParseInt(den, 10, 64)
+	if !ok || err1 != nil || err2 != nil || n <= 0 || d <= 0 || st.DurationTS <= 0 || sampleRate <= 0 {
+		return 0
+	}
+	return int64(math.Round(float64(st.DurationTS) * float64(n) * float64(sampleRate) / float64(d)))
+}
+
+// tagValue looks key up, ignoring case, in each tag map in turn.
+func tagValue(key string, tags ...map[string]string) string {
+	for _, m := range tags {
+		for k, v := range m {
+			if strings.EqualFold(k, key) {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
 /*
 // --- MP3 cover art (ID3 APIC) ---
 
@@ -0,0 +1,150 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"bottomley.ian/musicserver/internal/db"
+	myfs "bottomley.ian/musicserver/internal/services/fs"
+)
+
+// nightlyHour is the local hour at which "nightly" scheduled scans become due.
+const nightlyHour = 3
+
+// Schedule is a parsed folders.scan_schedule value.
+type Schedule struct {
+	Nightly bool
+	Every   time.Duration
+}
+
+// ParseSchedule accepts "nightly" or a whole number of hours such as "6h".
+func ParseSchedule(raw string) (Schedule, error) {
+	v := strings.ToLower(strings.TrimSpace(raw))
+	if v == "nightly" {
+		return Schedule{Nightly: true}, nil
+	}
+	hours, err := strconv.Atoi(strings.TrimSuffix(v, "h"))
+	if err != nil || !strings.HasSuffix(v, "h") || hours <= 0 {
+		return Schedule{}, fmt.Errorf("invalid schedule %q: want \"nightly\" or hours like \"6h\"", raw)
+	}
+	return Schedule{Every: time.Duration(hours) * time.Hour}, nil
+}
+
+// Due reports whether a scan last started at lastScan should run again at now.
+func (sc Schedule) Due(lastScan, now time.Time) bool {
+	if sc.Nightly {
+		local := now.Local()
+		boundary := time.Date(local.Year(), local.Month(), local.Day(), nightlyHour, 0, 0, 0, local.Location())
+		if local.Before(boundary) {
+			boundary = boundary.AddDate(0, 0, -1)
+		}
+		return lastScan.Before(boundary)
+	}
+	return !lastScan.Add(sc.Every).After(now)
+}
+
+// MonitorFolders stats every folder root each interval until ctx is done. It keeps
+// folders.available current, restarts watchers when a root comes back (with a catch-up
+// scan, since changes made while it was gone were never seen), and starts scheduled
+// rescans that are due.
+func (s *Scanner) MonitorFolders(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.checkFolders(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scanner) checkFolders(ctx context.Context) {
+	folders, err := s.Q.ListFolders(ctx)
+	if err != nil {
+		log.Printf("warn: folder monitor failed to list folders: %v", err)
+		return
+	}
+	for _, f := range folders {
+		if ctx.Err() != nil {
+			return
+		}
+		s.checkFolder(ctx, f)
+	}
+}
+
+func (s *Scanner) checkFolder(ctx context.Context, f db.Folder) {
+	available := s.rootAvailable(f.Path)
+
+	var flag int64
+	if available {
+		flag = 1
+	}
+	err := s.Q.SetFolderAvailability(ctx, db.SetFolderAvailabilityParams{
+		Available: flag,
+		Column2:   flag,
+		ID:        f.ID,
+	})
+	if err != nil {
+		log.Printf("warn: failed to record availability for folder %d: %v", f.ID, err)
+		return
+	}
+
+	if wasAvailable := f.Available == 1; wasAvailable != available {
+		if available {
+			log.Printf("folder %d (%s) is available again", f.ID, f.Path)
+			if f.Watch == 1 {
+				if _, err := s.StartScan(ctx, f.ID, ScanOptions{Trigger: TriggerWatch}); err != nil && !errors.Is(err, ErrScanRunning) {
+					log.Printf("warn: failed to start catch-up scan of folder %d: %v", f.ID, err)
+				}
+			}
+		} else {
+			log.Printf("folder %d (%s) is unavailable", f.ID, f.Path)
+			s.StopWatching(f.ID)
+		}
+	}
+	if available && f.Watch == 1 {
+		if err := s.StartWatching(f.ID); err != nil {
+			log.Printf("warn: failed to watch folder %d (%s): %v", f.ID, f.Path, err)
+		}
+	}
+
+	if !f.ScanSchedule.Valid {
+		return
+	}
+	sc, err := ParseSchedule(f.ScanSchedule.String)
+	if err != nil {
+		log.Printf("warn: folder %d: %v", f.ID, err)
+		return
+	}
+
+	due := !f.LastScanAt.Valid || sc.Due(f.LastScanAt.Time, time.Now())
+	// A scheduled scan skipped while the root was gone runs as soon as it returns.
+	if available && f.LastScanStatus.Valid && f.LastScanStatus.String == JobStatusSkippedUnavailable {
+		due = true
+	}
+	if !due {
+		return
+	}
+
+	// Unavailable roots still get a scan attempt so the skip is recorded as skipped_unavailable.
+	if _, err := s.StartScan(ctx, f.ID, ScanOptions{Trigger: TriggerSchedule}); err != nil && !errors.Is(err, ErrScanRunning) {
+		log.Printf("warn: failed to start scheduled scan of folder %d: %v", f.ID, err)
+	}
+}
+
+func (s *Scanner) rootAvailable(path string) bool {
+	root, err := myfs.ExpandPath(path)
+	if err != nil {
+		return false
+	}
+	info, err := s.FS.Stat(root)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,47 @@
+package scanner
+
+import (
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// discSuffix matches a trailing disc marker such as "[CD1]", "(Disc 2)", "- Part 3" or "Pt. 2".
+var discSuffix = regexp.MustCompile(`(?i)^(.*?)[\s\-_:,]*[\[(]?\s*\b(?:cd|disc|disk|part|pt\.?)\s*(\d{1,2})\s*[\])]?$`)
+
+// splitDiscSuffix returns the title without its disc marker and the disc number,
+// or disc 0 if the title has no marker. The base may be empty (e.g. a folder named "CD2").
+func splitDiscSuffix(title string) (string, int) {
+	m := discSuffix.FindStringSubmatch(strings.TrimSpace(title))
+	if m == nil {
+		return title, 0
+	}
+	disc, err := strconv.Atoi(m[2])
+	if err != nil || disc == 0 {
+		return title, 0
+	}
+	return strings.TrimSpace(m[1]), disc
+}
+
+// normalizeMultipart folds "Lost Dogs [CD2]" and files under ".../CD2/" into the
+// canonical album title. Disc numbers from tags win over ones guessed from names,
+// except that a title suffix beats a tag of "1/1" or a disc with no total: rippers
+// often tag each disc of a set as a single-disc album.
+func normalizeMultipart(f audioFile, m *Metadata) {
+	if base, n := splitDiscSuffix(m.Album); n > 0 && base != "" {
+		m.Album = base
+		if m.Disc == 0 || m.DiscTotal <= 1 {
+			m.Disc = n
+			if m.DiscTotal == 1 {
+				m.DiscTotal = 0
+			}
+		}
+		return
+	}
+	if m.Disc == 0 {
+		if dir := path.Base(path.Dir(f.rel)); dir != "." {
+			_, m.Disc = splitDiscSuffix(dir)
+		}
+	}
+}
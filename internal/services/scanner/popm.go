@@ -0,0 +1,116 @@
+package scanner
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// popmEmail is the POPM owner written with ratings. Most players read the frame
+// Windows Media Player writes, so ratings go under its address.
+const popmEmail = "Windows Media Player 9 Series"
+
+// popmByte maps 1-5 stars to the POPM values Windows Media Player uses.
+var popmByte = [6]byte{0, 1, 64, 128, 196, 255}
+
+// popmStars maps a POPM value (0-255) back to stars, rounding to the nearest band.
+func popmStars(b byte) int {
+	switch {
+	case b == 0:
+		return 0
+	case b < 32:
+		return 1
+	case b < 96:
+		return 2
+	case b < 160:
+		return 3
+	case b < 224:
+		return 4
+	}
+	return 5
+}
+
+// setPOPM returns data with every POPM frame in its ID3v2 tag replaced by one holding
+// rating (1-5), or removed when rating is 0. Files without a tag get an ID3v2.4 one.
+// The extended header and footer are dropped rather than recomputed.
+func setPOPM(data []byte, rating int) ([]byte, error) {
+	version := byte(4)
+	var frames []byte
+	var tagSize int
+	audio := data
+
+	if len(data) >= 10 && string(data[:3]) == "ID3" {
+		version = data[3]
+		flags := data[5]
+		if version != 3 && version != 4 {
+			return nil, fmt.Errorf("unsupported ID3v2.%d tag", version)
+		}
+		if flags&0x80 != 0 {
+			return nil, errors.New("unsynchronised ID3v2 tags are not supported")
+		}
+		tagSize = synchsafe(data[6:10])
+		if 10+tagSize > len(data) {
+			return nil, errors.New("truncated ID3v2 tag")
+		}
+		body := data[10 : 10+tagSize]
+		audio = data[10+tagSize:]
+		if flags&0x10 != 0 && len(audio) >= 10 {
+			audio = audio[10:]
+		}
+		if flags&0x40 != 0 && len(body) >= 4 {
+			n := int(binary.BigEndian.Uint32(body[:4])) + 4
+			if version == 4 {
+				n = synchsafe(body[:4])
+			}
+			if n > len(body) {
+				return nil, errors.New("truncated ID3v2 extended header")
+			}
+			body = body[n:]
+		}
+		// Frames are copied verbatim up to the padding.
+		for len(body) >= 10 && body[0] != 0 {
+			n := int(binary.BigEndian.Uint32(body[4:8]))
+			if version == 4 {
+				n = synchsafe(body[4:8])
+			}
+			if 10+n > len(body) {
+				return nil, errors.New("truncated ID3v2 frame")
+			}
+			if string(body[:4]) != "POPM" {
+				frames = append(frames, body[:10+n]...)
+			}
+			body = body[10+n:]
+		}
+	} else if rating == 0 {
+		return data, nil
+	}
+
+	if rating > 0 {
+		popm := append([]byte(popmEmail), 0, popmByte[rating])
+		frames = append(frames, "POPM"...)
+		if version == 4 {
+			frames = append(frames, synchsafeBytes(len(popm))...)
+		} else {
+			frames = binary.BigEndian.AppendUint32(frames, uint32(len(popm)))
+		}
+		frames = append(frames, 0, 0)
+		frames = append(frames, popm...)
+	}
+
+	// Keep the old tag size where the frames still fit, so the padding is reused.
+	size := max(len(frames), tagSize)
+	out := make([]byte, 0, 10+size+len(audio))
+	out = append(out, 'I', 'D', '3', version, 0, 0)
+	out = append(out, synchsafeBytes(size)...)
+	out = append(out, frames...)
+	out = append(out, make([]byte, size-len(frames))...)
+	return append(out, audio...), nil
+}
+
+func synchsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+func synchsafeBytes(n int) []byte {
+	return []byte{byte(n>>21) & 0x7f, byte(n>>14) & 0x7f, byte(n>>7) & 0x7f, byte(n) & 0x7f}
+}
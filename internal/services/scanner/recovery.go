@@ -0,0 +1,77 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	dbtypes "bottomley.ian/musicserver/internal/dbtypes"
+)
+
+// Recovery modes for scans left running when the process stopped.
+const (
+	// RecoveryNone only marks interrupted scans; rescans are left to the user.
+	RecoveryNone = "none"
+	// RecoveryResume starts an incremental scan, which skips files committed before the
+	// interruption and so picks up roughly where the old scan stopped.
+	RecoveryResume = "resume"
+	// RecoveryRestart reruns the interrupted scan with its original options.
+	RecoveryRestart = "restart"
+)
+
+const interruptedMessage = "interrupted: server stopped before the scan finished"
+
+// RecoverInterruptedScans runs once at startup, before any scan is queued. Scan runs and
+// folders still marked running are reconciled to interrupted/error, then optionally rescanned.
+func (s *Scanner) RecoverInterruptedScans(ctx context.Context, mode string) error {
+	switch mode {
+	case RecoveryNone, RecoveryResume, RecoveryRestart:
+	default:
+		return fmt.Errorf("invalid scan recovery mode %q: want %q, %q or %q", mode, RecoveryNone, RecoveryResume, RecoveryRestart)
+	}
+
+	msg := dbtypes.NullString{String: interruptedMessage, Valid: true}
+	runs, err := s.Q.InterruptRunningScanRuns(ctx, msg)
+	if err != nil {
+		return err
+	}
+	folderIDs, err := s.Q.InterruptRunningFolderScans(ctx, msg)
+	if err != nil {
+		return err
+	}
+
+	// Folders stuck from before scan_runs existed have no run row; rescan them incrementally.
+	interrupted := make(map[int64]ScanOptions, len(folderIDs))
+	for _, id := range folderIDs {
+		interrupted[id] = ScanOptions{Trigger: TriggerRecovery}
+	}
+	for _, run := range runs {
+		opts := interrupted[run.FolderID]
+		opts.Trigger = TriggerRecovery
+		opts.Full = opts.Full || run.FullRescan == 1
+		interrupted[run.FolderID] = opts
+	}
+	if len(interrupted) == 0 {
+		return nil
+	}
+	log.Printf("marked %d interrupted scan(s) across %d folder(s)", len(runs), len(interrupted))
+
+	if mode == RecoveryNone {
+		return nil
+	}
+	for folderID, opts := range interrupted {
+		if mode == RecoveryResume {
+			opts.Full = false
+		}
+		folder, err := s.Q.GetFolderByID(ctx, folderID)
+		if err != nil || folder.DeletedAt.Valid {
+			continue
+		}
+		if _, err := s.StartScan(ctx, folderID, opts); err != nil {
+			log.Printf("warn: failed to %s scan of folder %d: %v", mode, folderID, err)
+			continue
+		}
+		log.Printf("rescanning folder %d after interrupted scan (%s)", folderID, mode)
+	}
+	return nil
+}
@@ -2,13 +2,17 @@ package scanner
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"log"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"bottomley.ian/musicserver/internal/db"
 	dbtypes "bottomley.ian/musicserver/internal/dbtypes"
@@ -16,14 +20,65 @@ import (
 )
 
 type Scanner struct {
+	DB *sql.DB
 	Q  *db.Queries
 	FS myfs.FS
+
+	// Workers is the number of concurrent metadata readers (tag parse + ffprobe);
+	// zero means one per CPU.
+	Workers int
+	// BatchSize is how many tracks the scan writer commits per transaction; zero means defaultBatchSize.
+	BatchSize int
+	// Loudness runs the loudness pass after every scan, as if ScanOptions.Loudness were set.
+	Loudness bool
+
+	jobsMu   sync.Mutex
+	jobs     map[int64]*Job
+	stopping bool // set by StopScans; no new jobs start
+
+	watchMu sync.Mutex
+	watches map[int64]*folderWatch
+}
+
+// ScanOptions controls a single ScanFolder pass.
+type ScanOptions struct {
+	// Progress receives file counters as the walk proceeds; may be nil.
+	Progress *Progress
+	// Full re-reads tags and duration for every file, ignoring size/mtime matches.
+	Full bool
+	// Trigger records what started the scan (TriggerManual when empty).
+	Trigger string
+	// RunID is the scan_runs row that per-file errors are recorded against; 0 only logs them.
+	RunID int64
+	// Loudness measures tracks not yet analyzed (ffmpeg loudnorm) once the walk completes.
+	Loudness bool
+}
+
+// ScanResult summarizes what a completed scan changed in the index.
+type ScanResult struct {
+	Seen     int64
+	Added    int64
+	Updated  int64
+	Removed  int64
+	Failed   int64
+	Analyzed int64 // tracks measured by the loudness pass
+}
+
+type fileStat struct {
+	id           int64
+	sizeBytes    int64
+	lastModified int64
 }
 
-func New(q *db.Queries, fs myfs.FS) *Scanner {
+const defaultBatchSize = 200
+
+func New(database *sql.DB, q *db.Queries, fs myfs.FS) *Scanner {
 	return &Scanner{
-		Q:  q,
-		FS: fs,
+		DB:      database,
+		Q:       q,
+		FS:      fs,
+		jobs:    make(map[int64]*Job),
+		watches: make(map[int64]*folderWatch),
 	}
 }
 
@@ -42,180 +97,584 @@ func isMusic(entry fs.DirEntry) (ext string, ok bool) {
 	if entry.IsDir() {
 		return "", false
 	}
-	if strings.HasPrefix(entry.Name(), "._") {
+	return isMusicName(entry.Name())
+}
+
+func isMusicName(name string) (ext string, ok bool) {
+	if strings.HasPrefix(name, "._") || strings.HasPrefix(name, tagWritePrefix) {
 		return "", false
 	}
-	ext = strings.ToLower(filepath.Ext(entry.Name()))
+	ext = strings.ToLower(filepath.Ext(name))
 	return ext, audioExt[ext]
 }
 
-func (s *Scanner) ScanFolder(ctx context.Context, folderID int64) error {
+// ScanFolder walks a folder root, indexes new and changed audio files, and after a
+// complete walk soft-deletes tracks that were not seen plus any artists/albums left empty.
+// Missing-track detection uses folders.last_scan_at, so call StartFolderScan first.
+func (s *Scanner) ScanFolder(ctx context.Context, folderID int64, opts ScanOptions) (ScanResult, error) {
+	var result ScanResult
+
 	folder, err := s.Q.GetFolderByID(ctx, folderID)
 	if err != nil {
-		return err
+		return result, err
 	}
 	root, err := myfs.ExpandPath(folder.Path)
 	if err != nil {
-		return err
+		return result, err
 	}
 	info, statErr := s.FS.Stat(root)
 	if statErr != nil {
-		return fmt.Errorf("%w: %v", ErrFolderUnavailable, statErr)
+		return result, fmt.Errorf("%w: %v", ErrFolderUnavailable, statErr)
 	}
 	if !info.IsDir() {
-		return fmt.Errorf("%w: %s is not a directory", ErrFolderUnavailable, root)
+		return result, fmt.Errorf("%w: %s is not a directory", ErrFolderUnavailable, root)
 	}
 	log.Printf("%s", root)
-	return s.FS.WalkDir(root, func(path string, d fs.DirEntry, walkErr error) error {
 
-		if walkErr != nil {
-			return walkErr
-		}
+	total, err := s.countAudioFiles(ctx, root)
+	if err != nil {
+		return result, err
+	}
+	opts.Progress.setTotal(total)
 
-		// Respect cancellation
+	known, err := s.knownFiles(ctx, folderID)
+	if err != nil {
+		return result, err
+	}
+
+	// Walk and metadata reads run concurrently; DB writes funnel through writeItems.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := s.workers()
+	files := make(chan scanItem, workers*2)
+	items := make(chan scanItem, workers*2)
+	send := func(ch chan<- scanItem, it scanItem) error {
 		select {
+		case ch <- it:
+			return nil
 		case <-ctx.Done():
 			return ctx.Err()
-		default:
 		}
+	}
 
-		ext, ok := isMusic(d)
-		if !ok {
-			return err
-		}
+	var walkErr error
+	var seen int64
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(files)
+		walkErr = s.FS.WalkDir(root, func(path string, d fs.DirEntry, walkErr error) error {
+			if walkErr != nil {
+				return walkErr
+			}
+
+			// Respect cancellation
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			ext, ok := isMusic(d)
+			if !ok {
+				return nil
+			}
+
+			seen++
+			opts.Progress.visit(path)
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			sizeBytes := info.Size()
+			lastModified := info.ModTime().Unix()
+
+			// Unchanged since the last scan: keep it alive without re-reading tags or probing.
+			prev, indexed := known[rel]
+			if indexed && !opts.Full && prev.sizeBytes == sizeBytes && prev.lastModified == lastModified {
+				return send(items, scanItem{touchID: prev.id})
+			}
+
+			return send(files, scanItem{
+				file: audioFile{
+					path:         path,
+					rel:          rel,
+					name:         d.Name(),
+					ext:          ext,
+					sizeBytes:    sizeBytes,
+					lastModified: lastModified,
+				},
+				knownID: prev.id,
+			})
+		})
+	}()
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for it := range files {
+				it.metadata, it.err = s.ReadMetadata(it.file.path)
+				if send(items, it) != nil {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(items)
+	}()
+
+	err = s.writeItems(ctx, folderID, opts.RunID, items, &result, opts.Progress)
+	if err != nil {
+		cancel()
+	}
+	// Drain until the walker and workers have exited so walkErr is safe to read.
+	for range items {
+	}
+	result.Seen = seen
+	if err != nil {
+		return result, err
+	}
+	if walkErr != nil {
+		return result, walkErr
+	}
 
-		info, err := d.Info()
+	// Albums written during the walk were refreshed as each batch committed; only the
+	// albums of removed tracks are left.
+	albums := map[int64]bool{}
+	if folder.LastScanAt.Valid {
+		removed, err := s.Q.MarkMissingTracksForFolder(ctx, db.MarkMissingTracksForFolderParams{
+			FolderID:      folderID,
+			ScanStartedAt: sqliteTimestamp(folder.LastScanAt.Time),
+		})
 		if err != nil {
-			return err
+			return result, err
 		}
-
-		rel, err := filepath.Rel(root, path)
+		result.Removed = int64(len(removed))
+		addAlbums(albums, removed)
+	}
+	if err := s.pruneOrphans(ctx, albums); err != nil {
+		return result, err
+	}
+	if opts.Loudness || s.Loudness {
+		result.Analyzed, err = s.analyzeFolderLoudness(ctx, folderID, root, opts.RunID, opts.Progress)
 		if err != nil {
-			return err
+			return result, err
 		}
-		rel = filepath.ToSlash(rel)
-		sizeBytes := info.Size()
-		lastModified := info.ModTime().Unix()
-		baseTitle := strings.TrimSuffix(d.Name(), ext)
-
-		utp := db.UpsertTrackParams{
-			FolderID:     folderID,
-			RelPath:      rel,
-			Title:        baseTitle,
-			Filename:     d.Name(),
-			Ext:          strings.TrimPrefix(ext, "."),
-			SizeBytes:    sizeBytes,
-			LastModified: lastModified,
+	}
+
+	log.Printf("scan of %s done: %d added, %d updated, %d removed, %d failed, %d analyzed", root, result.Added, result.Updated, result.Removed, result.Failed, result.Analyzed)
+	return result, nil
+}
+
+// sqliteTimestamp formats t like CURRENT_TIMESTAMP so it compares correctly with stored DATETIME text.
+func sqliteTimestamp(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
+
+// pruneOrphans recomputes the rollups of the albums that tracks have left, then
+// soft-deletes albums and artists that no longer have live tracks.
+func (s *Scanner) pruneOrphans(ctx context.Context, albums map[int64]bool) error {
+	// Removed tracks change counts and durations on albums that survive the prune.
+	if err := refreshAlbums(ctx, s.Q, albums); err != nil {
+		return err
+	}
+	if _, err := s.Q.SoftDeleteOrphanedAlbums(ctx); err != nil {
+		return err
+	}
+	_, err := s.Q.SoftDeleteOrphanedArtists(ctx)
+	return err
+}
+
+// audioFile is an audio file discovered under a folder root.
+type audioFile struct {
+	path         string
+	rel          string // slash-separated, relative to the folder root
+	name         string
+	ext          string // lowercase, with dot
+	sizeBytes    int64
+	lastModified int64
+}
+
+// scanItem is one unit of work for the scan writer: either an unchanged file that only
+// needs last_seen_at touched, or a file whose metadata a worker has read.
+type scanItem struct {
+	touchID  int64
+	file     audioFile
+	knownID  int64 // existing track id for a changed file; 0 when new
+	metadata Metadata
+	err      error
+}
+
+func (s *Scanner) workers() int {
+	if s.Workers > 0 {
+		return s.Workers
+	}
+	return runtime.NumCPU()
+}
+
+func (s *Scanner) batchSize() int {
+	if s.BatchSize > 0 {
+		return s.BatchSize
+	}
+	return defaultBatchSize
+}
+
+// writeItems applies scan results from a single goroutine, committing a transaction
+// every batchSize items instead of paying for one SQLite commit per track.
+// A file whose metadata cannot be read is recorded as failed and skipped; an already
+// indexed track keeps its row so it is not marked missing.
+func (s *Scanner) writeItems(ctx context.Context, folderID, runID int64, items <-chan scanItem, result *ScanResult, progress *Progress) error {
+	var tx *sql.Tx
+	var q *db.Queries
+	pending := 0
+	albums := map[int64]bool{}
+	defer func() {
+		if tx != nil {
+			_ = tx.Rollback()
 		}
-		track, err := s.Q.UpsertTrack(ctx, utp)
-		if err != nil {
+	}()
+
+	commit := func() error {
+		if tx == nil {
+			return nil
+		}
+		if err := refreshAlbums(ctx, q, albums); err != nil {
 			return err
 		}
+		err := tx.Commit()
+		tx, q, pending = nil, nil, 0
+		clear(albums)
+		return err
+	}
 
-		metadata, err := s.ReadMetadata(path)
-		if err != nil {
-			return err
+	for it := range items {
+		if tx == nil {
+			var err error
+			tx, err = s.DB.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			q = s.Q.WithTx(tx)
 		}
-		artistID, albumID, albumRow, err := s.upsertArtistAlbum(ctx, metadata.Artist, metadata.Album)
-		if err != nil {
-			return err
+
+		switch {
+		case it.touchID != 0:
+			if err := q.TouchTrackSeen(ctx, it.touchID); err != nil {
+				return err
+			}
+		case it.err != nil:
+			if err := s.recordFileError(ctx, q, runID, it.file.rel, it.err.Error()); err != nil {
+				return err
+			}
+			if it.knownID != 0 {
+				if err := q.TouchTrackSeen(ctx, it.knownID); err != nil {
+					return err
+				}
+			}
+			result.Failed++
+			progress.failed()
+		default:
+			if err := s.writeFile(ctx, q, folderID, it.file, it.metadata, albums); err != nil {
+				return err
+			}
+			for _, w := range it.metadata.Warnings {
+				if err := s.recordFileError(ctx, q, runID, it.file.rel, w); err != nil {
+					return err
+				}
+			}
+			if it.knownID != 0 {
+				result.Updated++
+				progress.updated()
+			} else {
+				result.Added++
+				progress.added()
+			}
+		}
+
+		pending++
+		if pending >= s.batchSize() {
+			if err := commit(); err != nil {
+				return err
+			}
 		}
+	}
+	return commit()
+}
+
+// recordFileError stores a per-file problem against a scan run, or only logs it when runID is 0.
+func (s *Scanner) recordFileError(ctx context.Context, q *db.Queries, runID int64, rel, msg string) error {
+	log.Printf("warn: %s: %s", rel, msg)
+	if runID == 0 {
+		return nil
+	}
+	return q.CreateScanRunError(ctx, db.CreateScanRunErrorParams{
+		ScanRunID: runID,
+		RelPath:   rel,
+		Error:     msg,
+	})
+}
+
+// writeFile upserts one audio file from already-read metadata and refreshes its cover images.
+// The ids of the albums whose rollups the write affects are added to albums; the caller
+// refreshes them once per batch with refreshAlbums.
+func (s *Scanner) writeFile(ctx context.Context, q *db.Queries, folderID int64, f audioFile, metadata Metadata, albums map[int64]bool) error {
+	baseTitle := strings.TrimSuffix(f.name, f.ext)
 
-		var genre dbtypes.NullString
-		var year dbtypes.NullInt64
-		title := baseTitle
-		if g := strings.TrimSpace(metadata.Genre); g != "" {
-			genre = dbtypes.NullString{String: g, Valid: true}
+	utp := db.UpsertTrackParams{
+		FolderID:     folderID,
+		RelPath:      f.rel,
+		Title:        baseTitle,
+		Filename:     f.name,
+		Ext:          strings.TrimPrefix(f.ext, "."),
+		SizeBytes:    f.sizeBytes,
+		LastModified: f.lastModified,
+	}
+	track, err := q.UpsertTrack(ctx, utp)
+	if err != nil {
+		return err
+	}
+
+	normalizeMultipart(f, &metadata)
+	pinned := track.AlbumPinned == 1
+	if pinned {
+		// Merged or split by hand; keep the album and disc chosen through the API.
+		metadata.Album = ""
+		if metadata.Disc == 0 && track.DiscNumber.Valid {
+			metadata.Disc = int(track.DiscNumber.Int64)
+		}
+	}
+	artistID, albumID, albumRow, err := s.upsertArtistAlbum(ctx, q, metadata)
+	if err != nil {
+		return err
+	}
+	if pinned {
+		albumID = track.AlbumID
+	}
+
+	var genre dbtypes.NullString
+	var year dbtypes.NullInt64
+	title := baseTitle
+	if g := strings.TrimSpace(metadata.Genre); g != "" {
+		genre = dbtypes.NullString{String: g, Valid: true}
+	}
+	if metadata.Year > 0 {
+		year = dbtypes.NullInt64{Int64: int64(metadata.Year), Valid: true}
+	}
+	if t := strings.TrimSpace(metadata.Title); t != "" {
+		title = t
+	}
+	var durationSeconds dbtypes.NullInt64
+	if metadata.DurationSeconds != nil {
+		durationSeconds = dbtypes.NullInt64{Int64: *metadata.DurationSeconds, Valid: true}
+	}
+	var compilation int64
+	if metadata.Compilation {
+		compilation = 1
+	}
+
+	_, err = q.UpdateTrackMetadata(ctx, db.UpdateTrackMetadataParams{
+		ArtistID:        artistID,
+		AlbumID:         albumID,
+		Title:           title,
+		Genre:           genre,
+		Year:            year,
+		ImagePath:       dbtypes.NullString{},
+		DurationSeconds: durationSeconds,
+		TrackNumber:     positiveInt(metadata.Track),
+		TrackTotal:      positiveInt(metadata.TrackTotal),
+		DiscNumber:      positiveInt(metadata.Disc),
+		DiscTotal:       positiveInt(metadata.DiscTotal),
+		AlbumArtist:     trimmedString(metadata.AlbumArtist),
+		Composer:        trimmedString(metadata.Composer),
+		Comment:         trimmedString(metadata.Comment),
+		Lyrics:          trimmedString(metadata.Lyrics),
+		Bpm:             positiveInt(metadata.BPM),
+		Compilation:     compilation,
+		ReleaseDate:     trimmedString(metadata.ReleaseDate),
+		Rating:          positiveInt(metadata.Rating), // NULL keeps the stored rating
+		ID:              track.ID,
+	})
+	if err != nil {
+		return err
+	}
+	if a := metadata.Audio; a != nil {
+		// A failed probe leaves Audio nil and keeps the values from the last good one.
+		params := db.UpdateTrackAudioParams{
+			DurationMs: positiveInt(int(a.DurationMs)),
+			SampleRate: positiveInt(int(a.SampleRate)),
+			BitDepth:   positiveInt(int(a.BitDepth)),
+			Channels:   positiveInt(int(a.Channels)),
+			Codec:      trimmedString(a.Codec),
+			Lossless:   dbtypes.NullInt64{Valid: a.Codec != ""},
+			Bitrate:    positiveInt(int(a.Bitrate)),
+			ID:         track.ID,
 		}
-		if metadata.Year > 0 {
-			year = dbtypes.NullInt64{Int64: int64(metadata.Year), Valid: true}
+		if a.Lossless {
+			params.Lossless.Int64 = 1
 		}
-		if t := strings.TrimSpace(metadata.Title); t != "" {
-			title = t
+		if g := a.Gapless; g != nil {
+			params.EncoderDelay = dbtypes.NullInt64{Int64: g.Delay, Valid: true}
+			params.EncoderPadding = dbtypes.NullInt64{Int64: g.Padding, Valid: true}
+			params.SampleCount = positiveInt(int(g.Samples))
 		}
-		var durationSeconds dbtypes.NullInt64
-		if metadata.DurationSeconds != nil {
-			durationSeconds = dbtypes.NullInt64{Int64: *metadata.DurationSeconds, Valid: true}
+		if err := q.UpdateTrackAudio(ctx, params); err != nil {
+			return err
+		}
+	}
+	// Retagging can move a track between albums; both need their rollups recomputed.
+	for _, id := range []dbtypes.NullInt64{track.AlbumID, albumID} {
+		if id.Valid {
+			albums[id.Int64] = true
 		}
+	}
 
-		_, err = s.Q.UpdateTrackMetadata(ctx, db.UpdateTrackMetadataParams{
-			ArtistID:        artistID,
-			AlbumID:         albumID,
-			Title:           title,
-			Genre:           genre,
-			Year:            year,
-			ImagePath:       dbtypes.NullString{},
-			DurationSeconds: durationSeconds,
-			ID:              track.ID,
-		})
+	if metadata.Picture != nil && albumID.Valid {
+		savedPath, err := s.saveTrackImage(metadata.Picture, albumID.Int64, track.ID)
 		if err != nil {
-			return err
+			log.Printf("warn: failed to save image for track %d: %v", track.ID, err)
+		} else {
+			_, err := q.UpdateTrackImagePath(ctx, db.UpdateTrackImagePathParams{
+				ImagePath: dbtypes.NullString{String: savedPath, Valid: true},
+				ID:        track.ID,
+			})
+			if err != nil {
+				log.Printf("warn: failed to set track image path %d: %v", track.ID, err)
+			}
 		}
+	}
 
-		if metadata.Picture != nil && albumID.Valid {
-			savedPath, err := s.saveTrackImage(metadata.Picture, albumID.Int64, track.ID)
+	if albumRow != nil && !albumRow.ImagePath.Valid {
+		dirPath := filepath.Dir(f.path)
+		candidate, err := s.findAlbumImageFile(dirPath)
+		if err != nil {
+			log.Printf("warn: failed to search album image in %s: %v", dirPath, err)
+		} else if candidate != "" {
+			saved, err := s.saveAlbumImage(candidate, albumRow.ID)
 			if err != nil {
-				log.Printf("warn: failed to save image for track %d: %v", track.ID, err)
+				log.Printf("warn: failed to save album image %s: %v", candidate, err)
 			} else {
-				_, err := s.Q.UpdateTrackImagePath(ctx, db.UpdateTrackImagePathParams{
-					ImagePath: dbtypes.NullString{String: savedPath, Valid: true},
-					ID:        track.ID,
+				_, err = q.UpdateAlbumImagePath(ctx, db.UpdateAlbumImagePathParams{
+					ImagePath: dbtypes.NullString{String: saved, Valid: true},
+					ID:        albumRow.ID,
 				})
 				if err != nil {
-					log.Printf("warn: failed to set track image path %d: %v", track.ID, err)
+					log.Printf("warn: failed to set album image path %d: %v", albumRow.ID, err)
 				}
 			}
 		}
+	}
 
-		if albumRow != nil && !albumRow.ImagePath.Valid {
-			dirPath := filepath.Dir(path)
-			candidate, err := s.findAlbumImageFile(dirPath)
-			if err != nil {
-				log.Printf("warn: failed to search album image in %s: %v", dirPath, err)
-			} else if candidate != "" {
-				saved, err := s.saveAlbumImage(candidate, albumRow.ID)
-				if err != nil {
-					log.Printf("warn: failed to save album image %s: %v", candidate, err)
-				} else {
-					_, err = s.Q.UpdateAlbumImagePath(ctx, db.UpdateAlbumImagePathParams{
-						ImagePath: dbtypes.NullString{String: saved, Valid: true},
-						ID:        albumRow.ID,
-					})
-					if err != nil {
-						log.Printf("warn: failed to set album image path %d: %v", albumRow.ID, err)
-					}
-				}
-			}
+	return nil
+}
+
+// refreshAlbums recomputes the rollups of each album in ids.
+func refreshAlbums(ctx context.Context, q *db.Queries, ids map[int64]bool) error {
+	for id := range ids {
+		if err := q.RefreshAlbumRollups(ctx, sql.NullInt64{Int64: id, Valid: true}); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		return err
-	})
+// addAlbums adds the valid album ids in ids to albums.
+func addAlbums(albums map[int64]bool, ids []dbtypes.NullInt64) {
+	for _, id := range ids {
+		if id.Valid {
+			albums[id.Int64] = true
+		}
+	}
+}
+
+func (s *Scanner) knownFiles(ctx context.Context, folderID int64) (map[string]fileStat, error) {
+	rows, err := s.Q.ListTrackFileStatsForFolder(ctx, folderID)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]fileStat, len(rows))
+	for _, row := range rows {
+		out[row.RelPath] = fileStat{
+			id:           row.ID,
+			sizeBytes:    row.SizeBytes,
+			lastModified: row.LastModified,
+		}
+	}
+	return out, nil
+}
+
+// positiveInt maps unset (zero) tag numbers to NULL.
+func positiveInt(n int) dbtypes.NullInt64 {
+	if n <= 0 {
+		return dbtypes.NullInt64{}
+	}
+	return dbtypes.NullInt64{Int64: int64(n), Valid: true}
+}
+
+func trimmedString(v string) dbtypes.NullString {
+	v = strings.TrimSpace(v)
+	return dbtypes.NullString{String: v, Valid: v != ""}
 }
 
-func (s *Scanner) upsertArtistAlbum(ctx context.Context, artistName, albumTitle string) (dbtypes.NullInt64, dbtypes.NullInt64, *db.Album, error) {
+// variousArtists is the album artist used for compilations that carry no album artist tag.
+const variousArtists = "Various Artists"
+
+// upsertArtistAlbum links the performing artist and the album. Albums are keyed by
+// album artist, falling back to "Various Artists" for compilations and to the track
+// artist otherwise, so a compilation stays one album across its performers.
+func (s *Scanner) upsertArtistAlbum(ctx context.Context, q *db.Queries, metadata Metadata) (dbtypes.NullInt64, dbtypes.NullInt64, *db.Album, error) {
 	var artistID dbtypes.NullInt64
 	var albumID dbtypes.NullInt64
 	var albumRow *db.Album
 
-	if name := strings.TrimSpace(artistName); name != "" {
-		artist, err := s.Q.UpsertArtist(ctx, name)
+	artistName := strings.TrimSpace(metadata.Artist)
+	if artistName != "" {
+		artist, err := q.UpsertArtist(ctx, artistName)
 		if err != nil {
 			return artistID, albumID, nil, err
 		}
 		artistID = dbtypes.NullInt64{Int64: artist.ID, Valid: true}
 	}
 
-	if title := strings.TrimSpace(albumTitle); title != "" && artistID.Valid {
-		album, err := s.Q.UpsertAlbum(ctx, db.UpsertAlbumParams{
-			ArtistID: artistID.Int64,
-			Title:    title,
-		})
+	title := strings.TrimSpace(metadata.Album)
+	if title == "" {
+		return artistID, albumID, nil, nil
+	}
+
+	albumArtistID := artistID
+	albumArtist := strings.TrimSpace(metadata.AlbumArtist)
+	if albumArtist == "" && metadata.Compilation {
+		albumArtist = variousArtists
+	}
+	if albumArtist != "" && albumArtist != artistName {
+		artist, err := q.UpsertArtist(ctx, albumArtist)
 		if err != nil {
 			return artistID, albumID, nil, err
 		}
-		albumID = dbtypes.NullInt64{Int64: album.ID, Valid: true}
-		albumRow = &album
+		albumArtistID = dbtypes.NullInt64{Int64: artist.ID, Valid: true}
+	}
+	if !albumArtistID.Valid {
+		return artistID, albumID, nil, nil
+	}
+
+	album, err := q.UpsertAlbum(ctx, db.UpsertAlbumParams{
+		ArtistID: albumArtistID.Int64,
+		Title:    title,
+	})
+	if err != nil {
+		return artistID, albumID, nil, err
 	}
+	albumID = dbtypes.NullInt64{Int64: album.ID, Valid: true}
+	albumRow = &album
 
 	return artistID, albumID, albumRow, nil
 }
@@ -0,0 +1,344 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"bottomley.ian/musicserver/internal/db"
+	myfs "bottomley.ian/musicserver/internal/services/fs"
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// ErrTrackUnavailable is returned when a track's folder is unavailable, so its file cannot be written.
+var ErrTrackUnavailable = errors.New("track file unavailable")
+
+// tagWritePrefix names the temporary sibling a file is rewritten into. Scans skip it.
+const tagWritePrefix = ".tagwrite-"
+
+// vorbisCommentExt lists the formats whose ratings are stored as Vorbis comments.
+var vorbisCommentExt = map[string]bool{
+	"flac": true,
+	"ogg":  true,
+}
+
+// HasRatingTag reports whether files with extension ext store a rating that
+// WriteTrackTags can write: POPM for MP3, Vorbis comments for FLAC and OGG.
+func HasRatingTag(ext string) bool {
+	return ext == "mp3" || vorbisCommentExt[ext]
+}
+
+// TagEdit is a set of tag changes to write into a file. Nil fields are left as they
+// are; an empty string or 0 removes the tag.
+type TagEdit struct {
+	Title       *string
+	Artist      *string
+	AlbumArtist *string
+	Album       *string
+	Genre       *string
+	Year        *int
+	Track       *int
+	Rating      *int // 1-5 stars
+	// Unpin releases a track merged or split by hand once its file is written, so the
+	// re-read files it under the new album tags instead of keeping the pinned album.
+	Unpin bool
+}
+
+// ffmpegMetadata maps the edit to ffmpeg's generic metadata keys, which the muxers
+// translate to ID3 frames, Vorbis comments or MP4 atoms. MP3 ratings are not included:
+// ffmpeg cannot write POPM frames, so those go through setPOPM.
+func (e TagEdit) ffmpegMetadata(ext string, trackTotal int64) []string {
+	var out []string
+	add := func(key string, v *string) {
+		if v != nil {
+			out = append(out, key+"="+strings.TrimSpace(*v))
+		}
+	}
+	add("title", e.Title)
+	add("artist", e.Artist)
+	add("album_artist", e.AlbumArtist)
+	add("album", e.Album)
+	add("genre", e.Genre)
+	if e.Year != nil {
+		out = append(out, "date="+positiveOrEmpty(*e.Year))
+	}
+	if e.Track != nil {
+		track := positiveOrEmpty(*e.Track)
+		if track != "" && trackTotal > 0 {
+			track += "/" + strconv.FormatInt(trackTotal, 10)
+		}
+		out = append(out, "track="+track)
+	}
+	if e.Rating != nil && vorbisCommentExt[ext] {
+		// RATING is cleared so an older value from another tagger cannot disagree.
+		fmps := ""
+		if *e.Rating > 0 {
+			fmps = strconv.FormatFloat(float64(*e.Rating)/5, 'f', 1, 64)
+		}
+		out = append(out, "FMPS_RATING="+fmps, "RATING=")
+	}
+	return out
+}
+
+func positiveOrEmpty(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// WriteTrackTags writes each edit into its track's file with an ffmpeg stream copy,
+// then re-reads the file and refreshes the track row from it, exactly as a scan would.
+// It is PrepareTrackTags followed by Commit.
+func (s *Scanner) WriteTrackTags(ctx context.Context, edits map[int64]TagEdit) error {
+	w, err := s.PrepareTrackTags(ctx, edits)
+	if err != nil {
+		return err
+	}
+	return w.Commit(ctx)
+}
+
+// TagWrite is a batch of tag edits whose rewritten files wait next to the originals.
+type TagWrite struct {
+	s      *Scanner
+	staged []stagedTags
+}
+
+// stagedTags is one track of a TagWrite.
+type stagedTags struct {
+	track   db.Track
+	path    string
+	relPath string
+	tmp     string // rewritten copy; empty when the edit changes nothing in the file
+	unpin   bool
+}
+
+// PrepareTrackTags rewrites the file of every edited track into a temporary sibling,
+// in track id order, without touching the originals or the database. When any file
+// fails, the copies already made are removed and nothing has changed. The batch
+// needs free space for a copy of every file until Commit or Discard.
+func (s *Scanner) PrepareTrackTags(ctx context.Context, edits map[int64]TagEdit) (*TagWrite, error) {
+	ids := make([]int64, 0, len(edits))
+	for id := range edits {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	w := &TagWrite{s: s}
+	for _, id := range ids {
+		st, err := s.stageTrackTags(ctx, id, edits[id])
+		if err != nil {
+			w.Discard()
+			return nil, fmt.Errorf("track %d: %w", id, err)
+		}
+		w.staged = append(w.staged, st)
+	}
+	return w, nil
+}
+
+// Discard removes the rewritten copies, leaving every file as it was.
+func (w *TagWrite) Discard() {
+	for _, st := range w.staged {
+		if st.tmp != "" {
+			_ = w.s.FS.Remove(st.tmp)
+		}
+	}
+	w.staged = nil
+}
+
+// Commit renames each rewritten copy over its original, then re-reads the file and
+// refreshes the track row. Only a failed rename or database write can stop it part way;
+// the files not yet renamed are then discarded and keep their old tags.
+func (w *TagWrite) Commit(ctx context.Context) error {
+	s := w.s
+	albums := map[int64]bool{}
+	artists := map[int64]bool{}
+	for _, st := range w.staged {
+		if st.track.ArtistID.Valid {
+			artists[st.track.ArtistID.Int64] = true
+		}
+	}
+	for i, st := range w.staged {
+		if err := s.commitTrackTags(ctx, st, albums); err != nil {
+			w.staged = w.staged[i+1:]
+			w.Discard()
+			if err := refreshAlbums(ctx, s.Q, albums); err != nil {
+				log.Printf("warn: failed to refresh albums after tag write: %v", err)
+			}
+			return fmt.Errorf("track %d: %w", st.track.ID, err)
+		}
+	}
+	w.staged = nil
+	if err := refreshAlbums(ctx, s.Q, albums); err != nil {
+		log.Printf("warn: failed to refresh albums after tag write: %v", err)
+	}
+	if err := s.pruneEdited(ctx, albums, artists); err != nil {
+		log.Printf("warn: failed to prune artists/albums after tag write: %v", err)
+	}
+	return nil
+}
+
+// pruneEdited soft-deletes the given albums and artists, and the artists of albums it
+// deletes, once they have no live tracks left. Renames can leave the previous album or
+// artist empty, and only those rows need checking.
+func (s *Scanner) pruneEdited(ctx context.Context, albums, artists map[int64]bool) error {
+	for id := range albums {
+		albumArtists, err := s.Q.SoftDeleteOrphanedAlbumByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		for _, artistID := range albumArtists {
+			artists[artistID] = true
+		}
+	}
+	for id := range artists {
+		if _, err := s.Q.SoftDeleteOrphanedArtistByID(ctx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Scanner) stageTrackTags(ctx context.Context, trackID int64, edit TagEdit) (stagedTags, error) {
+	track, err := s.Q.GetTrackByID(ctx, trackID)
+	if err != nil {
+		return stagedTags{}, err
+	}
+	parts, err := s.Q.GetPlayableTrackPathPartsByID(ctx, trackID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return stagedTags{}, ErrTrackUnavailable
+	}
+	if err != nil {
+		return stagedTags{}, err
+	}
+	root, err := myfs.ExpandPath(parts.FolderPath)
+	if err != nil {
+		return stagedTags{}, err
+	}
+	path := filepath.Join(root, filepath.FromSlash(parts.RelPath))
+	st := stagedTags{
+		track:   track,
+		path:    path,
+		relPath: parts.RelPath,
+		unpin:   edit.Unpin && track.AlbumPinned == 1,
+	}
+	info, err := s.FS.Stat(path)
+	if err != nil {
+		return stagedTags{}, err
+	}
+	perm := info.Mode().Perm()
+
+	metadata := edit.ffmpegMetadata(track.Ext, track.TrackTotal.Int64)
+	if len(metadata) > 0 {
+		if st.tmp, err = s.writeTags(ctx, path, metadata); err != nil {
+			return stagedTags{}, err
+		}
+	}
+	if track.Ext == "mp3" && (edit.Rating != nil || len(metadata) > 0) {
+		// The ffmpeg remux drops POPM frames, so the stored rating is written back as well.
+		rating := int(track.Rating.Int64)
+		if edit.Rating != nil {
+			rating = *edit.Rating
+		}
+		src := path
+		if st.tmp != "" {
+			src = st.tmp
+		}
+		if st.tmp, err = s.writeRating(src, path, rating); err != nil {
+			_ = s.FS.Remove(tagWritePath(path))
+			return stagedTags{}, err
+		}
+	}
+	if st.tmp != "" {
+		if err := s.FS.Chmod(st.tmp, perm); err != nil {
+			_ = s.FS.Remove(st.tmp)
+			return stagedTags{}, err
+		}
+	}
+	return st, nil
+}
+
+func (s *Scanner) commitTrackTags(ctx context.Context, st stagedTags, albums map[int64]bool) error {
+	if st.tmp != "" {
+		if err := s.FS.Rename(st.tmp, st.path); err != nil {
+			_ = s.FS.Remove(st.tmp)
+			return err
+		}
+	}
+	if st.unpin {
+		if err := s.Q.UnpinTrackAlbum(ctx, st.track.ID); err != nil {
+			return err
+		}
+	}
+
+	info, err := s.FS.Stat(st.path)
+	if err != nil {
+		return err
+	}
+	read, err := s.ReadMetadata(st.path)
+	if err != nil {
+		return err
+	}
+	f := audioFile{
+		path:         st.path,
+		rel:          st.relPath,
+		name:         info.Name(),
+		ext:          "." + st.track.Ext,
+		sizeBytes:    info.Size(),
+		lastModified: info.ModTime().Unix(),
+	}
+	return s.writeFile(ctx, s.Q, st.track.FolderID, f, read, albums)
+}
+
+// tagWritePath names the temporary sibling path is rewritten into, keeping the
+// extension so ffmpeg picks the same muxer.
+func tagWritePath(path string) string {
+	return filepath.Join(filepath.Dir(path), tagWritePrefix+filepath.Base(path))
+}
+
+// writeTags remuxes path into its temporary sibling with the new metadata and returns
+// the sibling's path. A failed ffmpeg run removes the partial copy.
+func (s *Scanner) writeTags(ctx context.Context, path string, metadata []string) (string, error) {
+	tmp := tagWritePath(path)
+
+	var stderr bytes.Buffer
+	stream := ffmpeg.Input(path).Output(tmp, ffmpeg.KwArgs{
+		"map":          "0",
+		"c":            "copy",
+		"map_metadata": "0",
+		"metadata":     metadata,
+		"loglevel":     "error",
+	})
+	stream.Context = ctx
+	err := stream.OverWriteOutput().WithErrorOutput(&stderr).Run()
+	if err != nil {
+		_ = s.FS.Remove(tmp)
+		return "", fmt.Errorf("ffmpeg: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return tmp, nil
+}
+
+// writeRating sets the POPM rating (0 removes it) of the MP3 at src, which is either
+// path or its temporary sibling, and writes the result to the sibling.
+func (s *Scanner) writeRating(src, path string, rating int) (string, error) {
+	data, err := s.FS.ReadFile(src)
+	if err != nil {
+		return "", err
+	}
+	out, err := setPOPM(data, rating)
+	if err != nil {
+		return "", err
+	}
+	tmp := tagWritePath(path)
+	if err := s.FS.WriteFile(tmp, out, 0o600); err != nil {
+		return "", err
+	}
+	return tmp, nil
+}
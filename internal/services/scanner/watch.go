@@ -0,0 +1,279 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"bottomley.ian/musicserver/internal/db"
+	myfs "bottomley.ian/musicserver/internal/services/fs"
+)
+
+const (
+	// watchQuiet is how long a folder must be quiet before pending changes are indexed.
+	watchQuiet = 2 * time.Second
+	// watchMaxDelay caps how long a steady stream of events can postpone indexing.
+	watchMaxDelay = 30 * time.Second
+)
+
+type folderWatch struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// WatchFolders starts a watcher for every folder with watch enabled. Failures are
+// logged per folder so one missing root does not stop the others.
+func (s *Scanner) WatchFolders(ctx context.Context) error {
+	folders, err := s.Q.ListWatchedFolders(ctx)
+	if err != nil {
+		return err
+	}
+	for _, f := range folders {
+		if err := s.StartWatching(f.ID); err != nil {
+			log.Printf("warn: failed to watch folder %d (%s): %v", f.ID, f.Path, err)
+		}
+	}
+	return nil
+}
+
+// StartWatching indexes created, modified, renamed and deleted audio files under a
+// folder root as they change. It is a no-op if the folder is already watched.
+func (s *Scanner) StartWatching(folderID int64) error {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+
+	if _, ok := s.watches[folderID]; ok {
+		return nil
+	}
+
+	folder, err := s.Q.GetFolderByID(context.Background(), folderID)
+	if err != nil {
+		return err
+	}
+	root, err := myfs.ExpandPath(folder.Path)
+	if err != nil {
+		return err
+	}
+	w, err := s.FS.Watch(root)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	fw := &folderWatch{cancel: cancel, done: make(chan struct{})}
+	if s.watches == nil {
+		s.watches = make(map[int64]*folderWatch)
+	}
+	s.watches[folderID] = fw
+
+	go func() {
+		defer close(fw.done)
+		s.runWatch(ctx, folderID, root, w)
+	}()
+	log.Printf("watching folder %d (%s)", folderID, root)
+	return nil
+}
+
+// StopWatching stops the watcher for a folder, waiting for any in-flight indexing to finish.
+func (s *Scanner) StopWatching(folderID int64) {
+	s.watchMu.Lock()
+	fw, ok := s.watches[folderID]
+	delete(s.watches, folderID)
+	s.watchMu.Unlock()
+
+	if !ok {
+		return
+	}
+	fw.cancel()
+	<-fw.done
+}
+
+// runWatch collects event paths and indexes them once the folder has been quiet for
+// watchQuiet, or watchMaxDelay after the first pending event, whichever comes first.
+func (s *Scanner) runWatch(ctx context.Context, folderID int64, root string, w myfs.Watcher) {
+	defer w.Close()
+
+	pending := make(map[string]struct{})
+	var firstPending time.Time
+	timer := time.NewTimer(watchQuiet)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-w.Events():
+			if !ok {
+				return
+			}
+			if len(pending) == 0 {
+				firstPending = time.Now()
+			}
+			pending[ev.Path] = struct{}{}
+			delay := watchQuiet
+			if left := watchMaxDelay - time.Since(firstPending); left < delay {
+				delay = max(left, 0)
+			}
+			timer.Reset(delay)
+		case err, ok := <-w.Errors():
+			if !ok {
+				return
+			}
+			log.Printf("warn: watcher error for folder %d: %v", folderID, err)
+		case <-timer.C:
+			if len(pending) == 0 {
+				continue
+			}
+			// Let a running full scan finish first; it may already cover these paths.
+			if s.scanRunning(folderID) {
+				timer.Reset(watchQuiet)
+				continue
+			}
+			paths := make([]string, 0, len(pending))
+			for p := range pending {
+				paths = append(paths, p)
+			}
+			clear(pending)
+			// An ejected drive reports every path as removed. Drop the flush rather than
+			// soft-delete the library; the monitor marks the folder unavailable and runs a
+			// catch-up scan when it returns.
+			if !s.rootAvailable(root) {
+				log.Printf("warn: folder %d root %s is gone; dropping %d watch events", folderID, root, len(paths))
+				continue
+			}
+			s.indexChanged(ctx, folderID, root, paths)
+		}
+	}
+}
+
+func (s *Scanner) scanRunning(folderID int64) bool {
+	job, ok := s.LatestJob(folderID)
+	return ok && job.State().Status == JobStatusRunning
+}
+
+// indexChanged runs the per-file scan logic for paths reported by a watcher. Paths that
+// no longer exist soft-delete the matching track, or every track below a removed directory.
+// Each flush that touches the index is recorded as a scan run triggered by "watch".
+func (s *Scanner) indexChanged(ctx context.Context, folderID int64, root string, paths []string) {
+	var result ScanResult
+	var runID int64
+	albums := map[int64]bool{}
+	startRun := func() bool {
+		if runID != 0 {
+			return true
+		}
+		run, err := s.Q.CreateScanRun(ctx, db.CreateScanRunParams{
+			FolderID:    folderID,
+			TriggeredBy: TriggerWatch,
+		})
+		if err != nil {
+			log.Printf("warn: failed to record watch update for folder %d: %v", folderID, err)
+			return false
+		}
+		runID = run.ID
+		return true
+	}
+
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+
+		info, err := s.FS.Stat(path)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				log.Printf("warn: failed to stat %s: %v", path, err)
+				continue
+			}
+			removed, err := s.Q.SoftDeleteTracksUnderPath(ctx, db.SoftDeleteTracksUnderPathParams{
+				FolderID: folderID,
+				RelPath:  rel,
+			})
+			if err != nil {
+				log.Printf("warn: failed to remove tracks under %s: %v", path, err)
+				continue
+			}
+			addAlbums(albums, removed)
+			if len(removed) > 0 && startRun() {
+				result.Removed += int64(len(removed))
+			}
+			continue
+		}
+
+		// New directories are expanded by the watcher, which reports their files individually.
+		if info.IsDir() {
+			continue
+		}
+		ext, ok := isMusicName(info.Name())
+		if !ok || !startRun() {
+			continue
+		}
+		result.Seen++
+
+		_, lookupErr := s.Q.GetTrackIDByRelPath(ctx, db.GetTrackIDByRelPathParams{
+			FolderID: folderID,
+			RelPath:  rel,
+		})
+		f := audioFile{
+			path:         path,
+			rel:          rel,
+			name:         info.Name(),
+			ext:          ext,
+			sizeBytes:    info.Size(),
+			lastModified: info.ModTime().Unix(),
+		}
+		metadata, err := s.ReadMetadata(f.path)
+		if err != nil {
+			result.Failed++
+			if err := s.recordFileError(ctx, s.Q, runID, rel, err.Error()); err != nil {
+				log.Printf("warn: failed to record error for %s: %v", path, err)
+			}
+			continue
+		}
+		if err := s.writeFile(ctx, s.Q, folderID, f, metadata, albums); err != nil {
+			log.Printf("warn: failed to index %s: %v", path, err)
+			result.Failed++
+			continue
+		}
+		for _, w := range metadata.Warnings {
+			if err := s.recordFileError(ctx, s.Q, runID, rel, w); err != nil {
+				log.Printf("warn: failed to record error for %s: %v", path, err)
+			}
+		}
+		if lookupErr == nil {
+			result.Updated++
+		} else {
+			result.Added++
+		}
+	}
+
+	if runID == 0 {
+		return
+	}
+	if result.Removed > 0 {
+		if err := s.pruneOrphans(ctx, albums); err != nil {
+			log.Printf("warn: failed to prune artists/albums after watch update: %v", err)
+		}
+	} else if err := refreshAlbums(ctx, s.Q, albums); err != nil {
+		log.Printf("warn: failed to refresh albums after watch update: %v", err)
+	}
+
+	status := JobStatusOK
+	if ctx.Err() != nil {
+		status = JobStatusInterrupted
+	}
+	// The watch context may already be canceled; the run row should still be closed.
+	s.finishRun(context.Background(), runID, status, "", result)
+	log.Printf("watch update for folder %d: %d added, %d updated, %d removed, %d failed",
+		folderID, result.Added, result.Updated, result.Removed, result.Failed)
+}
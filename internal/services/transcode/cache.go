@@ -0,0 +1,561 @@
+package transcode
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// partSuffix marks a cache file, or a directory of files for OpenSet, still being
+// written. Leftovers are removed on startup.
+const partSuffix = ".part"
+
+// setSuffix marks the directory holding a finished OpenSet set, so a restart loads it
+// back as one entry.
+const setSuffix = ".set"
+
+// Cache is a directory of generated files. Once their total size passes MaxBytes the
+// least recently used are deleted. Keys are slash-separated paths below Dir. A set of
+// files from OpenSet is one entry, used and evicted as a whole.
+type Cache struct {
+	Dir      string
+	MaxBytes int64
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element // value: *cacheEntry
+	lru      *list.List               // most recently used at the front
+	size     int64
+	files    int
+	inflight map[string]chan struct{}
+	sets     map[string]*setFill // the OpenSet fills in inflight
+
+	hits, misses, evictions int64
+}
+
+// CacheStats is a snapshot of a cache's contents and counters since startup.
+type CacheStats struct {
+	Dir       string
+	MaxBytes  int64
+	Bytes     int64
+	Files     int
+	Hits      int64
+	Misses    int64
+	Evictions int64
+}
+
+type cacheEntry struct {
+	key   string
+	size  int64
+	files int
+	set   bool
+}
+
+// NewCache opens the cache in dir, creating it if needed. Files already there are kept,
+// ordered by modification time, which Open bumps on every hit.
+func NewCache(dir string, maxBytes int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	c := &Cache{
+		Dir:      dir,
+		MaxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+		inflight: make(map[string]chan struct{}),
+		sets:     make(map[string]*setFill),
+	}
+
+	type found struct {
+		entry   cacheEntry
+		modTime time.Time
+	}
+	var entries []found
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && path == dir {
+			return nil
+		}
+		if strings.HasSuffix(path, partSuffix) {
+			if err := os.RemoveAll(path); err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() && !strings.HasSuffix(path, setSuffix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		e := cacheEntry{key: filepath.ToSlash(rel), size: info.Size(), files: 1}
+		if d.IsDir() {
+			e = cacheEntry{key: strings.TrimSuffix(e.key, setSuffix), set: true}
+			e.size, e.files, err = dirSize(path)
+			if err != nil {
+				return err
+			}
+		}
+		entries = append(entries, found{entry: e, modTime: info.ModTime()})
+		if d.IsDir() {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(entries, func(a, b found) int { return a.modTime.Compare(b.modTime) })
+	for _, f := range entries {
+		c.add(f.entry)
+	}
+	c.evict()
+	return c, nil
+}
+
+// Open returns key's file, creating it with fill on a miss. fill writes the file at
+// the path it is given, which is renamed into place once fill succeeds. Concurrent
+// misses on one key share a single fill.
+func (c *Cache) Open(ctx context.Context, key string, fill func(ctx context.Context, path string) error) (*os.File, error) {
+	for {
+		c.mu.Lock()
+		if f, ok := c.openLocked(key); ok {
+			c.hits++
+			c.mu.Unlock()
+			return f, nil
+		}
+		if wait, ok := c.inflight[key]; ok {
+			c.mu.Unlock()
+			select {
+			case <-wait:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		done := make(chan struct{})
+		c.inflight[key] = done
+		c.misses++
+		c.mu.Unlock()
+
+		size, err := c.fill(ctx, key, fill)
+
+		c.mu.Lock()
+		delete(c.inflight, key)
+		close(done)
+		if err != nil {
+			c.removeEmptyDirs(c.path(key))
+			c.mu.Unlock()
+			return nil, err
+		}
+		c.add(cacheEntry{key: key, size: size, files: 1})
+		f, ok := c.openLocked(key)
+		// The new file stays even if it alone is over budget, so the caller can serve it.
+		c.evict()
+		c.mu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("cache file %s: %w", key, os.ErrNotExist)
+		}
+		return f, nil
+	}
+}
+
+// OpenSet is Open for files made together, such as the segments of one encode. It
+// returns the file name from the set cached under prefix. On a miss, fill writes the
+// whole set into the directory it is given, which becomes the set once fill succeeds.
+// fill calls ready with each file name it has finished, and that file is served
+// straight away. The fill belongs to the cache rather than the caller: it carries on
+// when ctx is cancelled, and concurrent misses on one prefix share it. A set without
+// name reports os.ErrNotExist.
+func (c *Cache) OpenSet(ctx context.Context, prefix, name string, fill func(ctx context.Context, dir string, ready func(name string)) error) (*os.File, error) {
+	var sf *setFill
+	started := false
+	for {
+		c.mu.Lock()
+		if f, ok, err := c.openSetLocked(prefix, name); ok {
+			if err == nil && !started {
+				c.hits++
+			}
+			c.mu.Unlock()
+			return f, err
+		}
+		if sf != nil && sf.done {
+			c.mu.Unlock()
+			if sf.err != nil {
+				return nil, sf.err
+			}
+			// The set was evicted as soon as it was added.
+			return nil, fmt.Errorf("cache set %s: %w", prefix, os.ErrNotExist)
+		}
+		if sf == nil {
+			if sf = c.sets[prefix]; sf == nil {
+				sf = c.startSetLocked(ctx, prefix, fill)
+				started = true
+			}
+		}
+		if sf.ready[name] {
+			if !started {
+				c.hits++
+			}
+			c.mu.Unlock()
+			return os.Open(filepath.Join(sf.dir, name))
+		}
+		changed := sf.changed
+		c.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// setFill is a set being written by an OpenSet fill. Files fill has reported ready
+// can be opened in dir before the set is finished.
+type setFill struct {
+	dir     string
+	ready   map[string]bool
+	changed chan struct{} // closed and replaced when a file is ready or the fill ends
+	done    bool
+	err     error
+}
+
+// startSetLocked starts filling prefix's set in the background, detached from ctx so
+// a cancelled request does not throw away the work. c.mu must be held.
+func (c *Cache) startSetLocked(ctx context.Context, prefix string, fill func(ctx context.Context, dir string, ready func(name string)) error) *setFill {
+	sf := &setFill{
+		dir:     c.path(prefix) + partSuffix,
+		ready:   make(map[string]bool),
+		changed: make(chan struct{}),
+	}
+	c.sets[prefix] = sf
+	done := make(chan struct{})
+	c.inflight[prefix] = done
+	c.misses++
+	go c.fillSet(context.WithoutCancel(ctx), prefix, sf, done, fill)
+	return sf
+}
+
+// fillSet runs fill in sf.dir and renames it to the set directory. A failed fill
+// leaves nothing behind. Waiters are woken as each file is ready and when it ends.
+func (c *Cache) fillSet(ctx context.Context, prefix string, sf *setFill, done chan struct{}, fill func(ctx context.Context, dir string, ready func(name string)) error) {
+	ready := func(name string) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		sf.ready[name] = true
+		close(sf.changed)
+		sf.changed = make(chan struct{})
+	}
+	err := os.RemoveAll(sf.dir)
+	if err == nil {
+		err = os.MkdirAll(sf.dir, 0o755)
+	}
+	if err == nil {
+		err = fill(ctx, sf.dir, ready)
+	}
+	var size int64
+	var files int
+	if err == nil {
+		size, files, err = dirSize(sf.dir)
+	}
+	dir := c.path(prefix) + setSuffix
+	if err == nil {
+		err = os.RemoveAll(dir)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Renaming under c.mu keeps sf.dir valid for anyone opening a ready file.
+	if err == nil {
+		err = os.Rename(sf.dir, dir)
+	}
+	delete(c.sets, prefix)
+	delete(c.inflight, prefix)
+	close(done)
+	sf.done, sf.err = true, err
+	close(sf.changed)
+	if err != nil {
+		_ = os.RemoveAll(sf.dir)
+		c.removeEmptyDirs(sf.dir)
+		return
+	}
+	c.add(cacheEntry{key: prefix, size: size, files: files, set: true})
+	// The new set stays even if it alone is over budget, so waiters can serve it.
+	c.evict()
+}
+
+// Lookup opens key's file if it is cached, without waiting for one being filled.
+func (c *Cache) Lookup(key string) (*os.File, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, ok := c.openLocked(key)
+	if ok {
+		c.hits++
+	}
+	return f, ok
+}
+
+// Contains reports whether key is cached or being filled, without marking it used.
+func (c *Cache) Contains(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, cached := c.entries[key]
+	_, filling := c.inflight[key]
+	return cached || filling
+}
+
+// Create starts key's file for a caller that produces it while sending it elsewhere.
+// It returns false when the key is cached or already being filled. Until Commit, the
+// file is invisible and Open calls for the key wait for it.
+func (c *Cache) Create(key string) (*CacheWriter, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok {
+		return nil, false
+	}
+	if _, ok := c.inflight[key]; ok {
+		// The caller goes without the cache, which still counts as a miss.
+		c.misses++
+		return nil, false
+	}
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, false
+	}
+	f, err := os.Create(path + partSuffix)
+	if err != nil {
+		return nil, false
+	}
+	done := make(chan struct{})
+	c.inflight[key] = done
+	c.misses++
+	return &CacheWriter{c: c, key: key, file: f, done: done}, true
+}
+
+// CacheWriter writes one file into a Cache. Exactly one of Commit or Abort takes
+// effect; calling Abort after Commit does nothing, so it can be deferred.
+type CacheWriter struct {
+	c        *Cache
+	key      string
+	file     *os.File
+	done     chan struct{}
+	finished bool
+}
+
+func (cw *CacheWriter) Write(p []byte) (int, error) {
+	return cw.file.Write(p)
+}
+
+// Commit adds the written file to the cache.
+func (cw *CacheWriter) Commit() error {
+	if cw.finished {
+		return nil
+	}
+	cw.finished = true
+	part := cw.file.Name()
+	err := cw.file.Close()
+	var info os.FileInfo
+	if err == nil {
+		info, err = os.Stat(part)
+	}
+	if err == nil {
+		err = os.Rename(part, cw.c.path(cw.key))
+	}
+
+	c := cw.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inflight, cw.key)
+	close(cw.done)
+	if err != nil {
+		_ = os.Remove(part)
+		return err
+	}
+	c.add(cacheEntry{key: cw.key, size: info.Size(), files: 1})
+	c.evict()
+	return nil
+}
+
+// Abort discards the written file.
+func (cw *CacheWriter) Abort() {
+	if cw.finished {
+		return
+	}
+	cw.finished = true
+	_ = cw.file.Close()
+	_ = os.Remove(cw.file.Name())
+
+	c := cw.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inflight, cw.key)
+	close(cw.done)
+	c.removeEmptyDirs(cw.file.Name())
+}
+
+// Stats returns the cache's current size and counters.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Dir:       c.Dir,
+		MaxBytes:  c.MaxBytes,
+		Bytes:     c.size,
+		Files:     c.files,
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
+
+func (c *Cache) fill(ctx context.Context, key string, fill func(ctx context.Context, path string) error) (int64, error) {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, err
+	}
+	part := path + partSuffix
+	if err := fill(ctx, part); err != nil {
+		_ = os.Remove(part)
+		return 0, err
+	}
+	info, err := os.Stat(part)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Rename(part, path); err != nil {
+		_ = os.Remove(part)
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// dirSize totals the regular files directly inside dir.
+func dirSize(dir string) (size int64, files int, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			return 0, 0, err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+			files++
+		}
+	}
+	return size, files, nil
+}
+
+// openLocked opens a cached file and marks it used. An entry whose file has gone
+// missing is dropped.
+func (c *Cache) openLocked(key string) (*os.File, bool) {
+	elem, ok := c.entries[key]
+	if !ok || elem.Value.(*cacheEntry).set {
+		return nil, false
+	}
+	f, err := os.Open(c.path(key))
+	if err != nil {
+		c.remove(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	now := time.Now()
+	_ = os.Chtimes(c.path(key), now, now)
+	return f, true
+}
+
+// openSetLocked opens name in the set cached under prefix and marks the set used. ok
+// is false when no set is cached; a cached set without name returns its open error.
+// An entry whose directory has gone missing is dropped.
+func (c *Cache) openSetLocked(prefix, name string) (f *os.File, ok bool, err error) {
+	elem, ok := c.entries[prefix]
+	if !ok || !elem.Value.(*cacheEntry).set {
+		return nil, false, nil
+	}
+	dir := c.path(prefix) + setSuffix
+	if _, err := os.Stat(dir); err != nil {
+		c.remove(elem)
+		return nil, false, nil
+	}
+	f, err = os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return nil, true, err
+	}
+	c.lru.MoveToFront(elem)
+	now := time.Now()
+	_ = os.Chtimes(dir, now, now)
+	return f, true, nil
+}
+
+func (c *Cache) add(e cacheEntry) {
+	if elem, ok := c.entries[e.key]; ok {
+		c.remove(elem)
+	}
+	c.entries[e.key] = c.lru.PushFront(&e)
+	c.size += e.size
+	c.files += e.files
+}
+
+// evict deletes least recently used entries until the cache fits MaxBytes, always
+// keeping the most recent one. A set goes as a whole. Files already open stay readable
+// until closed.
+func (c *Cache) evict() {
+	for c.size > c.MaxBytes && c.lru.Len() > 1 {
+		elem := c.lru.Back()
+		e := elem.Value.(*cacheEntry)
+		path := c.path(e.key)
+		if e.set {
+			path += setSuffix
+		}
+		_ = os.RemoveAll(path)
+		c.remove(elem)
+		c.evictions++
+		c.removeEmptyDirs(path)
+	}
+}
+
+// removeEmptyDirs drops the directories above path that are left empty, up to Dir.
+// os.Remove fails harmlessly on the others. Directories a fill may be about to write
+// into are kept. c.mu must be held.
+func (c *Cache) removeEmptyDirs(path string) {
+	for dir := filepath.Dir(path); dir != filepath.Clean(c.Dir); dir = filepath.Dir(dir) {
+		for key := range c.inflight {
+			if strings.HasPrefix(c.path(key), dir+string(filepath.Separator)) {
+				return
+			}
+		}
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
+
+func (c *Cache) remove(elem *list.Element) {
+	e := elem.Value.(*cacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, e.key)
+	c.size -= e.size
+	c.files -= e.files
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.Dir, filepath.FromSlash(key))
+}
@@ -0,0 +1,261 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// ErrBusy is returned when every transcode slot stays taken for QueueTimeout.
+var ErrBusy = errors.New("too many transcodes running")
+
+// Bitrate bounds in kbit/s.
+const (
+	MinBitrate = 32
+	MaxBitrate = 320
+)
+
+// defaultQueueTimeout is how long a request waits for a free slot before ErrBusy.
+const defaultQueueTimeout = 5 * time.Second
+
+// Format is an output encoding ffmpeg can stream to a pipe.
+type Format struct {
+	Name           string
+	ContentType    string
+	Codec          string // ffmpeg audio encoder
+	Muxer          string // ffmpeg output format
+	Ext            string // file extension for cached output
+	DefaultBitrate int    // kbit/s
+}
+
+// Formats lists the supported output encodings by name.
+var Formats = map[string]Format{
+	"mp3": {
+		Name:           "mp3",
+		ContentType:    "audio/mpeg",
+		Codec:          "libmp3lame",
+		Muxer:          "mp3",
+		Ext:            "mp3",
+		DefaultBitrate: 192,
+	},
+	"opus": {
+		Name:           "opus",
+		ContentType:    "audio/ogg; codecs=opus",
+		Codec:          "libopus",
+		Muxer:          "ogg",
+		Ext:            "opus",
+		DefaultBitrate: 128,
+	},
+	"aac": {
+		Name:           "aac",
+		ContentType:    "audio/aac",
+		Codec:          "aac",
+		Muxer:          "adts",
+		Ext:            "aac",
+		DefaultBitrate: 192,
+	},
+}
+
+// HLSFormat is AAC in MPEG-TS, the segment format every HLS player accepts.
+var HLSFormat = Format{
+	Name:           "aac",
+	ContentType:    "video/mp2t",
+	Codec:          "aac",
+	Muxer:          "mpegts",
+	Ext:            "ts",
+	DefaultBitrate: 128,
+}
+
+// FormatNames returns the supported format names, sorted.
+func FormatNames() []string {
+	names := make([]string, 0, len(Formats))
+	for name := range Formats {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Profile is one format at one bitrate, optionally with a volume change.
+type Profile struct {
+	Format  Format
+	Bitrate int     // kbit/s
+	GainDB  float64 // applied with ffmpeg's volume filter when non-zero
+}
+
+// ParseProfile resolves a format name and bitrate. An empty format means mp3 and a
+// zero bitrate means the format's default.
+func ParseProfile(format string, bitrate int) (Profile, error) {
+	if format == "" {
+		format = "mp3"
+	}
+	f, ok := Formats[strings.ToLower(format)]
+	if !ok {
+		return Profile{}, fmt.Errorf("invalid format: %s; allowed: %s", format, strings.Join(FormatNames(), ", "))
+	}
+	if bitrate == 0 {
+		bitrate = f.DefaultBitrate
+	}
+	if bitrate < MinBitrate || bitrate > MaxBitrate {
+		return Profile{}, fmt.Errorf("invalid bitrate: must be between %d and %d", MinBitrate, MaxBitrate)
+	}
+	return Profile{Format: f, Bitrate: bitrate}, nil
+}
+
+// String names the profile, e.g. "mp3-192", or "mp3-192-gain+3.50" with a volume change.
+func (p Profile) String() string {
+	name := p.Format.Name + "-" + strconv.Itoa(p.Bitrate)
+	if g := p.gain(); g != "" {
+		name += "-gain" + g
+	}
+	return name
+}
+
+// gain formats GainDB for ffmpeg, or returns "" when it rounds to no change.
+func (p Profile) gain() string {
+	g := strconv.FormatFloat(p.GainDB, 'f', 2, 64)
+	if g == "0.00" || g == "-0.00" {
+		return ""
+	}
+	if p.GainDB > 0 {
+		g = "+" + g
+	}
+	return g
+}
+
+// Transcoder runs ffmpeg transcodes, at most a fixed number at a time.
+type Transcoder struct {
+	// QueueTimeout is how long Transcode waits for a free slot before returning ErrBusy.
+	QueueTimeout time.Duration
+
+	slots chan struct{}
+}
+
+// New returns a Transcoder that runs up to limit transcodes at once; 0 means one per CPU.
+func New(limit int) *Transcoder {
+	if limit <= 0 {
+		limit = runtime.NumCPU()
+	}
+	return &Transcoder{
+		QueueTimeout: defaultQueueTimeout,
+		slots:        make(chan struct{}, limit),
+	}
+}
+
+// acquire takes a slot, waiting up to QueueTimeout. The returned func frees it.
+func (t *Transcoder) acquire(ctx context.Context) (func(), error) {
+	timer := time.NewTimer(t.QueueTimeout)
+	defer timer.Stop()
+	select {
+	case t.slots <- struct{}{}:
+		return func() { <-t.slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, ErrBusy
+	}
+}
+
+// Transcode encodes the first audio stream of path into w. ffmpeg is killed when ctx
+// is done, in which case ctx.Err() is returned.
+func (t *Transcoder) Transcode(ctx context.Context, path string, p Profile, w io.Writer) error {
+	stream := ffmpeg.Input(path).Output("pipe:1", p.outputArgs())
+	return t.run(ctx, stream, w)
+}
+
+// TranscodeFile encodes the first audio stream of path into the file dest.
+func (t *Transcoder) TranscodeFile(ctx context.Context, path string, p Profile, dest string) error {
+	stream := ffmpeg.Input(path).Output(dest, p.outputArgs())
+	return t.run(ctx, stream, nil)
+}
+
+// TranscodeSegments encodes the whole of path once and splits the output into segments
+// of length in dir, named 0.ts, 1.ts and so on. A single encode primes the encoder once
+// and keeps timestamps running, so the segments join without gaps or clicks. ready is
+// called with each segment's file name once it is complete.
+func (t *Transcoder) TranscodeSegments(ctx context.Context, path string, p Profile, length time.Duration, dir string, ready func(name string)) error {
+	args := p.outputArgs()
+	args["f"] = "segment"
+	args["segment_format"] = p.Format.Muxer
+	args["segment_time"] = seconds(length)
+	// The muxer lists each segment on stdout once it has closed the file.
+	args["segment_list"] = "pipe:1"
+	args["segment_list_type"] = "flat"
+	stream := ffmpeg.Input(path).Output(filepath.Join(dir, "%d."+p.Format.Ext), args).OverWriteOutput()
+	return t.run(ctx, stream, &lineWriter{fn: ready})
+}
+
+// outputArgs maps the profile to ffmpeg output options, keeping only the first audio stream.
+func (p Profile) outputArgs() ffmpeg.KwArgs {
+	args := ffmpeg.KwArgs{
+		"map":      "0:a:0",
+		"c:a":      p.Format.Codec,
+		"b:a":      strconv.Itoa(p.Bitrate) + "k",
+		"f":        p.Format.Muxer,
+		"loglevel": "error",
+	}
+	if g := p.gain(); g != "" {
+		args["af"] = "volume=" + g + "dB"
+	}
+	return args
+}
+
+// run takes a slot and runs stream, writing to w when it outputs to a pipe and
+// overwriting its output file otherwise.
+func (t *Transcoder) run(ctx context.Context, stream *ffmpeg.Stream, w io.Writer) error {
+	release, err := t.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	var stderr bytes.Buffer
+	stream.Context = ctx
+	if w != nil {
+		stream = stream.WithOutput(w)
+	} else {
+		stream = stream.OverWriteOutput()
+	}
+	err = stream.WithErrorOutput(&stderr).Run()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("ffmpeg: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// lineWriter calls fn with each complete line written to it.
+type lineWriter struct {
+	fn  func(line string)
+	buf []byte
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.buf = append(lw.buf, p...)
+	for {
+		i := bytes.IndexByte(lw.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if line := strings.TrimSpace(string(lw.buf[:i])); line != "" {
+			lw.fn(line)
+		}
+		lw.buf = lw.buf[i+1:]
+	}
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
@@ -11,6 +11,11 @@ import (
 //go:embed migrations/*.sql
 var migrationFS embed.FS
 
+// onceMarker flags a migration that is not safe to re-run (e.g. ALTER TABLE ... ADD COLUMN).
+// Such files are applied a single time and recorded in schema_migrations; all other
+// migrations are create-only and run on every startup.
+const onceMarker = "-- migrate:once"
+
 func ApplyMigrations(db *sql.DB) error {
 	entries, err := migrationFS.ReadDir("migrations")
 	if err != nil {
@@ -29,11 +34,24 @@ func ApplyMigrations(db *sql.DB) error {
 	}
 	sort.Strings(files)
 
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+  name TEXT PRIMARY KEY,
+  applied_at DATETIME NOT NULL DEFAULT (CURRENT_TIMESTAMP)
+);`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
 	for _, f := range files {
 		b, err := migrationFS.ReadFile("migrations/" + f)
 		if err != nil {
 			return fmt.Errorf("read migration %s: %w", f, err)
 		}
+		if strings.HasPrefix(string(b), onceMarker) {
+			if err := applyOnce(db, f, string(b)); err != nil {
+				return fmt.Errorf("exec migration %s: %w", f, err)
+			}
+			continue
+		}
 		if _, err := db.Exec(string(b)); err != nil {
 			return fmt.Errorf("exec migration %s: %w", f, err)
 		}
@@ -41,3 +59,27 @@ func ApplyMigrations(db *sql.DB) error {
 
 	return nil
 }
+
+func applyOnce(db *sql.DB, name, body string) error {
+	var applied int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM schema_migrations WHERE name = ?`, name).Scan(&applied); err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(body); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (name) VALUES (?)`, name); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,3 @@
+-- migrate:once
+-- ---------- folders: filesystem watch flag ----------
+ALTER TABLE folders ADD COLUMN watch INTEGER NOT NULL DEFAULT 0 CHECK (watch IN (0,1));
@@ -0,0 +1,4 @@
+-- migrate:once
+-- ---------- folders: scheduled rescans ----------
+-- "nightly" or an interval in hours such as "6h"; NULL disables scheduled scans
+ALTER TABLE folders ADD COLUMN scan_schedule TEXT NULL;
@@ -0,0 +1,15 @@
+-- ---------- scan_runs (one row per folder scan) ----------
+CREATE TABLE IF NOT EXISTS scan_runs (
+  id INTEGER PRIMARY KEY,
+  folder_id INTEGER NOT NULL REFERENCES folders(id),
+
+  status TEXT NOT NULL DEFAULT 'running', -- "running" | "ok" | "error" | "skipped_unavailable" | "interrupted"
+  full_rescan INTEGER NOT NULL DEFAULT 0 CHECK (full_rescan IN (0,1)),
+  error TEXT NULL,
+
+  started_at DATETIME NOT NULL DEFAULT (CURRENT_TIMESTAMP),
+  finished_at DATETIME NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_scan_runs_folder_started ON scan_runs(folder_id, started_at);
+CREATE INDEX IF NOT EXISTS idx_scan_runs_status ON scan_runs(status);
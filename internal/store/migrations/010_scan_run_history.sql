@@ -0,0 +1,19 @@
+-- migrate:once
+-- ---------- scan_runs: trigger and counters ----------
+ALTER TABLE scan_runs ADD COLUMN triggered_by TEXT NOT NULL DEFAULT 'manual'; -- "manual" | "watch" | "schedule" | "recovery"
+ALTER TABLE scan_runs ADD COLUMN files_seen INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scan_runs ADD COLUMN tracks_added INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scan_runs ADD COLUMN tracks_updated INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scan_runs ADD COLUMN tracks_removed INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scan_runs ADD COLUMN files_failed INTEGER NOT NULL DEFAULT 0;
+
+-- ---------- scan_run_errors (per-file problems during a run) ----------
+CREATE TABLE IF NOT EXISTS scan_run_errors (
+  id INTEGER PRIMARY KEY,
+  scan_run_id INTEGER NOT NULL REFERENCES scan_runs(id) ON DELETE CASCADE,
+  rel_path TEXT NOT NULL,
+  error TEXT NOT NULL,
+  created_at DATETIME NOT NULL DEFAULT (CURRENT_TIMESTAMP)
+);
+
+CREATE INDEX IF NOT EXISTS idx_scan_run_errors_run ON scan_run_errors(scan_run_id);
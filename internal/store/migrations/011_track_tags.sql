@@ -0,0 +1,17 @@
+-- migrate:once
+-- ---------- tracks: full tag set ----------
+ALTER TABLE tracks ADD COLUMN track_number INTEGER NULL;
+ALTER TABLE tracks ADD COLUMN track_total INTEGER NULL;
+ALTER TABLE tracks ADD COLUMN disc_number INTEGER NULL;
+ALTER TABLE tracks ADD COLUMN disc_total INTEGER NULL;
+ALTER TABLE tracks ADD COLUMN album_artist TEXT NULL;
+ALTER TABLE tracks ADD COLUMN composer TEXT NULL;
+ALTER TABLE tracks ADD COLUMN comment TEXT NULL;
+ALTER TABLE tracks ADD COLUMN lyrics TEXT NULL;
+ALTER TABLE tracks ADD COLUMN bpm INTEGER NULL;
+ALTER TABLE tracks ADD COLUMN compilation INTEGER NOT NULL DEFAULT 0 CHECK (compilation IN (0, 1));
+
+-- ---------- albums: compilation flag ----------
+ALTER TABLE albums ADD COLUMN compilation INTEGER NOT NULL DEFAULT 0 CHECK (compilation IN (0, 1));
+
+CREATE INDEX IF NOT EXISTS idx_tracks_album_disc_track ON tracks(album_id, disc_number, track_number);
@@ -0,0 +1,4 @@
+-- migrate:once
+-- ---------- tracks: manual album assignment ----------
+-- Set by the album merge/split API; the scanner keeps album_id for pinned tracks.
+ALTER TABLE tracks ADD COLUMN album_pinned INTEGER NOT NULL DEFAULT 0 CHECK (album_pinned IN (0, 1));
@@ -0,0 +1,36 @@
+-- migrate:once
+-- ---------- tracks: release date tag ----------
+ALTER TABLE tracks ADD COLUMN release_date TEXT NULL; -- "YYYY", "YYYY-MM" or "YYYY-MM-DD"
+
+-- ---------- albums: values rolled up from live tracks ----------
+ALTER TABLE albums ADD COLUMN year INTEGER NULL;
+ALTER TABLE albums ADD COLUMN release_date TEXT NULL;
+ALTER TABLE albums ADD COLUMN genre TEXT NULL;
+ALTER TABLE albums ADD COLUMN track_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE albums ADD COLUMN disc_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE albums ADD COLUMN duration_seconds INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_albums_year ON albums(year);
+
+-- Backfill from what is already indexed; the scanner keeps these current from here on.
+UPDATE albums
+SET year = (SELECT MIN(t.year) FROM tracks t WHERE t.album_id = albums.id AND t.deleted_at IS NULL),
+  genre = (
+    SELECT t.genre
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+      AND t.genre IS NOT NULL
+    GROUP BY t.genre
+    ORDER BY COUNT(*) DESC, t.genre
+    LIMIT 1
+  ),
+  track_count = (SELECT COUNT(*) FROM tracks t WHERE t.album_id = albums.id AND t.deleted_at IS NULL),
+  disc_count = (
+    SELECT MAX(COALESCE(MAX(t.disc_total), 0), COUNT(DISTINCT COALESCE(t.disc_number, 1)))
+    FROM tracks t
+    WHERE t.album_id = albums.id
+      AND t.deleted_at IS NULL
+  ),
+  duration_seconds = (SELECT COALESCE(SUM(t.duration_seconds), 0) FROM tracks t WHERE t.album_id = albums.id AND t.deleted_at IS NULL)
+WHERE deleted_at IS NULL;
@@ -0,0 +1,147 @@
+-- migrate:once
+-- ---------- full-text search: tracks ----------
+-- Tracks are indexed together with their artist and album names, so this table keeps
+-- its own copy of the text (rowid = tracks.id). Only live tracks are indexed.
+CREATE VIRTUAL TABLE IF NOT EXISTS tracks_fts USING fts5(
+  title,
+  artist,
+  album,
+  genre,
+  tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS tracks_fts_insert
+AFTER INSERT ON tracks
+FOR EACH ROW
+WHEN NEW.deleted_at IS NULL
+BEGIN
+  INSERT INTO tracks_fts (rowid, title, artist, album, genre)
+  VALUES (
+    NEW.id,
+    NEW.title,
+    (SELECT name FROM artists WHERE id = NEW.artist_id),
+    (SELECT title FROM albums WHERE id = NEW.album_id),
+    NEW.genre
+  );
+END;
+
+CREATE TRIGGER IF NOT EXISTS tracks_fts_update
+AFTER UPDATE OF title, genre, artist_id, album_id, deleted_at ON tracks
+FOR EACH ROW
+WHEN NEW.title IS NOT OLD.title
+  OR NEW.genre IS NOT OLD.genre
+  OR NEW.artist_id IS NOT OLD.artist_id
+  OR NEW.album_id IS NOT OLD.album_id
+  OR NEW.deleted_at IS NOT OLD.deleted_at
+BEGIN
+  DELETE FROM tracks_fts WHERE rowid = OLD.id;
+  INSERT INTO tracks_fts (rowid, title, artist, album, genre)
+  SELECT
+    NEW.id,
+    NEW.title,
+    (SELECT name FROM artists WHERE id = NEW.artist_id),
+    (SELECT title FROM albums WHERE id = NEW.album_id),
+    NEW.genre
+  WHERE NEW.deleted_at IS NULL;
+END;
+
+CREATE TRIGGER IF NOT EXISTS tracks_fts_delete
+AFTER DELETE ON tracks
+FOR EACH ROW
+BEGIN
+  DELETE FROM tracks_fts WHERE rowid = OLD.id;
+END;
+
+-- Renames reach the copies held for each track. The upserts rewrite name/title with
+-- the same value on every scanned file, hence the WHEN guards.
+CREATE TRIGGER IF NOT EXISTS tracks_fts_artist_name
+AFTER UPDATE OF name ON artists
+FOR EACH ROW
+WHEN NEW.name IS NOT OLD.name
+BEGIN
+  UPDATE tracks_fts
+  SET artist = NEW.name
+  WHERE rowid IN (SELECT id FROM tracks WHERE artist_id = NEW.id AND deleted_at IS NULL);
+END;
+
+CREATE TRIGGER IF NOT EXISTS tracks_fts_album_title
+AFTER UPDATE OF title ON albums
+FOR EACH ROW
+WHEN NEW.title IS NOT OLD.title
+BEGIN
+  UPDATE tracks_fts
+  SET album = NEW.title
+  WHERE rowid IN (SELECT id FROM tracks WHERE album_id = NEW.id AND deleted_at IS NULL);
+END;
+
+INSERT INTO tracks_fts (rowid, title, artist, album, genre)
+SELECT t.id, t.title, ar.name, al.title, t.genre
+FROM tracks t
+LEFT JOIN artists ar ON ar.id = t.artist_id
+LEFT JOIN albums al ON al.id = t.album_id
+WHERE t.deleted_at IS NULL;
+
+-- ---------- full-text search: artists and albums ----------
+-- External-content tables over the name/title column; soft-deleted rows are filtered at query time.
+CREATE VIRTUAL TABLE IF NOT EXISTS artists_fts USING fts5(
+  name,
+  content = 'artists',
+  content_rowid = 'id',
+  tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS artists_fts_insert
+AFTER INSERT ON artists
+FOR EACH ROW
+BEGIN
+  INSERT INTO artists_fts (rowid, name) VALUES (NEW.id, NEW.name);
+END;
+
+CREATE TRIGGER IF NOT EXISTS artists_fts_update
+AFTER UPDATE OF name ON artists
+FOR EACH ROW
+WHEN NEW.name IS NOT OLD.name
+BEGIN
+  INSERT INTO artists_fts (artists_fts, rowid, name) VALUES ('delete', OLD.id, OLD.name);
+  INSERT INTO artists_fts (rowid, name) VALUES (NEW.id, NEW.name);
+END;
+
+CREATE TRIGGER IF NOT EXISTS artists_fts_delete
+AFTER DELETE ON artists
+FOR EACH ROW
+BEGIN
+  INSERT INTO artists_fts (artists_fts, rowid, name) VALUES ('delete', OLD.id, OLD.name);
+END;
+
+CREATE VIRTUAL TABLE IF NOT EXISTS albums_fts USING fts5(
+  title,
+  content = 'albums',
+  content_rowid = 'id',
+  tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS albums_fts_insert
+AFTER INSERT ON albums
+FOR EACH ROW
+BEGIN
+  INSERT INTO albums_fts (rowid, title) VALUES (NEW.id, NEW.title);
+END;
+
+CREATE TRIGGER IF NOT EXISTS albums_fts_update
+AFTER UPDATE OF title ON albums
+FOR EACH ROW
+WHEN NEW.title IS NOT OLD.title
+BEGIN
+  INSERT INTO albums_fts (albums_fts, rowid, title) VALUES ('delete', OLD.id, OLD.title);
+  INSERT INTO albums_fts (rowid, title) VALUES (NEW.id, NEW.title);
+END;
+
+CREATE TRIGGER IF NOT EXISTS albums_fts_delete
+AFTER DELETE ON albums
+FOR EACH ROW
+BEGIN
+  INSERT INTO albums_fts (albums_fts, rowid, title) VALUES ('delete', OLD.id, OLD.title);
+END;
+
+INSERT INTO artists_fts (artists_fts) VALUES ('rebuild');
+INSERT INTO albums_fts (albums_fts) VALUES ('rebuild');
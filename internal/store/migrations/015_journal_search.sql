@@ -0,0 +1,35 @@
+-- ---------- full-text search: journal entries ----------
+-- journal_entries is recreated on every startup (004_tasks.sql), which drops its
+-- triggers, so this file is not migrate:once and rebuilds the index each time.
+CREATE VIRTUAL TABLE IF NOT EXISTS journal_entries_fts USING fts5(
+  title,
+  body,
+  content = 'journal_entries',
+  content_rowid = 'id',
+  tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER IF NOT EXISTS journal_entries_fts_insert
+AFTER INSERT ON journal_entries
+FOR EACH ROW
+BEGIN
+  INSERT INTO journal_entries_fts (rowid, title, body) VALUES (NEW.id, NEW.title, NEW.body);
+END;
+
+CREATE TRIGGER IF NOT EXISTS journal_entries_fts_update
+AFTER UPDATE OF title, body ON journal_entries
+FOR EACH ROW
+WHEN NEW.title IS NOT OLD.title OR NEW.body IS NOT OLD.body
+BEGIN
+  INSERT INTO journal_entries_fts (journal_entries_fts, rowid, title, body) VALUES ('delete', OLD.id, OLD.title, OLD.body);
+  INSERT INTO journal_entries_fts (rowid, title, body) VALUES (NEW.id, NEW.title, NEW.body);
+END;
+
+CREATE TRIGGER IF NOT EXISTS journal_entries_fts_delete
+AFTER DELETE ON journal_entries
+FOR EACH ROW
+BEGIN
+  INSERT INTO journal_entries_fts (journal_entries_fts, rowid, title, body) VALUES ('delete', OLD.id, OLD.title, OLD.body);
+END;
+
+INSERT INTO journal_entries_fts (journal_entries_fts) VALUES ('rebuild');
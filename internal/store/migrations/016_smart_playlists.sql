@@ -0,0 +1,10 @@
+-- migrate:once
+-- ---------- playlists: smart playlists ----------
+-- Smart playlists have no playlist_tracks rows. Their rules (JSON) are evaluated against tracks on read.
+ALTER TABLE playlists ADD COLUMN type TEXT NOT NULL DEFAULT 'static' CHECK (type IN ('static', 'smart'));
+ALTER TABLE playlists ADD COLUMN rules TEXT NULL;
+
+-- ---------- tracks: play counters ----------
+-- Kept on the track so smart playlist rules and sorts can filter on them directly.
+ALTER TABLE tracks ADD COLUMN play_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tracks ADD COLUMN last_played_at DATETIME NULL;
@@ -0,0 +1,32 @@
+-- ---------- plays (listening history) ----------
+-- One row per reported or inferred play. counted says whether the play reached the
+-- scrobble threshold and so counts towards tracks.play_count.
+CREATE TABLE IF NOT EXISTS plays (
+  id INTEGER PRIMARY KEY,
+  track_id INTEGER NOT NULL REFERENCES tracks(id),
+
+  played_at DATETIME NOT NULL DEFAULT (CURRENT_TIMESTAMP),
+  position_seconds INTEGER NULL, -- how far playback got, when the client reports it
+  completed INTEGER NOT NULL DEFAULT 0 CHECK (completed IN (0,1)),
+  counted INTEGER NOT NULL DEFAULT 1 CHECK (counted IN (0,1)),
+  source TEXT NOT NULL DEFAULT 'client' CHECK (source IN ('client', 'stream')),
+
+  created_at DATETIME NOT NULL DEFAULT (CURRENT_TIMESTAMP)
+);
+
+CREATE INDEX IF NOT EXISTS idx_plays_played_at ON plays(played_at);
+CREATE INDEX IF NOT EXISTS idx_plays_track_played_at ON plays(track_id, played_at);
+
+-- Keep the per-track counters in step. Back-dated plays leave a later last_played_at alone.
+CREATE TRIGGER IF NOT EXISTS plays_update_track
+AFTER INSERT ON plays
+FOR EACH ROW
+BEGIN
+  UPDATE tracks
+  SET play_count = play_count + NEW.counted,
+      last_played_at = CASE
+        WHEN last_played_at IS NULL OR last_played_at < NEW.played_at THEN NEW.played_at
+        ELSE last_played_at
+      END
+  WHERE id = NEW.track_id;
+END;
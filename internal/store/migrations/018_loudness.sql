@@ -0,0 +1,40 @@
+-- migrate:once
+-- ---------- tracks: loudness analysis ----------
+-- Filled by the optional loudness pass (ffmpeg loudnorm, EBU R128). Gains are ReplayGain 2.0
+-- style, relative to -18 LUFS. loudness_analyzed_at is set even when analysis fails, so a bad
+-- file is not retried until it changes; UpsertTrack clears all of these when it does.
+ALTER TABLE tracks ADD COLUMN loudness_lufs REAL NULL;
+ALTER TABLE tracks ADD COLUMN peak_dbtp REAL NULL; -- true peak
+ALTER TABLE tracks ADD COLUMN replay_gain_db REAL NULL;
+ALTER TABLE tracks ADD COLUMN loudness_analyzed_at DATETIME NULL;
+-- Copies of the album's values, like REPLAYGAIN_ALBUM_* tags; kept in step by the triggers below.
+ALTER TABLE tracks ADD COLUMN album_gain_db REAL NULL;
+ALTER TABLE tracks ADD COLUMN album_peak_dbtp REAL NULL;
+
+-- ---------- albums: loudness rolled up from live tracks ----------
+-- NULL until every live track on the album has been analyzed.
+ALTER TABLE albums ADD COLUMN loudness_lufs REAL NULL;
+ALTER TABLE albums ADD COLUMN peak_dbtp REAL NULL;
+ALTER TABLE albums ADD COLUMN replay_gain_db REAL NULL;
+
+CREATE TRIGGER IF NOT EXISTS albums_loudness_update_tracks
+AFTER UPDATE OF replay_gain_db, peak_dbtp ON albums
+FOR EACH ROW
+WHEN NEW.replay_gain_db IS NOT OLD.replay_gain_db OR NEW.peak_dbtp IS NOT OLD.peak_dbtp
+BEGIN
+  UPDATE tracks
+  SET album_gain_db = NEW.replay_gain_db,
+      album_peak_dbtp = NEW.peak_dbtp
+  WHERE album_id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS tracks_album_loudness
+AFTER UPDATE OF album_id ON tracks
+FOR EACH ROW
+WHEN NEW.album_id IS NOT OLD.album_id
+BEGIN
+  UPDATE tracks
+  SET album_gain_db = (SELECT replay_gain_db FROM albums WHERE id = NEW.album_id),
+      album_peak_dbtp = (SELECT peak_dbtp FROM albums WHERE id = NEW.album_id)
+  WHERE id = NEW.id;
+END;
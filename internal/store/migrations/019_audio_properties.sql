@@ -0,0 +1,16 @@
+-- migrate:once
+-- ---------- tracks: audio properties from ffprobe ----------
+-- Refreshed on every scan of the file; a failed probe keeps the previous values.
+ALTER TABLE tracks ADD COLUMN duration_ms INTEGER NULL;
+ALTER TABLE tracks ADD COLUMN sample_rate INTEGER NULL; -- Hz
+ALTER TABLE tracks ADD COLUMN bit_depth INTEGER NULL; -- lossless and PCM only
+ALTER TABLE tracks ADD COLUMN channels INTEGER NULL;
+ALTER TABLE tracks ADD COLUMN codec TEXT NULL; -- ffprobe codec_name, e.g. "flac", "mp3"
+ALTER TABLE tracks ADD COLUMN bitrate INTEGER NULL; -- kbit/s
+
+-- ---------- tracks: gapless playback ----------
+-- Encoder delay and padding in samples, from the LAME header (MP3) or iTunSMPB (AAC/M4A).
+-- sample_count is the exact number of playable samples per channel, delay and padding removed.
+ALTER TABLE tracks ADD COLUMN encoder_delay INTEGER NULL;
+ALTER TABLE tracks ADD COLUMN encoder_padding INTEGER NULL;
+ALTER TABLE tracks ADD COLUMN sample_count INTEGER NULL;
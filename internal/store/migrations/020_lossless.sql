@@ -0,0 +1,15 @@
+-- migrate:once
+-- ---------- tracks: lossless flag ----------
+-- Set by the scanner from the codec; NULL while the codec is unknown.
+ALTER TABLE tracks ADD COLUMN lossless INTEGER NULL CHECK (lossless IN (0, 1));
+
+-- Backfill tracks probed before the column existed. Keep in step with losslessCodecs in
+-- the scanner.
+UPDATE tracks
+SET lossless = CASE
+  WHEN codec IN ('flac', 'alac', 'ape', 'wavpack', 'tta', 'mlp', 'truehd') OR codec LIKE 'pcm\_%' ESCAPE '\' THEN 1
+  ELSE 0
+END
+WHERE codec IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_tracks_codec_bitrate ON tracks(codec, bitrate);
@@ -0,0 +1,12 @@
+-- ---------- plays: client reports upgrade inferred plays ----------
+-- An inferred (stream) play is stored uncounted. When the client reports the same play,
+-- the row is updated in place, so play_count follows changes to counted as well.
+CREATE TRIGGER IF NOT EXISTS plays_update_track_counted
+AFTER UPDATE OF counted ON plays
+FOR EACH ROW
+WHEN NEW.counted <> OLD.counted
+BEGIN
+  UPDATE tracks
+  SET play_count = play_count + NEW.counted - OLD.counted
+  WHERE id = NEW.track_id;
+END;
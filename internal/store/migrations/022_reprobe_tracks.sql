@@ -0,0 +1,9 @@
+-- migrate:once
+-- ---------- tracks: re-read files indexed before the audio columns ----------
+-- Incremental scans skip files whose size and mtime have not changed, so tracks indexed
+-- before 019 would keep NULL audio properties, and 020's lossless backfill found nothing
+-- to fill. Clearing last_modified makes the next scan of each folder re-read them once.
+UPDATE tracks
+SET last_modified = 0
+WHERE codec IS NULL
+  AND deleted_at IS NULL;